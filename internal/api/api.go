@@ -1,14 +1,19 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
+	"testovoe/internal/audit"
 	"testovoe/internal/config"
+	"testovoe/internal/currency"
 	"testovoe/internal/handlers"
+	"testovoe/internal/outbox"
 	"testovoe/internal/storage"
+	"testovoe/internal/trash"
 	"testovoe/internal/utils"
 	"testovoe/internal/validators"
     "time"
@@ -45,18 +50,57 @@ func (a *Api) Run() error {
 	}
 	store := storage.NewPostgresStorage(db)
 
+	var rates currency.RateProvider = store.ExchangeRate
+	if a.cfg.Currency.RatesFile != "" {
+		rates, err = currency.LoadFile(a.cfg.Currency.RatesFile)
+		if err != nil {
+			return err
+		}
+	}
+
 	validate := validator.New(validator.WithRequiredStructEnabled())
 	validate.RegisterValidation("mm_yyyy", validators.MonthYearValidator)
+	validate.RegisterValidation("mm_yyyy_or_date", validators.MonthYearOrDateValidator)
+	validate.RegisterValidation("metadata", validators.MetadataValidator)
 
-	subHandler := handlers.NewSubscriptionHandler(store, validate)
-	mux.HandleFunc("POST /subscriptions", subHandler.Create)
+	subHandler := handlers.NewSubscriptionHandler(store, validate, rates)
+	idempotency := handlers.NewIdempotency(store.Idempotency, a.cfg.Idempotency.KeyTTL)
+	mux.HandleFunc("POST /subscriptions", idempotency.Wrap(subHandler.Create))
 	mux.HandleFunc("GET /subscriptions/{id}", subHandler.Get)
 	mux.HandleFunc("PUT /subscriptions/{id}", subHandler.Update)
+	mux.HandleFunc("PATCH /subscriptions/{id}", subHandler.Patch)
 	mux.HandleFunc("DELETE /subscriptions/{id}", subHandler.Delete)
+	mux.HandleFunc("POST /subscriptions/{id}/restore", subHandler.Restore)
+	mux.HandleFunc("GET /subscriptions/trash", subHandler.Trash)
 	mux.HandleFunc("GET /subscriptions", subHandler.List)
+	mux.HandleFunc("GET /subscriptions/export", subHandler.Export)
+	mux.HandleFunc("GET /subscriptions/search", subHandler.Search)
+	mux.HandleFunc("POST /subscriptions:batch", subHandler.Batch)
+	mux.HandleFunc("POST /subscriptions/import", subHandler.Import)
+	mux.HandleFunc("GET /subscriptions/total", subHandler.Total)
+	mux.HandleFunc("GET /subscriptions/timeseries", subHandler.TimeSeries)
+	mux.HandleFunc("POST /subscriptions/{id}/price-changes", subHandler.ChangePrice)
+	mux.HandleFunc("GET /subscriptions/{id}/price-history", subHandler.PriceHistory)
+	mux.HandleFunc("POST /subscriptions/{id}/pause", subHandler.Pause)
+	mux.HandleFunc("POST /subscriptions/{id}/resume", subHandler.Resume)
+	mux.HandleFunc("GET /subscriptions/{id}/pauses", subHandler.Pauses)
+	mux.HandleFunc("GET /subscriptions/{id}/history", subHandler.History)
+	mux.HandleFunc("GET /audit", subHandler.Audit)
+	mux.HandleFunc("GET /reports/breakdown", subHandler.Breakdown)
+
+	serviceHandler := handlers.NewServiceHandler(store, validate)
+
+	mux.HandleFunc("POST /services", serviceHandler.Create)
+	mux.HandleFunc("GET /services", serviceHandler.List)
+	mux.HandleFunc("GET /services/{id}", serviceHandler.Get)
+	mux.HandleFunc("PUT /services/{id}", serviceHandler.Update)
+	mux.HandleFunc("DELETE /services/{id}", serviceHandler.Delete)
 
 	// Wrap the mux with gzip compression to reduce payload sizes
 	handler := utils.GzipMiddleware(mux)
+	// Changes are recorded in the audit trail with the actor and the
+	// request id.
+	handler = audit.Middleware(handler)
 
 	srv := &http.Server{
 		Addr:              net.JoinHostPort(a.cfg.Api.Host, fmt.Sprint(a.cfg.Api.Port)),
@@ -68,6 +112,27 @@ func (a *Api) Run() error {
 		MaxHeaderBytes:    1 << 20,
 	}
 
+	// Deleted subscriptions stay in the trash for the retention period.
+	go trash.NewPurger(store.Subscription, a.cfg.Trash.Retention, a.cfg.Trash.PurgeInterval).Run(context.Background())
+
+	// The subscription events are published to the file if it is set, and
+	// to a channel drained in the process otherwise, so that the outbox
+	// doesn't grow.
+	var sink outbox.Sink
+	if a.cfg.Outbox.File != "" {
+		fileSink, err := outbox.NewFileSink(a.cfg.Outbox.File)
+		if err != nil {
+			return err
+		}
+		defer fileSink.Close()
+		sink = fileSink
+	} else {
+		channelSink := outbox.NewChannelSink(a.cfg.Outbox.BatchSize)
+		go outbox.Consume(context.Background(), channelSink.Events())
+		sink = channelSink
+	}
+	go outbox.NewDispatcher(store.Outbox, sink, a.cfg.Outbox.PollInterval, a.cfg.Outbox.BatchSize).Run(context.Background())
+
 	slog.Info("start server", "host", a.cfg.Api.Host, "port", a.cfg.Api.Port)
 	return srv.ListenAndServe()
 }
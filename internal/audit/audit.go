@@ -0,0 +1,84 @@
+// Package audit carries who made a request, and which request it was,
+// down to the storage, which records them with every change of a
+// subscription.
+package audit
+
+import (
+	"context"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ActorHeader names the user or the service making the request. There
+	// is no authentication, so it is taken on trust.
+	ActorHeader = "X-Actor"
+	// RequestIDHeader identifies the request. It is generated unless the
+	// client sends one, and is sent back in the response.
+	RequestIDHeader = "X-Request-ID"
+
+	// MaxHeaderLength bounds the actor and the request id, longer ones are
+	// cut.
+	MaxHeaderLength = 200
+)
+
+type contextKey int
+
+const (
+	actorKey contextKey = iota
+	requestIDKey
+)
+
+// WithActor returns a copy of ctx carrying the actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// Actor returns the actor of ctx, or "" if it is unknown.
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey).(string)
+	return actor
+}
+
+// WithRequestID returns a copy of ctx carrying the request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request id of ctx, or "" if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// Middleware puts the actor and the request id of the request into its
+// context.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := truncate(r.Header.Get(RequestIDHeader))
+		if id == "" {
+			id = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := WithRequestID(r.Context(), id)
+		if actor := truncate(r.Header.Get(ActorHeader)); actor != "" {
+			ctx = WithActor(ctx, actor)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// truncate cuts s to MaxHeaderLength bytes, without splitting a rune.
+func truncate(s string) string {
+	if len(s) <= MaxHeaderLength {
+		return s
+	}
+	n := MaxHeaderLength
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+)
+
+func serve(header http.Header) (actor, requestID string, w *httptest.ResponseRecorder) {
+	handler := Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		actor = Actor(r.Context())
+		requestID = RequestID(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header = header
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return actor, requestID, w
+}
+
+func TestMiddleware(t *testing.T) {
+	header := http.Header{}
+	header.Set(ActorHeader, "support@example.com")
+	header.Set(RequestIDHeader, "req-1")
+	actor, requestID, w := serve(header)
+	assert.Equal(t, "support@example.com", actor)
+	assert.Equal(t, "req-1", requestID)
+	assert.Equal(t, "req-1", w.Header().Get(RequestIDHeader))
+}
+
+func TestMiddlewareGeneratesRequestID(t *testing.T) {
+	actor, requestID, w := serve(http.Header{})
+	assert.Equal(t, "", actor)
+	_, err := uuid.Parse(requestID)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, requestID, w.Header().Get(RequestIDHeader))
+}
+
+func TestMiddlewareTruncates(t *testing.T) {
+	header := http.Header{}
+	header.Set(ActorHeader, "a"+strings.Repeat("я", MaxHeaderLength))
+	actor, _, _ := serve(header)
+	// 199 bytes: the last rune doesn't fit.
+	assert.Equal(t, MaxHeaderLength-1, len(actor))
+}
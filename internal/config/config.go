@@ -1,10 +1,20 @@
 package config
 
-import "github.com/ilyakaznacheev/cleanenv"
+import (
+	"errors"
+	"time"
+
+	"github.com/ilyakaznacheev/cleanenv"
+)
 
 type Config struct {
 	Database DatabaseConfig
 	Api      ApiConfig
+	Currency CurrencyConfig
+
+	Idempotency IdempotencyConfig
+	Trash       TrashConfig
+	Outbox      OutboxConfig
 }
 
 type DatabaseConfig struct {
@@ -20,6 +30,36 @@ type ApiConfig struct {
 	Port int    `env:"API_PORT" env-default:"8080"`
 }
 
+type CurrencyConfig struct {
+	// RatesFile is a JSON file with exchange rates. If empty, the rates
+	// are read from the exchange_rates table.
+	RatesFile string `env:"RATES_FILE"`
+}
+
+type IdempotencyConfig struct {
+	// KeyTTL is how long a response is kept for an Idempotency-Key.
+	KeyTTL time.Duration `env:"IDEMPOTENCY_KEY_TTL" env-default:"24h"`
+}
+
+type TrashConfig struct {
+	// Retention is how long a deleted subscription can be restored before
+	// it is purged.
+	Retention time.Duration `env:"TRASH_RETENTION" env-default:"720h"`
+	// PurgeInterval is how often the trash is checked for subscriptions
+	// to purge.
+	PurgeInterval time.Duration `env:"TRASH_PURGE_INTERVAL" env-default:"1h"`
+}
+
+type OutboxConfig struct {
+	// File is the NDJSON file the subscription events are appended to. If
+	// empty, they are published to an in-process channel and logged.
+	File string `env:"OUTBOX_FILE"`
+	// PollInterval is how often the outbox is checked for new events.
+	PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" env-default:"1s"`
+	// BatchSize is the maximum number of events published at once.
+	BatchSize int `env:"OUTBOX_BATCH_SIZE" env-default:"100"`
+}
+
 func MustInit() *Config {
 	var cfg Config
 	if err := cleanenv.ReadConfig(".env", &cfg); err != nil {
@@ -27,5 +67,45 @@ func MustInit() *Config {
 			panic(err)
 		}
 	}
+	if err := cfg.Validate(); err != nil {
+		panic(err)
+	}
 	return &cfg
 }
+
+// Validate reports settings that are read but can't be used, e.g. a zero
+// interval of a background job.
+func (c *Config) Validate() error {
+	return errors.Join(c.Idempotency.validate(), c.Trash.validate(), c.Outbox.validate())
+}
+
+// validate rejects a TTL that isn't positive: the cleanup would delete
+// every key, even of requests in progress, which disables idempotency.
+func (c IdempotencyConfig) validate() error {
+	if c.KeyTTL <= 0 {
+		return errors.New("IDEMPOTENCY_KEY_TTL must be positive")
+	}
+	return nil
+}
+
+func (c TrashConfig) validate() error {
+	var errs []error
+	if c.Retention <= 0 {
+		errs = append(errs, errors.New("TRASH_RETENTION must be positive"))
+	}
+	if c.PurgeInterval <= 0 {
+		errs = append(errs, errors.New("TRASH_PURGE_INTERVAL must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+func (c OutboxConfig) validate() error {
+	var errs []error
+	if c.PollInterval <= 0 {
+		errs = append(errs, errors.New("OUTBOX_POLL_INTERVAL must be positive"))
+	}
+	if c.BatchSize <= 0 {
+		errs = append(errs, errors.New("OUTBOX_BATCH_SIZE must be positive"))
+	}
+	return errors.Join(errs...)
+}
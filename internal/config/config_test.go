@@ -0,0 +1,46 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func validConfig() Config {
+	return Config{
+		Idempotency: IdempotencyConfig{KeyTTL: 24 * time.Hour},
+		Trash:       TrashConfig{Retention: 720 * time.Hour, PurgeInterval: time.Hour},
+		Outbox:      OutboxConfig{PollInterval: time.Second, BatchSize: 100},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := validConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestValidateInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+	}{
+		{"zero key ttl", func(c *Config) { c.Idempotency.KeyTTL = 0 }},
+		{"negative key ttl", func(c *Config) { c.Idempotency.KeyTTL = -time.Hour }},
+		{"zero retention", func(c *Config) { c.Trash.Retention = 0 }},
+		{"negative retention", func(c *Config) { c.Trash.Retention = -time.Hour }},
+		{"zero purge interval", func(c *Config) { c.Trash.PurgeInterval = 0 }},
+		{"zero poll interval", func(c *Config) { c.Outbox.PollInterval = 0 }},
+		{"zero batch size", func(c *Config) { c.Outbox.BatchSize = 0 }},
+		{"negative batch size", func(c *Config) { c.Outbox.BatchSize = -1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(&cfg)
+			if err := cfg.Validate(); err == nil {
+				t.Error("Validate: got nil error")
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package currency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Default is the currency of subscriptions created without one and
+// of reports that don't ask for a specific currency.
+const Default = "RUB"
+
+var (
+	ErrUnknownCurrency = errors.New("unknown currency")
+)
+
+// Rates maps an ISO-4217 code to the price of one unit of that currency
+// in some common base currency.
+type Rates map[string]float64
+
+// RateProvider is a source of exchange rates, e.g. a file or a database table.
+type RateProvider interface {
+	Rates(ctx context.Context) (Rates, error)
+}
+
+// Convert converts amount from one currency to another, rounding to the
+// nearest whole unit. Converting a currency to itself never needs a rate.
+func (r Rates) Convert(amount int64, from, to string) (int64, error) {
+	if from == to {
+		return amount, nil
+	}
+
+	fromRate, ok := r[from]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownCurrency, from)
+	}
+	toRate, ok := r[to]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownCurrency, to)
+	}
+
+	return int64(math.Round(float64(amount) * fromRate / toRate)), nil
+}
+
+// Sum converts per-currency amounts to a single currency and adds them up.
+func (r Rates) Sum(amounts map[string]int64, to string) (int64, error) {
+	var total int64
+	for from, amount := range amounts {
+		converted, err := r.Convert(amount, from, to)
+		if err != nil {
+			return 0, err
+		}
+		total += converted
+	}
+	return total, nil
+}
+
+// StaticProvider serves a fixed set of rates.
+type StaticProvider struct {
+	rates Rates
+}
+
+func NewStaticProvider(rates Rates) *StaticProvider {
+	return &StaticProvider{rates: rates}
+}
+
+func (p *StaticProvider) Rates(ctx context.Context) (Rates, error) {
+	return p.rates, nil
+}
@@ -0,0 +1,84 @@
+package currency
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	rates := Rates{"RUB": 1, "USD": 90, "EUR": 100}
+
+	got, err := rates.Convert(10, "USD", "RUB")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 900 {
+		t.Fatalf("expected 900, got %d", got)
+	}
+
+	got, err = rates.Convert(100, "EUR", "USD")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 111 {
+		t.Fatalf("expected 111, got %d", got)
+	}
+}
+
+func TestConvertSameCurrencyWithoutRate(t *testing.T) {
+	got, err := Rates{}.Convert(42, "KZT", "KZT")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 42 {
+		t.Fatalf("expected 42, got %d", got)
+	}
+}
+
+func TestConvertUnknownCurrency(t *testing.T) {
+	_, err := Rates{"RUB": 1}.Convert(1, "USD", "RUB")
+	if !errors.Is(err, ErrUnknownCurrency) {
+		t.Fatalf("expected ErrUnknownCurrency, got %v", err)
+	}
+}
+
+func TestSum(t *testing.T) {
+	rates := Rates{"RUB": 1, "USD": 90, "EUR": 100}
+
+	got, err := rates.Sum(map[string]int64{"RUB": 500, "USD": 10, "EUR": 2}, "RUB")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 1600 {
+		t.Fatalf("expected 1600, got %d", got)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"RUB": 1, "USD": 92.5}`), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	provider, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rates, _ := provider.Rates(t.Context())
+	if rates["USD"] != 92.5 {
+		t.Fatalf("expected 92.5, got %v", rates["USD"])
+	}
+}
+
+func TestLoadFileNonPositiveRate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"USD": 0}`), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	if _, err := LoadFile(path); err == nil {
+		t.Fatalf("expected error for zero rate, got nil")
+	}
+}
@@ -0,0 +1,30 @@
+package currency
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// LoadFile reads rates from a JSON object that maps currency codes to
+// the price of one unit in the base currency, e.g.
+//
+//	{"RUB": 1, "USD": 92.5, "EUR": 100.3}
+func LoadFile(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates Rates
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, err
+	}
+	for code, rate := range rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("rate for %s must be positive, got %v", code, rate)
+		}
+	}
+
+	return NewStaticProvider(rates), nil
+}
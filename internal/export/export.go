@@ -0,0 +1,44 @@
+// Package export writes tables row by row, so that large results can be
+// streamed without holding them in memory.
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// Writer writes the rows of a table. A cell is a string, an integer, a
+// bool or nil for an empty cell. Close must be called after the last row
+// to complete the output; it doesn't close the underlying writer.
+type Writer interface {
+	Write(row []any) error
+	Close() error
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+// NewCSV returns a Writer of comma separated values.
+func NewCSV(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Write(row []any) error {
+	c.record = c.record[:0]
+	for _, cell := range row {
+		if cell == nil {
+			c.record = append(c.record, "")
+			continue
+		}
+		c.record = append(c.record, fmt.Sprint(cell))
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,119 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCSV(&buf)
+	if err := w.Write([]any{"id", "service_name", "end_date"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]any{1, "Yandex, Plus", nil}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "id,service_name,end_date\n1,\"Yandex, Plus\",\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+// xlsxCell is a cell of a worksheet as parsed by readSheet.
+type xlsxCell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Value  string `xml:"v"`
+	Inline string `xml:"is>t"`
+}
+
+func readSheet(t *testing.T, data []byte) [][]xlsxCell {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+
+	parts := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		parts[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+		var v struct{ XMLName xml.Name }
+		if err := xml.Unmarshal(parts[name], &v); err != nil {
+			t.Errorf("part %s: %v", name, err)
+		}
+	}
+
+	var sheet struct {
+		Rows []struct {
+			Cells []xlsxCell `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &sheet); err != nil {
+		t.Fatalf("parse sheet: %v", err)
+	}
+
+	var rows [][]xlsxCell
+	for _, row := range sheet.Rows {
+		rows = append(rows, row.Cells)
+	}
+	return rows
+}
+
+func TestXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewXLSX(&buf, "Subscriptions & co")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]any{"id", "service_name", "end_date"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]any{400, "<Netflix> & \"Co\"", nil}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := readSheet(t, buf.Bytes())
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if len(rows[0]) != 3 || rows[0][2].Ref != "C1" || rows[0][2].Inline != "end_date" {
+		t.Errorf("unexpected header %+v", rows[0])
+	}
+	if len(rows[1]) != 2 {
+		t.Fatalf("expected the empty cell to be left out, got %+v", rows[1])
+	}
+	if rows[1][0].Ref != "A2" || rows[1][0].Type != "" || rows[1][0].Value != "400" {
+		t.Errorf("expected a number cell, got %+v", rows[1][0])
+	}
+	if rows[1][1].Type != "inlineStr" || rows[1][1].Inline != "<Netflix> & \"Co\"" {
+		t.Errorf("expected an inline string cell, got %+v", rows[1][1])
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	tests := map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+	for i, want := range tests {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
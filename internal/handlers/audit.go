@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"testovoe/internal/models"
+	"testovoe/internal/response"
+	"testovoe/internal/storage"
+	"testovoe/internal/utils"
+	"time"
+)
+
+const DefaultEventsLimit = 50
+
+type AuditQuery struct {
+	SubscriptionID string `validate:"omitempty,number"`
+	UserID         string `validate:"omitempty,uuid"`
+	Actor          string `validate:"max=200"`
+	RequestID      string `validate:"max=200"`
+	Action         string `validate:"omitempty,oneof=create update delete restore"`
+	Changed        string `validate:"omitempty,oneof=service_id service_name price currency user_id start_date end_date billing_period billing_interval_months trial_end category tags metadata notes pauses"`
+	From           string `validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To             string `validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Limit          string `validate:"omitempty,number"`
+	Offset         string `validate:"omitempty,number"`
+}
+
+type EventResponse struct {
+	ID             int64     `json:"id"`
+	SubscriptionID int       `json:"subscription_id"`
+	Action         string    `json:"action"`
+	Actor          *string   `json:"actor,omitempty"`
+	RequestID      *string   `json:"request_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	// Changed lists the fields that differ between Before and After,
+	// besides the version.
+	Changed []string        `json:"changed"`
+	Before  json.RawMessage `json:"before"`
+	After   json.RawMessage `json:"after"`
+}
+
+type EventsResponse struct {
+	Events  []EventResponse `json:"events"`
+	HasMore bool            `json:"has_more"`
+}
+
+func newEventResponse(event *models.SubscriptionEvent) EventResponse {
+	resp := EventResponse{
+		ID:             event.ID,
+		SubscriptionID: event.SubscriptionID,
+		Action:         event.Action,
+		CreatedAt:      event.CreatedAt,
+		Changed:        changedFields(event.Before, event.After),
+		Before:         event.Before,
+		After:          event.After,
+	}
+	if event.Actor.Valid {
+		resp.Actor = utils.String(event.Actor.String)
+	}
+	if event.RequestID.Valid {
+		resp.RequestID = utils.String(event.RequestID.String)
+	}
+	return resp
+}
+
+// changedFields returns the sorted keys of the members that differ between
+// two snapshots. The snapshots come from Postgres in a normal form, so
+// equal members are equal bytes.
+func changedFields(before, after json.RawMessage) []string {
+	var b, a map[string]json.RawMessage
+	// A missing snapshot has no members.
+	_ = json.Unmarshal(before, &b)
+	_ = json.Unmarshal(after, &a)
+
+	changed := []string{}
+	for key, value := range a {
+		if old, ok := b[key]; !ok || !bytes.Equal(old, value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	changed = slices.DeleteFunc(changed, func(key string) bool { return key == "version" })
+	slices.Sort(changed)
+	return changed
+}
+
+// History returns the audit trail of a subscription, newest first: who
+// changed it, when, and how it looked before and after. Deleted and purged
+// subscriptions keep their history. Pages are selected with limit
+// (default 50, at most 1000) and offset.
+func (h *SubscriptionHandler) History(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		slog.ErrorContext(ctx, "parse id", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	query := AuditQuery{
+		Limit:  r.URL.Query().Get("limit"),
+		Offset: r.URL.Query().Get("offset"),
+	}
+	if !h.validateInput(w, r, query) {
+		return
+	}
+	limit, offset, ok := parseLimitOffset(w, query.Limit, query.Offset, DefaultEventsLimit, storage.MaxLimit)
+	if !ok {
+		return
+	}
+
+	events, hasMore, err := h.store.Audit.Events(ctx, models.EventFilter{SubscriptionID: id}, limit, offset)
+	if err != nil {
+		slog.ErrorContext(ctx, "subscription history", "error", err)
+		response.ServerError(w, "Internal server error")
+		return
+	}
+	// Subscriptions created before the audit trail have no events, but
+	// still exist.
+	if len(events) == 0 && offset == 0 {
+		if _, err := h.store.Subscription.Get(ctx, id); err != nil {
+			slog.ErrorContext(ctx, "subscription history", "error", err)
+			if errors.Is(err, storage.ErrNotFound) {
+				response.NotFound(w, "Not found")
+				return
+			}
+			response.ServerError(w, "Internal server error")
+			return
+		}
+	}
+
+	writeEvents(w, events, hasMore)
+}
+
+// Audit returns the audit trail of all subscriptions, newest first.
+// Query parameters:
+//   - subscription_id, user_id, actor, request_id, action: exact filters;
+//     user_id matches the owner before or after the change
+//   - changed: a field of the subscription, like price or end_date, to
+//     select the events that changed it
+//   - from, to: RFC 3339 times bounding the events, both inclusive
+//   - limit (default 50, at most 1000), offset: the page
+func (h *SubscriptionHandler) Audit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	query := AuditQuery{
+		SubscriptionID: q.Get("subscription_id"),
+		UserID:         q.Get("user_id"),
+		Actor:          q.Get("actor"),
+		RequestID:      q.Get("request_id"),
+		Action:         q.Get("action"),
+		Changed:        q.Get("changed"),
+		From:           q.Get("from"),
+		To:             q.Get("to"),
+		Limit:          q.Get("limit"),
+		Offset:         q.Get("offset"),
+	}
+	if !h.validateInput(w, r, query) {
+		return
+	}
+	limit, offset, ok := parseLimitOffset(w, query.Limit, query.Offset, DefaultEventsLimit, storage.MaxLimit)
+	if !ok {
+		return
+	}
+
+	filter := models.EventFilter{
+		UserID:    query.UserID,
+		Actor:     query.Actor,
+		RequestID: query.RequestID,
+		Action:    query.Action,
+		Changed:   query.Changed,
+	}
+	if query.SubscriptionID != "" {
+		id, err := strconv.Atoi(query.SubscriptionID)
+		if err != nil {
+			response.BadRequest(w, "Invalid subscription_id")
+			return
+		}
+		filter.SubscriptionID = id
+	}
+	// Both are validated.
+	if query.From != "" {
+		filter.From, _ = time.Parse(time.RFC3339, query.From)
+	}
+	if query.To != "" {
+		filter.To, _ = time.Parse(time.RFC3339, query.To)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		slog.ErrorContext(ctx, "parse audit period", "error", ErrInvalidPeriod)
+		response.BadRequest(w, "Period end is before period start")
+		return
+	}
+
+	events, hasMore, err := h.store.Audit.Events(ctx, filter, limit, offset)
+	if err != nil {
+		slog.ErrorContext(ctx, "audit", "error", err)
+		if errors.Is(err, storage.ErrInvalidField) {
+			response.BadRequest(w, "Invalid changed")
+			return
+		}
+		response.ServerError(w, "Internal server error")
+		return
+	}
+
+	writeEvents(w, events, hasMore)
+}
+
+func writeEvents(w http.ResponseWriter, events []models.SubscriptionEvent, hasMore bool) {
+	resp := EventsResponse{Events: []EventResponse{}, HasMore: hasMore}
+	for i := range events {
+		resp.Events = append(resp.Events, newEventResponse(&events[i]))
+	}
+	response.Success(w, resp)
+}
@@ -0,0 +1,152 @@
+package handlers_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/currency"
+	"testovoe/internal/handlers"
+	"testovoe/internal/models"
+	"testovoe/internal/storage"
+	mock_storage "testovoe/internal/storage/mocks"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/mock/gomock"
+)
+
+func setupAuditTest(t *testing.T) (*handlers.SubscriptionHandler, *mock_storage.MockAuditStorage, *mock_storage.MockSubscriptionStorage) {
+	t.Helper()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	mockedAuditStorage := mock_storage.NewMockAuditStorage(ctrl)
+	mockedSubscriptionStorage := mock_storage.NewMockSubscriptionStorage(ctrl)
+	handler := handlers.NewSubscriptionHandler(
+		&storage.Storage{Subscription: mockedSubscriptionStorage, Audit: mockedAuditStorage},
+		validator.New(validator.WithRequiredStructEnabled()),
+		currency.NewStaticProvider(currency.Rates{"RUB": 1}),
+	)
+
+	return handler, mockedAuditStorage, mockedSubscriptionStorage
+}
+
+func TestHistory(t *testing.T) {
+	handler, mockedAuditStorage, _ := setupAuditTest(t)
+
+	createdAt := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	mockedAuditStorage.EXPECT().
+		Events(gomock.Any(), models.EventFilter{SubscriptionID: 1}, 50, 0).
+		Return([]models.SubscriptionEvent{{
+			ID:             7,
+			SubscriptionID: 1,
+			Action:         models.EventUpdate,
+			Before:         json.RawMessage(`{"price": 100, "end_date": null, "version": 1}`),
+			After:          json.RawMessage(`{"price": 200, "end_date": "2025-12-31", "version": 2}`),
+			Actor:          sql.NullString{String: "support@example.com", Valid: true},
+			RequestID:      sql.NullString{String: "req-1", Valid: true},
+			CreatedAt:      createdAt,
+		}}, false, nil).
+		Times(1)
+
+	var resp handlers.EventsResponse
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/1/history", nil)
+	w := serve("GET /subscriptions/{id}/history", handler.History, r, &resp)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, 1, len(resp.Events))
+	event := resp.Events[0]
+	assert.Equal(t, "update", event.Action)
+	assert.Equal(t, "support@example.com", *event.Actor)
+	assert.Equal(t, "req-1", *event.RequestID)
+	assert.Equal(t, createdAt, event.CreatedAt)
+	assert.Equal(t, []string{"end_date", "price"}, event.Changed)
+}
+
+func TestHistoryNotFound(t *testing.T) {
+	handler, mockedAuditStorage, mockedSubscriptionStorage := setupAuditTest(t)
+
+	mockedAuditStorage.EXPECT().
+		Events(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, false, nil).
+		Times(2)
+	mockedSubscriptionStorage.EXPECT().
+		Get(gomock.Any(), 1).
+		Return(&models.Subscription{ID: 1}, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Get(gomock.Any(), 2).
+		Return(nil, storage.ErrNotFound).
+		Times(1)
+
+	// A subscription without events has an empty history.
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/1/history", nil)
+	w := serve("GET /subscriptions/{id}/history", handler.History, r, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	r = httptest.NewRequest(http.MethodGet, "/subscriptions/2/history", nil)
+	w = serve("GET /subscriptions/{id}/history", handler.History, r, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAudit(t *testing.T) {
+	handler, mockedAuditStorage, _ := setupAuditTest(t)
+
+	mockedAuditStorage.EXPECT().
+		Events(gomock.Any(), models.EventFilter{
+			SubscriptionID: 1,
+			UserID:         "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+			Actor:          "support@example.com",
+			Action:         models.EventUpdate,
+			Changed:        "price",
+			From:           time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+			To:             time.Date(2025, time.March, 31, 23, 59, 59, 0, time.UTC),
+		}, 10, 20).
+		Return([]models.SubscriptionEvent{{
+			ID:             8,
+			SubscriptionID: 1,
+			Action:         models.EventCreate,
+			After:          json.RawMessage(`{"price": 100, "version": 1}`),
+		}}, true, nil).
+		Times(1)
+
+	var resp handlers.EventsResponse
+	r := httptest.NewRequest(http.MethodGet, "/audit?subscription_id=1&user_id=60601fee-2bf1-4721-ae6f-7636e79a0cba"+
+		"&actor=support@example.com&action=update&changed=price"+
+		"&from=2025-03-01T00:00:00Z&to=2025-03-31T23:59:59Z&limit=10&offset=20", nil)
+	w := serve("GET /audit", handler.Audit, r, &resp)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, true, resp.HasMore)
+	assert.Equal(t, []string{"price"}, resp.Events[0].Changed)
+	assert.Equal(t, "null", string(resp.Events[0].Before))
+}
+
+func TestAuditInvalidQuery(t *testing.T) {
+	handler, mockedAuditStorage, _ := setupAuditTest(t)
+
+	mockedAuditStorage.EXPECT().
+		Events(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	for _, query := range []string{
+		"?subscription_id=one",
+		"?user_id=42",
+		"?action=purge",
+		"?changed=version",
+		"?from=2025-03-01",
+		"?from=2025-03-31T00:00:00Z&to=2025-03-01T00:00:00Z",
+		"?limit=-1",
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/audit"+query, nil)
+		w := serve("GET /audit", handler.Audit, r, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
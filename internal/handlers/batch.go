@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"testovoe/internal/models"
+	"testovoe/internal/response"
+	"testovoe/internal/storage"
+	"testovoe/internal/utils"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	BatchAtomic  = "atomic"
+	BatchPerItem = "per_item"
+)
+
+// errBatchFailed rolls back an atomic batch.
+var errBatchFailed = errors.New("batch operation failed")
+
+type BatchOperation struct {
+	Op string `json:"op" validate:"required,oneof=create update delete"`
+	ID int    `json:"id,omitempty" validate:"required_unless=Op create,excluded_if=Op create"`
+	// Version is the expected version of the subscription for update and
+	// delete, like the If-Match header.
+	Version int `json:"version,omitempty" validate:"omitempty,min=1"`
+	// Data is the CreateSubscriptionPayload or the UpdateSubscriptionPayload.
+	Data json.RawMessage `json:"data,omitempty" validate:"required_unless=Op delete"`
+}
+
+// BatchPayload holds up to 100 operations.
+type BatchPayload struct {
+	Mode       string           `json:"mode,omitempty" validate:"omitempty,oneof=atomic per_item"`
+	Operations []BatchOperation `json:"operations" validate:"required,min=1,max=100,dive"`
+}
+
+// BatchResponse holds the result of every operation, in the order of the
+// operations.
+type BatchResponse struct {
+	Mode    string              `json:"mode"`
+	Results []response.Response `json:"results"`
+}
+
+// batchItem is an operation ready to be applied, or its error result if
+// its data is invalid.
+type batchItem struct {
+	BatchOperation
+	payload CreateSubscriptionPayload
+	sub     *models.Subscription
+	failed  *response.Response
+}
+
+// Batch applies create, update and delete operations in one transaction.
+// In the atomic mode (the default) either all of them are applied or none,
+// and the response status is the one of the failed operation. In the
+// per_item mode the operations that succeed are applied and the others
+// are skipped, and the response status is 200.
+func (h *SubscriptionHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var payload BatchPayload
+	if err := utils.ReadJSON(r, &payload); err != nil {
+		slog.ErrorContext(ctx, "read json", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	if !h.validateInput(w, r, payload) {
+		return
+	}
+	if payload.Mode == "" {
+		payload.Mode = BatchAtomic
+	}
+
+	items := make([]batchItem, len(payload.Operations))
+	results := make([]response.Response, len(payload.Operations))
+	failed := -1
+	for i, op := range payload.Operations {
+		items[i] = h.prepareBatchItem(op)
+		if items[i].failed != nil {
+			results[i] = *items[i].failed
+			if failed < 0 {
+				failed = i
+			}
+		}
+	}
+
+	if payload.Mode == BatchAtomic && failed >= 0 {
+		batchFailed(w, payload.Mode, results, failed)
+		return
+	}
+
+	err := h.store.Subscription.InTx(ctx, func(tx storage.SubscriptionStorage) error {
+		for i, item := range items {
+			if item.failed != nil {
+				continue
+			}
+
+			if payload.Mode == BatchAtomic {
+				var err error
+				results[i], err = applyBatchItem(ctx, tx, item)
+				if err != nil {
+					failed = i
+					return errBatchFailed
+				}
+				continue
+			}
+
+			// Every item gets its own savepoint, so that a failed one
+			// doesn't abort the others.
+			err := tx.InTx(ctx, func(itemTx storage.SubscriptionStorage) error {
+				var err error
+				results[i], err = applyBatchItem(ctx, itemTx, item)
+				return err
+			})
+			if err != nil {
+				slog.ErrorContext(ctx, "apply batch item", "index", i, "error", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "apply batch", "error", err)
+		if errors.Is(err, errBatchFailed) {
+			batchFailed(w, payload.Mode, results, failed)
+			return
+		}
+		response.ServerError(w, "Internal server error")
+		return
+	}
+
+	response.Success(w, BatchResponse{Mode: payload.Mode, Results: results})
+}
+
+// prepareBatchItem decodes and validates the data of an operation.
+func (h *SubscriptionHandler) prepareBatchItem(op BatchOperation) batchItem {
+	item := batchItem{BatchOperation: op}
+	if op.Op == "delete" {
+		return item
+	}
+
+	var payload CreateSubscriptionPayload
+	if err := json.Unmarshal(op.Data, &payload); err != nil {
+		item.failed = &response.Response{Status: http.StatusBadRequest, Message: "Bad request"}
+		return item
+	}
+
+	if err := h.validate.Struct(payload); err != nil {
+		item.failed = &response.Response{Status: http.StatusBadRequest, Message: "Invalid input"}
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			item.failed.Message = "validation error"
+			item.failed.Data = response.ValidationErrors(verrs)
+		}
+		return item
+	}
+
+	sub, err := subscriptionFromPayload(payload)
+	if err != nil {
+		item.failed = &response.Response{Status: http.StatusBadRequest, Message: "Bad request"}
+		if errors.Is(err, ErrTrialBeforeStart) {
+			item.failed.Message = "Trial ends before start date"
+		}
+		return item
+	}
+	sub.ID = op.ID
+	sub.Version = op.Version
+	item.payload = payload
+	item.sub = sub
+	return item
+}
+
+// applyBatchItem applies one operation. The error is only returned to
+// tell a failed operation, its result is always set.
+func applyBatchItem(ctx context.Context, store storage.SubscriptionStorage, item batchItem) (response.Response, error) {
+	var err error
+	switch item.Op {
+	case "create":
+		var id int
+		if id, err = store.Create(ctx, item.sub); err == nil {
+			return response.Response{Status: http.StatusCreated, Message: "success", Data: map[string]any{"id": id}}, nil
+		}
+	case "update":
+		if omitsStoredFields(item.payload) {
+			var stored *models.Subscription
+			if stored, err = store.Get(ctx, item.ID); err != nil {
+				break
+			}
+			if err = keepStoredFields(item.sub, item.payload, stored); err != nil {
+				break
+			}
+		}
+		var updated *models.Subscription
+		if updated, err = store.Update(ctx, item.ID, item.sub); err == nil {
+			return response.Response{Status: http.StatusOK, Message: "success", Data: newSubscriptionResponse(updated)}, nil
+		}
+	case "delete":
+		if err = store.Delete(ctx, item.ID, item.Version); err == nil {
+			return response.Response{Status: http.StatusNoContent, Message: "success"}, nil
+		}
+	}
+
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return response.Response{Status: http.StatusNotFound, Message: "Not found"}, err
+	case errors.Is(err, storage.ErrStaleVersion):
+		return response.Response{Status: http.StatusPreconditionFailed, Message: "Subscription has been modified"}, err
+	case errors.Is(err, storage.ErrServiceNotFound):
+		return response.Response{Status: http.StatusBadRequest, Message: "Service not found"}, err
+	case errors.Is(err, storage.ErrInvalidSubscription):
+		return response.Response{Status: http.StatusBadRequest, Message: "Subscription is invalid"}, err
+	case errors.Is(err, ErrTrialBeforeStart):
+		return response.Response{Status: http.StatusBadRequest, Message: "Trial ends before start date"}, err
+	default:
+		return response.Response{Status: http.StatusInternalServerError, Message: "Internal server error"}, err
+	}
+}
+
+// batchFailed responds to an atomic batch that is rolled back because of
+// the failed operation. Every other operation gets 424 Failed Dependency.
+func batchFailed(w http.ResponseWriter, mode string, results []response.Response, failed int) {
+	status := results[failed].Status
+	for i := range results {
+		if i != failed && results[i].Status < http.StatusBadRequest {
+			results[i] = response.Response{Status: http.StatusFailedDependency, Message: "Not applied"}
+		}
+	}
+	response.Write(w, response.Response{
+		Status:  status,
+		Message: "Batch is not applied",
+		Data:    BatchResponse{Mode: mode, Results: results},
+	})
+}
@@ -0,0 +1,184 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testovoe/internal/handlers"
+	"testovoe/internal/models"
+	"testovoe/internal/response"
+	"testovoe/internal/storage"
+	mock_storage "testovoe/internal/storage/mocks"
+
+	"github.com/go-playground/assert/v2"
+	"go.uber.org/mock/gomock"
+)
+
+// expectInTx runs the functions passed to InTx with the mock itself.
+func expectInTx(mockedSubscriptionStorage *mock_storage.MockSubscriptionStorage) {
+	mockedSubscriptionStorage.EXPECT().
+		InTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(storage.SubscriptionStorage) error) error {
+			return fn(mockedSubscriptionStorage)
+		}).
+		AnyTimes()
+}
+
+func statuses(results []response.Response) []int {
+	var out []int
+	for _, result := range results {
+		out = append(out, result.Status)
+	}
+	return out
+}
+
+const batchBody = `{
+	"mode": "%s",
+	"operations": [
+		{"op": "create", "data": {"service_name": "Netflix", "price": 400, "user_id": "60601fee-2bf1-4721-ae6f-7636e79a0cba", "start_date": "07-2025"}},
+		{"op": "update", "id": 1, "version": 2, "data": {"service_name": "Spotify", "price": 300, "user_id": "60601fee-2bf1-4721-ae6f-7636e79a0cba", "start_date": "07-2025"}},
+		{"op": "delete", "id": 2}
+	]
+}`
+
+func TestBatchAtomic(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+	expectInTx(mockedSubscriptionStorage)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Return(7, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Get(gomock.Any(), 1).
+		Return(&models.Subscription{ID: 1, Currency: "RUB", BillingPeriod: models.BillingMonthly}, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Update(gomock.Any(), 1, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ int, sub *models.Subscription) (*models.Subscription, error) {
+			assert.Equal(t, 2, sub.Version)
+			sub.Version = 3
+			return sub, nil
+		}).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Delete(gomock.Any(), 2, 0).
+		Return(nil).
+		Times(1)
+
+	var resp handlers.BatchResponse
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions:batch", strings.NewReader(strings.Replace(batchBody, "%s", "atomic", 1)))
+	w := serve("POST /subscriptions:batch", handler.Batch, r, &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []int{http.StatusCreated, http.StatusOK, http.StatusNoContent}, statuses(resp.Results))
+}
+
+func TestBatchAtomicRollsBack(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+	expectInTx(mockedSubscriptionStorage)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Return(7, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Get(gomock.Any(), 1).
+		Return(&models.Subscription{ID: 1, Currency: "RUB", BillingPeriod: models.BillingMonthly}, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Update(gomock.Any(), 1, gomock.Any()).
+		Return(nil, storage.ErrStaleVersion).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Delete(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	var resp handlers.BatchResponse
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions:batch", strings.NewReader(strings.Replace(batchBody, "%s", "atomic", 1)))
+	w := serve("POST /subscriptions:batch", handler.Batch, r, &resp)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.Equal(t, []int{http.StatusFailedDependency, http.StatusPreconditionFailed, http.StatusFailedDependency}, statuses(resp.Results))
+}
+
+func TestBatchPerItem(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+	expectInTx(mockedSubscriptionStorage)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Return(7, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Get(gomock.Any(), 1).
+		Return(&models.Subscription{ID: 1, Currency: "RUB", BillingPeriod: models.BillingMonthly}, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Update(gomock.Any(), 1, gomock.Any()).
+		Return(nil, storage.ErrNotFound).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Delete(gomock.Any(), 2, 0).
+		Return(nil).
+		Times(1)
+
+	var resp handlers.BatchResponse
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions:batch", strings.NewReader(strings.Replace(batchBody, "%s", "per_item", 1)))
+	w := serve("POST /subscriptions:batch", handler.Batch, r, &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []int{http.StatusCreated, http.StatusNotFound, http.StatusNoContent}, statuses(resp.Results))
+}
+
+func TestBatchInvalidItem(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		InTx(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	body := `{
+		"operations": [
+			{"op": "create", "data": {"service_name": "Netflix", "price": 400, "user_id": "60601fee-2bf1-4721-ae6f-7636e79a0cba", "start_date": "2025/07"}},
+			{"op": "delete", "id": 2}
+		]
+	}`
+	var resp handlers.BatchResponse
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions:batch", strings.NewReader(body))
+	w := serve("POST /subscriptions:batch", handler.Batch, r, &resp)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, handlers.BatchAtomic, resp.Mode)
+	assert.Equal(t, []int{http.StatusBadRequest, http.StatusFailedDependency}, statuses(resp.Results))
+	assert.Equal(t, "validation error", resp.Results[0].Message)
+	assert.NotEqual(t, nil, resp.Results[0].Data)
+}
+
+func TestBatchInvalidOperation(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		InTx(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions:batch", strings.NewReader(`{"operations": [{"op": "upsert", "id": 1}]}`))
+	w := serve("POST /subscriptions:batch", handler.Batch, r, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestBatchEmpty(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		InTx(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions:batch", strings.NewReader(`{"operations": []}`))
+	w := serve("POST /subscriptions:batch", handler.Batch, r, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testovoe/internal/models"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// listCursor is the position of a page of List: the page starts after the
+// subscription with these values of the sortable columns. It only applies
+// to the sort it was made for. Clients get it as an opaque string and must
+// not build it themselves.
+type listCursor struct {
+	Sort        string `json:"sort,omitempty"`
+	ID          int    `json:"id"`
+	Price       int    `json:"price,omitempty"`
+	StartDate   string `json:"start_date,omitempty"`
+	ServiceName string `json:"service_name,omitempty"`
+}
+
+// encodeCursor returns the cursor of the page after sub.
+func encodeCursor(sort string, sub *models.Subscription) string {
+	data, _ := json.Marshal(listCursor{
+		Sort:        sort,
+		ID:          sub.ID,
+		Price:       sub.Price,
+		StartDate:   sub.StartDate.Format(time.DateOnly),
+		ServiceName: sub.ServiceName,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns the subscription a cursor made for sort points
+// after.
+func decodeCursor(s, sort string) (*models.Subscription, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID <= 0 || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+
+	sub := &models.Subscription{ID: c.ID, Price: c.Price, ServiceName: c.ServiceName}
+	if c.StartDate != "" {
+		if sub.StartDate, err = time.Parse(time.DateOnly, c.StartDate); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return sub, nil
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testovoe/internal/response"
+)
+
+// etag formats the version of a subscription as a strong entity tag.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseETag is the inverse of etag. It doesn't accept weak tags.
+func parseETag(tag string) (int, bool) {
+	tag = strings.TrimSpace(tag)
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// ifMatch returns the version the If-Match header expects, zero if the
+// header is missing or "*". A header that can't match any version, like a
+// weak or a foreign tag, fails the request with 412.
+func ifMatch(w http.ResponseWriter, r *http.Request) (int, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	version, ok := parseETag(header)
+	if !ok {
+		response.PreconditionFailed(w, "Precondition failed")
+		return 0, false
+	}
+	return version, true
+}
+
+// notModified reports whether the If-None-Match header holds the tag of
+// the version. Unlike If-Match, it uses the weak comparison.
+func notModified(r *http.Request, version int) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if v, ok := parseETag(strings.TrimPrefix(tag, "W/")); ok && v == version {
+			return true
+		}
+	}
+	return false
+}
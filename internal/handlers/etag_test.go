@@ -0,0 +1,198 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testovoe/internal/models"
+	"testovoe/internal/storage"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+const updateBody = `{
+	"service_name": "test",
+	"start_date": "01-2006",
+	"price": 100,
+	"user_id": "550e8400-e29b-41d4-a716-446655440000"
+}`
+
+func versionedSubscription(version int) *models.Subscription {
+	return &models.Subscription{
+		ID:            1,
+		ServiceName:   "test",
+		Price:         100,
+		Currency:      "RUB",
+		UserID:        uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+		StartDate:     time.Date(2006, time.January, 1, 0, 0, 0, 0, time.UTC),
+		BillingPeriod: models.BillingMonthly,
+		Version:       version,
+	}
+}
+
+func TestGetETag(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Get(gomock.Any(), 1).
+		Return(versionedSubscription(3), nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/1", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions/{id}", handler.Get)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"etag":"\"3\""`))
+}
+
+func TestGetIfNoneMatch(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Get(gomock.Any(), 1).
+		Return(versionedSubscription(3), nil).
+		Times(2)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions/{id}", handler.Get)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/1", nil)
+	r.Header.Set("If-None-Match", `"2", W/"3"`)
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, 0, w.Body.Len())
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/subscriptions/1", nil)
+	r.Header.Set("If-None-Match", `"2"`)
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestUpdateIfMatch(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Get(gomock.Any(), 1).
+		Return(&models.Subscription{ID: 1, Currency: "RUB", BillingPeriod: models.BillingMonthly}, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Update(gomock.Any(), 1, gomock.Any()).
+		DoAndReturn(func(_ any, _ int, sub *models.Subscription) (*models.Subscription, error) {
+			assert.Equal(t, 3, sub.Version)
+			return &models.Subscription{ID: 1, Version: 4}, nil
+		}).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, "/subscriptions/1", strings.NewReader(updateBody))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("If-Match", `"3"`)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /subscriptions/{id}", handler.Update)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+}
+
+func TestUpdateStaleVersion(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Get(gomock.Any(), 1).
+		Return(&models.Subscription{ID: 1, Currency: "RUB", BillingPeriod: models.BillingMonthly}, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Update(gomock.Any(), 1, gomock.Any()).
+		Return(nil, storage.ErrStaleVersion).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, "/subscriptions/1", strings.NewReader(updateBody))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("If-Match", `"2"`)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /subscriptions/{id}", handler.Update)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+}
+
+func TestUpdateWeakIfMatch(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Update(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, "/subscriptions/1", strings.NewReader(updateBody))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("If-Match", `W/"3"`)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /subscriptions/{id}", handler.Update)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+}
+
+func TestPatchIfMatch(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Patch(gomock.Any(), 1, 3, gomock.Any()).
+		Return(versionedSubscription(4), nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPatch, "/subscriptions/1", strings.NewReader(`{"price": 100}`))
+	r.Header.Set("If-Match", `"3"`)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PATCH /subscriptions/{id}", handler.Patch)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+}
+
+func TestDeleteStaleVersion(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Delete(gomock.Any(), 1, 2).
+		Return(storage.ErrStaleVersion).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "/subscriptions/1", nil)
+	r.Header.Set("If-Match", `"2"`)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /subscriptions/{id}", handler.Delete)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+}
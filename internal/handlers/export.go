@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"testovoe/internal/export"
+	"testovoe/internal/models"
+	"testovoe/internal/response"
+	"time"
+)
+
+// Export formats.
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+	ExportXLSX   = "xlsx"
+)
+
+var exportContentTypes = map[string]string{
+	ExportCSV:    "text/csv",
+	ExportNDJSON: "application/x-ndjson",
+	ExportXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// exportColumns are the columns of the CSV and XLSX exports, the fields of
+// SubscriptionResponse.
+var exportColumns = []any{
+	"id",
+	"service_id",
+	"service_name",
+	"price",
+	"currency",
+	"user_id",
+	"start_date",
+	"end_date",
+	"billing_period",
+	"billing_interval_months",
+	"trial_end",
+	"in_trial",
+	"paused",
+	"category",
+	"tags",
+	"notes",
+	"metadata",
+}
+
+type ExportQuery struct {
+	Format string `validate:"omitempty,oneof=csv ndjson xlsx"`
+	UserID string `validate:"omitempty,uuid"`
+}
+
+// Export streams all the subscriptions matching the filters of List, in id
+// order, as CSV, NDJSON or XLSX. The format is taken from the format query
+// parameter or else negotiated with the Accept header, CSV being the
+// default. Unlike List there is no limit: the rows are written as they are
+// read from the database.
+func (h *SubscriptionHandler) Export(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := ExportQuery{
+		Format: r.URL.Query().Get("format"),
+		UserID: r.URL.Query().Get("user_id"),
+	}
+	if !h.validateInput(w, r, query) {
+		return
+	}
+	filter, ok := h.readListFilter(w, r)
+	if !ok {
+		return
+	}
+
+	format := query.Format
+	if format == "" {
+		if format, ok = negotiateExport(r.Header.Get("Accept")); !ok {
+			response.Write(w, response.Response{Status: http.StatusNotAcceptable, Message: "Not acceptable"})
+			return
+		}
+	}
+
+	// The export may take longer than the write timeout of the server.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.WarnContext(ctx, "clear write deadline", "error", err)
+	}
+
+	// The response starts with the first row, so that an error of the
+	// query can still be answered with a proper status.
+	var write func(sub *models.Subscription) error
+	var closeExport func() error
+	start := func() error {
+		w.Header().Set("Content-Type", exportContentTypes[format])
+		w.Header().Set("Content-Disposition", `attachment; filename="subscriptions.`+format+`"`)
+		w.WriteHeader(http.StatusOK)
+
+		if format == ExportNDJSON {
+			enc := json.NewEncoder(w)
+			write = func(sub *models.Subscription) error {
+				return enc.Encode(newSubscriptionResponse(sub))
+			}
+			closeExport = func() error { return nil }
+			return nil
+		}
+
+		var out export.Writer
+		if format == ExportXLSX {
+			var err error
+			if out, err = export.NewXLSX(w, "Subscriptions"); err != nil {
+				return err
+			}
+		} else {
+			out = export.NewCSV(w)
+		}
+		write = func(sub *models.Subscription) error {
+			return out.Write(exportRow(newSubscriptionResponse(sub)))
+		}
+		closeExport = out.Close
+		return out.Write(exportColumns)
+	}
+
+	err := h.store.Subscription.Export(ctx, filter, func(sub *models.Subscription) error {
+		if write == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return write(sub)
+	})
+	if err == nil && write == nil {
+		err = start()
+	}
+	if err == nil {
+		err = closeExport()
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "export subscriptions", "error", err, "format", format)
+		if write == nil {
+			response.ServerError(w, "Internal server error")
+			return
+		}
+		// The status is already sent: break the connection, so that the
+		// client doesn't take a truncated file for a complete one.
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// negotiateExport picks the export format from an Accept header.
+func negotiateExport(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return ExportCSV, true
+	}
+
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+
+		switch mediaType {
+		case "*/*", "text/*":
+			best, bestQ = ExportCSV, q
+		default:
+			for format, contentType := range exportContentTypes {
+				if mediaType == contentType {
+					best, bestQ = format, q
+				}
+			}
+		}
+	}
+	return best, best != ""
+}
+
+// exportRow returns the cells of a subscription in the order of
+// exportColumns.
+func exportRow(sub SubscriptionResponse) []any {
+	row := []any{
+		sub.ID,
+		nil,
+		sub.ServiceName,
+		sub.Price,
+		sub.Currency,
+		sub.UserID.String(),
+		sub.StartDate,
+		nil,
+		string(sub.BillingPeriod),
+		nil,
+		nil,
+		sub.InTrial,
+		sub.Paused,
+		nil,
+		strings.Join(sub.Tags, ","),
+		nil,
+		nil,
+	}
+	if sub.ServiceID != nil {
+		row[1] = *sub.ServiceID
+	}
+	if sub.EndDate != nil {
+		row[7] = *sub.EndDate
+	}
+	if sub.BillingIntervalMonths != nil {
+		row[9] = *sub.BillingIntervalMonths
+	}
+	if sub.TrialEnd != nil {
+		row[10] = *sub.TrialEnd
+	}
+	if sub.Category != nil {
+		row[13] = *sub.Category
+	}
+	if sub.Notes != nil {
+		row[15] = *sub.Notes
+	}
+	if string(sub.Metadata) != "{}" {
+		row[16] = string(sub.Metadata)
+	}
+	return row
+}
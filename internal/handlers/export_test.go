@@ -0,0 +1,183 @@
+package handlers_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testovoe/internal/handlers"
+	"testovoe/internal/models"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+// exportSubscriptions makes the mock export subs.
+func exportSubscriptions(subs ...models.Subscription) func(context.Context, models.SubscriptionFilter, func(*models.Subscription) error) error {
+	return func(_ context.Context, _ models.SubscriptionFilter, fn func(*models.Subscription) error) error {
+		for _, sub := range subs {
+			if err := fn(&sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+var exportedSubscriptions = []models.Subscription{
+	{
+		ID:            1,
+		ServiceName:   "Yandex, Plus",
+		Price:         300,
+		Currency:      "RUB",
+		UserID:        uuid.MustParse("60601fee-2bf1-4721-ae6f-7636e79a0cba"),
+		StartDate:     time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:       sql.NullTime{Time: time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC), Valid: true},
+		BillingPeriod: models.BillingMonthly,
+		Category:      sql.NullString{String: "music", Valid: true},
+		Tags:          []string{"family", "shared"},
+		Metadata:      json.RawMessage(`{"app_store_id":"123"}`),
+		Version:       1,
+	},
+	{
+		ID:            2,
+		ServiceID:     sql.NullInt32{Int32: 5, Valid: true},
+		ServiceName:   "Netflix",
+		Price:         400,
+		Currency:      "RUB",
+		UserID:        uuid.MustParse("60601fee-2bf1-4721-ae6f-7636e79a0cba"),
+		StartDate:     time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+		BillingPeriod: models.BillingCustom,
+
+		BillingIntervalMonths: sql.NullInt32{Int32: 3, Valid: true},
+		Version:               1,
+	},
+}
+
+func TestExportCSV(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Export(gomock.Any(), models.SubscriptionFilter{UserID: "60601fee-2bf1-4721-ae6f-7636e79a0cba", ServiceName: "Netflix"}, gomock.Any()).
+		DoAndReturn(exportSubscriptions(exportedSubscriptions...)).
+		Times(1)
+
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/export?user_id=60601fee-2bf1-4721-ae6f-7636e79a0cba&service_name=Netflix", nil)
+	w := serve("GET /subscriptions/export", handler.Export, r, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="subscriptions.csv"`, w.Header().Get("Content-Disposition"))
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Equal(t, 3, len(lines))
+	assert.Equal(t, "id,service_id,service_name,price,currency,user_id,start_date,end_date,billing_period,billing_interval_months,trial_end,in_trial,paused,category,tags,notes,metadata", lines[0])
+	assert.Equal(t, `1,,"Yandex, Plus",300,RUB,60601fee-2bf1-4721-ae6f-7636e79a0cba,07-2025,12-2025,monthly,,,false,false,music,"family,shared",,"{""app_store_id"":""123""}"`, lines[1])
+	assert.Equal(t, "2,5,Netflix,400,RUB,60601fee-2bf1-4721-ae6f-7636e79a0cba,07-2025,,custom,3,,false,false,,,,", lines[2])
+}
+
+func TestExportNDJSON(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Export(gomock.Any(), models.SubscriptionFilter{}, gomock.Any()).
+		DoAndReturn(exportSubscriptions(exportedSubscriptions...)).
+		Times(1)
+
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/export", nil)
+	r.Header.Set("Accept", "application/json;q=0.9, application/x-ndjson")
+	w := serve("GET /subscriptions/export", handler.Export, r, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	var sub handlers.SubscriptionResponse
+	assert.Equal(t, nil, json.Unmarshal([]byte(lines[1]), &sub))
+	assert.Equal(t, 2, sub.ID)
+	assert.Equal(t, 3, *sub.BillingIntervalMonths)
+}
+
+func TestExportXLSX(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Export(gomock.Any(), models.SubscriptionFilter{}, gomock.Any()).
+		DoAndReturn(exportSubscriptions(exportedSubscriptions...)).
+		Times(1)
+
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/export?format=xlsx", nil)
+	r.Header.Set("Accept", "text/csv")
+	w := serve("GET /subscriptions/export", handler.Export, r, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", w.Header().Get("Content-Type"))
+	// A zip archive.
+	assert.Equal(t, true, strings.HasPrefix(w.Body.String(), "PK"))
+}
+
+func TestExportEmpty(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Export(gomock.Any(), models.SubscriptionFilter{}, gomock.Any()).
+		DoAndReturn(exportSubscriptions()).
+		Times(1)
+
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/export?format=csv", nil)
+	w := serve("GET /subscriptions/export", handler.Export, r, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, strings.Count(w.Body.String(), "\n"))
+}
+
+func TestExportError(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Export(gomock.Any(), models.SubscriptionFilter{}, gomock.Any()).
+		Return(errors.New("connection refused")).
+		Times(1)
+
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/export", nil)
+	w := serve("GET /subscriptions/export", handler.Export, r, nil)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestExportNotAcceptable(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Export(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/export", nil)
+	r.Header.Set("Accept", "application/json")
+	w := serve("GET /subscriptions/export", handler.Export, r, nil)
+
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+}
+
+func TestExportInvalidQuery(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Export(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/export?format=pdf", nil)
+	w := serve("GET /subscriptions/export", handler.Export, r, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	r = httptest.NewRequest(http.MethodGet, "/subscriptions/export?user_id=42", nil)
+	w = serve("GET /subscriptions/export", handler.Export, r, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
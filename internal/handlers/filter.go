@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testovoe/internal/models"
+	"testovoe/internal/response"
+	"testovoe/internal/storage"
+	"testovoe/internal/utils"
+)
+
+// ListQuery holds the query parameters filtering List and Export, besides
+// user_id and service_name.
+type ListQuery struct {
+	ServiceID         string `validate:"omitempty,number"`
+	ServiceNamePrefix string
+	Category          string   `validate:"omitempty,oneof=streaming music gaming cloud software news education fitness other"`
+	Tags              []string `validate:"max=20,dive,required,max=50"`
+	Metadata          []string `validate:"max=20,dive,required,max=300"`
+	PriceMin          string   `validate:"omitempty,number"`
+	PriceMax          string   `validate:"omitempty,number"`
+	ActiveAt          string   `validate:"omitempty,mm_yyyy_or_date"`
+	StartedAfter      string   `validate:"omitempty,mm_yyyy_or_date"`
+	EndedBefore       string   `validate:"omitempty,mm_yyyy_or_date"`
+	Status            string   `validate:"omitempty,oneof=active ended"`
+}
+
+// readListFilter reads and validates the filters of List and Export. A
+// month selects its whole length: active_at=03-2025 is any day of March,
+// started_after=03-2025 is from April on and ended_before=03-2025 is
+// until February. tag may be repeated to select the subscriptions having
+// all the tags, and so may metadata, which is either "key:value" for a
+// member with this string value or "key" for a member with any value.
+func (h *SubscriptionHandler) readListFilter(w http.ResponseWriter, r *http.Request) (models.SubscriptionFilter, bool) {
+	q := r.URL.Query()
+	query := ListQuery{
+		ServiceID:         q.Get("service_id"),
+		ServiceNamePrefix: q.Get("service_name_prefix"),
+		Category:          q.Get("category"),
+		Tags:              q["tag"],
+		Metadata:          q["metadata"],
+		PriceMin:          q.Get("price_min"),
+		PriceMax:          q.Get("price_max"),
+		ActiveAt:          q.Get("active_at"),
+		StartedAfter:      q.Get("started_after"),
+		EndedBefore:       q.Get("ended_before"),
+		Status:            q.Get("status"),
+	}
+	if !h.validateInput(w, r, query) {
+		return models.SubscriptionFilter{}, false
+	}
+
+	filter := models.SubscriptionFilter{
+		UserID:            q.Get("user_id"),
+		ServiceName:       q.Get("service_name"),
+		ServiceNamePrefix: query.ServiceNamePrefix,
+		Category:          query.Category,
+		Status:            query.Status,
+	}
+	if len(query.Tags) > 0 {
+		filter.Tags = normalizeTags(query.Tags)
+	}
+	for _, member := range query.Metadata {
+		key, value, ok := strings.Cut(member, ":")
+		if !ok {
+			filter.MetadataKeys = append(filter.MetadataKeys, key)
+			continue
+		}
+		if filter.Metadata == nil {
+			filter.Metadata = map[string]string{}
+		}
+		filter.Metadata[key] = value
+	}
+	var err error
+	if query.ServiceID != "" {
+		if filter.ServiceID, err = strconv.Atoi(query.ServiceID); err != nil {
+			response.BadRequest(w, "Invalid service_id")
+			return models.SubscriptionFilter{}, false
+		}
+	}
+	if query.PriceMin != "" {
+		if filter.PriceMin, err = parsePrice(query.PriceMin); err != nil {
+			response.BadRequest(w, "Invalid price_min")
+			return models.SubscriptionFilter{}, false
+		}
+	}
+	if query.PriceMax != "" {
+		if filter.PriceMax, err = parsePrice(query.PriceMax); err != nil {
+			response.BadRequest(w, "Invalid price_max")
+			return models.SubscriptionFilter{}, false
+		}
+	}
+	// The dates are validated already.
+	if query.ActiveAt != "" {
+		filter.ActiveFrom, _ = utils.ParseStartDate(query.ActiveAt)
+		filter.ActiveTo, _ = utils.ParseEndDate(query.ActiveAt)
+	}
+	if query.StartedAfter != "" {
+		filter.StartedAfter, _ = utils.ParseEndDate(query.StartedAfter)
+	}
+	if query.EndedBefore != "" {
+		filter.EndedBefore, _ = utils.ParseStartDate(query.EndedBefore)
+	}
+	return filter, true
+}
+
+// parsePrice parses a price validated as a number, which may still
+// overflow an int.
+func parsePrice(s string) (*int, error) {
+	price, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+// parseSort parses a comma separated list of storage.SortColumns, each
+// descending if prefixed with "-", like "price,-start_date".
+func parseSort(s string) ([]models.SortKey, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var keys []models.SortKey
+	for _, field := range strings.Split(s, ",") {
+		key := models.SortKey{Column: strings.TrimSpace(field)}
+		if column, ok := strings.CutPrefix(key.Column, "-"); ok {
+			key = models.SortKey{Column: column, Desc: true}
+		}
+		if !slices.Contains(storage.SortColumns, key.Column) {
+			return nil, fmt.Errorf("unknown sort column %q", key.Column)
+		}
+		if slices.ContainsFunc(keys, func(k models.SortKey) bool { return k.Column == key.Column }) {
+			return nil, fmt.Errorf("duplicate sort column %q", key.Column)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
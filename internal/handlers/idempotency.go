@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testovoe/internal/audit"
+	"testovoe/internal/models"
+	"testovoe/internal/response"
+	"testovoe/internal/storage"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// maxIdempotencyKeyLen bounds the keys clients may send.
+	maxIdempotencyKeyLen = 255
+)
+
+// replayedHeaders are the response headers stored with the body and sent
+// again to the retries.
+var replayedHeaders = []string{"Location", "ETag"}
+
+// Idempotency makes retries of a request with the same Idempotency-Key
+// header safe: the request is handled once and its response is replayed
+// to the retries.
+type Idempotency struct {
+	store storage.IdempotencyStorage
+	ttl   time.Duration
+}
+
+func NewIdempotency(store storage.IdempotencyStorage, ttl time.Duration) *Idempotency {
+	return &Idempotency{store: store, ttl: ttl}
+}
+
+// recordingWriter keeps a copy of the response sent to the client.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// idempotencyClient identifies the client the keys of a request belong
+// to: its actor (see audit.Actor), or else its remote address.
+func idempotencyClient(r *http.Request) string {
+	if actor := audit.Actor(r.Context()); actor != "" {
+		return "actor:" + actor
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+// requestHash identifies a request by its method, path and body.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Wrap handles requests without the header as usual. For a new key it
+// calls next and stores its response, unless it is a server error that is
+// worth retrying or next panics. A completed key replays the stored
+// response, a key used with another request fails with 422 and a key
+// whose first request is still running fails with 409. Keys are scoped by
+// client, see idempotencyClient.
+func (i *Idempotency) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			response.BadRequest(w, "Idempotency-Key is too long")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			slog.ErrorContext(ctx, "read body", "error", err)
+			response.BadRequest(w, "Bad request")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		client := idempotencyClient(r)
+		stored, err := i.store.Reserve(ctx, client, key, requestHash(r, body), i.ttl)
+		if err != nil {
+			slog.ErrorContext(ctx, "reserve idempotency key", "error", err)
+			switch {
+			case errors.Is(err, storage.ErrIdempotencyKeyReused):
+				response.UnprocessableEntity(w, "Idempotency-Key is already used with another request")
+			case errors.Is(err, storage.ErrIdempotencyKeyInProgress):
+				response.Conflict(w, "Request with this Idempotency-Key is in progress")
+			default:
+				response.ServerError(w, "Internal server error")
+			}
+			return
+		}
+
+		if stored != nil {
+			slog.InfoContext(ctx, "replay idempotent response", "key", key)
+			for name, values := range stored.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		// The key is released or completed once the request is handled, so
+		// its context may already be done.
+		ctx = context.WithoutCancel(ctx)
+		release := func() {
+			if err := i.store.Release(ctx, client, key); err != nil {
+				slog.ErrorContext(ctx, "release idempotency key", "error", err)
+			}
+		}
+		// A panic, e.g. http.ErrAbortHandler, must not leave the key
+		// reserved until it expires.
+		defer func() {
+			if p := recover(); p != nil {
+				release()
+				panic(p)
+			}
+		}()
+
+		rec := &recordingWriter{ResponseWriter: w}
+		next(rec, r)
+
+		if rec.status >= http.StatusInternalServerError {
+			release()
+			return
+		}
+		header := http.Header{}
+		for _, name := range replayedHeaders {
+			if values := w.Header().Values(name); len(values) > 0 {
+				header[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+		err = i.store.Complete(ctx, client, key, models.IdempotentResponse{
+			Status: rec.status,
+			Header: header,
+			Body:   rec.body.Bytes(),
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "complete idempotency key", "error", err)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package handlers_test
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testovoe/internal/audit"
+	"testovoe/internal/handlers"
+	"testovoe/internal/models"
+	"testovoe/internal/storage"
+	mock_storage "testovoe/internal/storage/mocks"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"go.uber.org/mock/gomock"
+)
+
+const createBody = `{
+	"service_name": "Netflix",
+	"price": 400,
+	"user_id": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+	"start_date": "07-2025"
+}`
+
+func setupIdempotencyTest(t *testing.T) (http.Handler, *mock_storage.MockSubscriptionStorage, *mock_storage.MockIdempotencyStorage) {
+	t.Helper()
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedIdempotencyStorage := mock_storage.NewMockIdempotencyStorage(gomock.NewController(t))
+	idempotency := handlers.NewIdempotency(mockedIdempotencyStorage, time.Hour)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subscriptions", idempotency.Wrap(handler.Create))
+	return mux, mockedSubscriptionStorage, mockedIdempotencyStorage
+}
+
+func postWithKey(mux http.Handler, key, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		r.Header.Set(handlers.IdempotencyKeyHeader, key)
+	}
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotentCreateStoresResponse(t *testing.T) {
+	mux, mockedSubscriptionStorage, mockedIdempotencyStorage := setupIdempotencyTest(t)
+
+	mockedIdempotencyStorage.EXPECT().
+		Reserve(gomock.Any(), gomock.Any(), "key-1", gomock.Any(), time.Hour).
+		Return(nil, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Return(7, nil).
+		Times(1)
+
+	var stored models.IdempotentResponse
+	mockedIdempotencyStorage.EXPECT().
+		Complete(gomock.Any(), gomock.Any(), "key-1", gomock.Any()).
+		DoAndReturn(func(_ any, _, _ string, resp models.IdempotentResponse) error {
+			stored = resp
+			return nil
+		}).
+		Times(1)
+
+	w := postWithKey(mux, "key-1", createBody)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, http.StatusCreated, stored.Status)
+	assert.Equal(t, w.Body.String(), string(stored.Body))
+}
+
+func TestIdempotentCreateReplays(t *testing.T) {
+	mux, mockedSubscriptionStorage, mockedIdempotencyStorage := setupIdempotencyTest(t)
+
+	body := `{"status":201,"message":"created","data":{"id":7}}`
+	mockedIdempotencyStorage.EXPECT().
+		Reserve(gomock.Any(), gomock.Any(), "key-1", gomock.Any(), time.Hour).
+		Return(&models.IdempotentResponse{
+			Status: http.StatusCreated,
+			Header: http.Header{"Etag": {`"1"`}},
+			Body:   []byte(body),
+		}, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	w := postWithKey(mux, "key-1", createBody)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, body, w.Body.String())
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+}
+
+func TestIdempotentCreateSameHash(t *testing.T) {
+	mux, mockedSubscriptionStorage, mockedIdempotencyStorage := setupIdempotencyTest(t)
+
+	var hashes []string
+	mockedIdempotencyStorage.EXPECT().
+		Reserve(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, _, _, hash string, _ time.Duration) (*models.IdempotentResponse, error) {
+			hashes = append(hashes, hash)
+			return nil, storage.ErrIdempotencyKeyInProgress
+		}).
+		Times(3)
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	postWithKey(mux, "key-1", createBody)
+	postWithKey(mux, "key-1", createBody)
+	postWithKey(mux, "key-1", `{"service_name": "Spotify"}`)
+
+	assert.Equal(t, hashes[0], hashes[1])
+	assert.NotEqual(t, hashes[0], hashes[2])
+}
+
+func TestIdempotentCreateReusedKey(t *testing.T) {
+	mux, mockedSubscriptionStorage, mockedIdempotencyStorage := setupIdempotencyTest(t)
+
+	mockedIdempotencyStorage.EXPECT().
+		Reserve(gomock.Any(), gomock.Any(), "key-1", gomock.Any(), time.Hour).
+		Return(nil, storage.ErrIdempotencyKeyReused).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	w := postWithKey(mux, "key-1", createBody)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestIdempotentCreateInProgress(t *testing.T) {
+	mux, _, mockedIdempotencyStorage := setupIdempotencyTest(t)
+
+	mockedIdempotencyStorage.EXPECT().
+		Reserve(gomock.Any(), gomock.Any(), "key-1", gomock.Any(), time.Hour).
+		Return(nil, storage.ErrIdempotencyKeyInProgress).
+		Times(1)
+
+	w := postWithKey(mux, "key-1", createBody)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestIdempotentCreateReleasesOnServerError(t *testing.T) {
+	mux, mockedSubscriptionStorage, mockedIdempotencyStorage := setupIdempotencyTest(t)
+
+	mockedIdempotencyStorage.EXPECT().
+		Reserve(gomock.Any(), gomock.Any(), "key-1", gomock.Any(), time.Hour).
+		Return(nil, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Return(0, errors.New("db is down")).
+		Times(1)
+	mockedIdempotencyStorage.EXPECT().
+		Release(gomock.Any(), gomock.Any(), "key-1").
+		Return(nil).
+		Times(1)
+	mockedIdempotencyStorage.EXPECT().
+		Complete(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	w := postWithKey(mux, "key-1", createBody)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestCreateWithoutIdempotencyKey(t *testing.T) {
+	mux, mockedSubscriptionStorage, mockedIdempotencyStorage := setupIdempotencyTest(t)
+
+	mockedIdempotencyStorage.EXPECT().
+		Reserve(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Return(7, nil).
+		Times(1)
+
+	w := postWithKey(mux, "", createBody)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestIdempotencyKeysAreScopedByClient(t *testing.T) {
+	mux, _, mockedIdempotencyStorage := setupIdempotencyTest(t)
+
+	var clients []string
+	mockedIdempotencyStorage.EXPECT().
+		Reserve(gomock.Any(), gomock.Any(), "key-1", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, client, _, _ string, _ time.Duration) (*models.IdempotentResponse, error) {
+			clients = append(clients, client)
+			return nil, storage.ErrIdempotencyKeyInProgress
+		}).
+		Times(3)
+
+	for _, actor := range []string{"support", "billing", ""} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(createBody))
+		r.Header.Set(handlers.IdempotencyKeyHeader, "key-1")
+		if actor != "" {
+			r = r.WithContext(audit.WithActor(r.Context(), actor))
+		}
+		mux.ServeHTTP(w, r)
+	}
+
+	// Without an actor the key belongs to the remote address.
+	assert.Equal(t, []string{"actor:support", "actor:billing", "addr:192.0.2.1"}, clients)
+}
+
+func TestIdempotentResponseKeepsHeaders(t *testing.T) {
+	mockedIdempotencyStorage := mock_storage.NewMockIdempotencyStorage(gomock.NewController(t))
+	idempotency := handlers.NewIdempotency(mockedIdempotencyStorage, time.Hour)
+
+	mockedIdempotencyStorage.EXPECT().
+		Reserve(gomock.Any(), gomock.Any(), "key-1", gomock.Any(), time.Hour).
+		Return(nil, nil).
+		Times(1)
+	var stored models.IdempotentResponse
+	mockedIdempotencyStorage.EXPECT().
+		Complete(gomock.Any(), gomock.Any(), "key-1", gomock.Any()).
+		DoAndReturn(func(_ any, _, _ string, resp models.IdempotentResponse) error {
+			stored = resp
+			return nil
+		}).
+		Times(1)
+
+	handler := idempotency.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/subscriptions/7")
+		w.Header().Set("ETag", `"1"`)
+		w.Header().Set("X-Other", "not replayed")
+		w.WriteHeader(http.StatusCreated)
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(createBody))
+	r.Header.Set(handlers.IdempotencyKeyHeader, "key-1")
+	handler(w, r)
+
+	assert.Equal(t, http.Header{"Location": {"/subscriptions/7"}, "Etag": {`"1"`}}, stored.Header)
+}
+
+func TestIdempotentCreateReleasesOnPanic(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	mockedIdempotencyStorage := mock_storage.NewMockIdempotencyStorage(gomock.NewController(t))
+	idempotency := handlers.NewIdempotency(mockedIdempotencyStorage, time.Hour)
+
+	mockedIdempotencyStorage.EXPECT().
+		Reserve(gomock.Any(), gomock.Any(), "key-1", gomock.Any(), time.Hour).
+		Return(nil, nil).
+		Times(1)
+	mockedIdempotencyStorage.EXPECT().
+		Release(gomock.Any(), gomock.Any(), "key-1").
+		Return(nil).
+		Times(1)
+	mockedIdempotencyStorage.EXPECT().
+		Complete(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	handler := idempotency.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(createBody))
+	r.Header.Set(handlers.IdempotencyKeyHeader, "key-1")
+
+	// The panic still reaches the server after the key is released.
+	defer func() {
+		assert.Equal(t, http.ErrAbortHandler, recover())
+	}()
+	handler(w, r)
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testovoe/internal/models"
+	"testovoe/internal/response"
+	"testovoe/internal/storage"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+const (
+	MaxImportRows  = 10000
+	maxImportBytes = 10 << 20
+)
+
+// importColumns are the columns an import may have. The header row names
+// them in any order; the required ones must be present.
+var importColumns = map[string]bool{
+	"service_name":            true,
+	"price":                   true,
+	"user_id":                 true,
+	"start_date":              true,
+	"end_date":                false,
+	"currency":                false,
+	"billing_period":          false,
+	"billing_interval_months": false,
+	"trial_months":            false,
+	"trial_end":               false,
+	"category":                false,
+	"tags":                    false,
+	"notes":                   false,
+	"metadata":                false,
+}
+
+type ImportQuery struct {
+	DryRun    string `validate:"omitempty,boolean"`
+	Delimiter string `validate:"omitempty,oneof=comma semicolon tab"`
+}
+
+// ImportLineError describes why a line of the file is not imported. Line
+// is the line number in the file, the header being line 1.
+type ImportLineError struct {
+	Line    int                      `json:"line"`
+	Message string                   `json:"message"`
+	Errors  []response.ValidationErr `json:"errors,omitempty"`
+}
+
+type ImportResponse struct {
+	DryRun   bool              `json:"dry_run"`
+	Rows     int               `json:"rows"`
+	Valid    int               `json:"valid"`
+	Imported int               `json:"imported"`
+	IDs      []int             `json:"ids"`
+	Errors   []ImportLineError `json:"errors"`
+}
+
+// importRow is a line of the file that passed validation.
+type importRow struct {
+	line int
+	sub  *models.Subscription
+}
+
+// Import creates subscriptions from a CSV file with a header row. Every
+// row is validated like the Create payload; the valid rows are inserted in
+// one transaction and the invalid ones are reported by line. If the storage
+// refuses a valid row, nothing is imported and the response is 422 with the
+// line among the errors. With dry_run=true nothing is written.
+func (h *SubscriptionHandler) Import(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := ImportQuery{
+		DryRun:    r.URL.Query().Get("dry_run"),
+		Delimiter: r.URL.Query().Get("delimiter"),
+	}
+	if !h.validateInput(w, r, query) {
+		return
+	}
+	dryRun, _ := strconv.ParseBool(query.DryRun)
+
+	reader := csv.NewReader(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	switch query.Delimiter {
+	case "semicolon":
+		reader.Comma = ';'
+	case "tab":
+		reader.Comma = '\t'
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		slog.ErrorContext(ctx, "read csv header", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+	columns, err := importHeader(header)
+	if err != nil {
+		slog.ErrorContext(ctx, "read csv header", "error", err)
+		response.BadRequest(w, "Invalid header: "+err.Error())
+		return
+	}
+
+	resp := ImportResponse{DryRun: dryRun, IDs: []int{}, Errors: []ImportLineError{}}
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "read csv", "error", err)
+			response.BadRequest(w, "Invalid CSV: "+err.Error())
+			return
+		}
+		line, _ := reader.FieldPos(0)
+
+		resp.Rows++
+		if resp.Rows > MaxImportRows {
+			response.BadRequest(w, fmt.Sprintf("File has more than %d rows", MaxImportRows))
+			return
+		}
+
+		sub, lineErr := h.importRecord(columns, record)
+		if lineErr != nil {
+			lineErr.Line = line
+			resp.Errors = append(resp.Errors, *lineErr)
+			continue
+		}
+		rows = append(rows, importRow{line: line, sub: sub})
+	}
+	resp.Valid = len(rows)
+
+	if dryRun || len(rows) == 0 {
+		response.Success(w, resp)
+		return
+	}
+
+	var failed int
+	err = h.store.Subscription.InTx(ctx, func(tx storage.SubscriptionStorage) error {
+		for _, row := range rows {
+			id, err := tx.Create(ctx, row.sub)
+			if err != nil {
+				failed = row.line
+				return fmt.Errorf("line %d: %w", row.line, err)
+			}
+			resp.IDs = append(resp.IDs, id)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "import subscriptions", "error", err)
+		message, ok := importRowError(err)
+		if !ok {
+			response.ServerError(w, "Internal server error")
+			return
+		}
+		// The transaction is rolled back, nothing is imported.
+		resp.IDs = []int{}
+		resp.Errors = append(resp.Errors, ImportLineError{Line: failed, Message: message})
+		response.Write(w, response.Response{
+			Status:  http.StatusUnprocessableEntity,
+			Message: "Import is not applied",
+			Data:    resp,
+		})
+		return
+	}
+	resp.Imported = len(resp.IDs)
+
+	response.Success(w, resp)
+}
+
+// importRowError returns the message of a line the storage refused to
+// insert, or false if the error isn't caused by the line.
+func importRowError(err error) (string, bool) {
+	switch {
+	case errors.Is(err, storage.ErrServiceNotFound):
+		return "Service not found", true
+	case errors.Is(err, storage.ErrInvalidSubscription):
+		return "Subscription is invalid", true
+	}
+	return "", false
+}
+
+// importHeader maps the columns of the header to their indexes.
+func importHeader(header []string) (map[string]int, error) {
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		// Spreadsheets often start the file with a byte order mark.
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		if _, ok := importColumns[name]; !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		columns[name] = i
+	}
+	for name, required := range importColumns {
+		if _, ok := columns[name]; required && !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+	return columns, nil
+}
+
+// importRecord converts a row into a subscription the same way Create
+// does with its payload.
+func (h *SubscriptionHandler) importRecord(columns map[string]int, record []string) (*models.Subscription, *ImportLineError) {
+	if len(record) != len(columns) {
+		return nil, &ImportLineError{Message: fmt.Sprintf("Expected %d fields, got %d", len(columns), len(record))}
+	}
+	value := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	optional := func(name string) *string {
+		if v := value(name); v != "" {
+			return &v
+		}
+		return nil
+	}
+
+	var fieldErrs []response.ValidationErr
+	optionalInt := func(name, field string) *int {
+		v := optional(name)
+		if v == nil {
+			return nil
+		}
+		n, err := strconv.Atoi(*v)
+		if err != nil {
+			fieldErrs = append(fieldErrs, response.ValidationErr{Field: field, Tag: "number", Value: *v})
+			return nil
+		}
+		return &n
+	}
+
+	payload := CreateSubscriptionPayload{
+		ServiceName: value("service_name"),
+		Currency:    value("currency"),
+		StartDate:   value("start_date"),
+		EndDate:     optional("end_date"),
+
+		BillingPeriod:         value("billing_period"),
+		BillingIntervalMonths: optionalInt("billing_interval_months", "BillingIntervalMonths"),
+
+		TrialMonths: optionalInt("trial_months", "TrialMonths"),
+		TrialEnd:    optional("trial_end"),
+
+		Category: optional("category"),
+		Notes:    optional("notes"),
+	}
+	// Metadata is a JSON object, like in the export.
+	if v := value("metadata"); v != "" {
+		payload.Metadata = json.RawMessage(v)
+	}
+	// Tags are comma separated, like in the export.
+	if v := value("tags"); v != "" {
+		payload.Tags = strings.Split(v, ",")
+	}
+	if price := optionalInt("price", "Price"); price != nil {
+		payload.Price = *price
+	}
+	if v := value("user_id"); v != "" {
+		userID, err := uuid.Parse(v)
+		if err != nil {
+			fieldErrs = append(fieldErrs, response.ValidationErr{Field: "UserID", Tag: "uuid", Value: v})
+		}
+		payload.UserID = userID
+	}
+
+	if err := h.validate.Struct(payload); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			// A value that couldn't be parsed is already reported, don't
+			// report it again as missing.
+			for _, verr := range response.ValidationErrors(verrs) {
+				if !slices.ContainsFunc(fieldErrs, func(e response.ValidationErr) bool { return e.Field == verr.Field }) {
+					fieldErrs = append(fieldErrs, verr)
+				}
+			}
+		} else {
+			return nil, &ImportLineError{Message: "Invalid input"}
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return nil, &ImportLineError{Message: "validation error", Errors: fieldErrs}
+	}
+
+	sub, err := subscriptionFromPayload(payload)
+	if err != nil {
+		if errors.Is(err, ErrTrialBeforeStart) {
+			return nil, &ImportLineError{Message: "Trial ends before start date"}
+		}
+		return nil, &ImportLineError{Message: "Bad request"}
+	}
+	return sub, nil
+}
@@ -0,0 +1,171 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testovoe/internal/handlers"
+	"testovoe/internal/models"
+	"testovoe/internal/storage"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"go.uber.org/mock/gomock"
+)
+
+const importCSV = "service_name,price,user_id,start_date,end_date\n" +
+	"Netflix,400,60601fee-2bf1-4721-ae6f-7636e79a0cba,07-2025,\n" +
+	"Spotify,abc,60601fee-2bf1-4721-ae6f-7636e79a0cba,2025/07,\n" +
+	"\"Yandex, Plus\",300,60601fee-2bf1-4721-ae6f-7636e79a0cba,2025-07-15,12-2025\n"
+
+func TestImport(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+	expectInTx(mockedSubscriptionStorage)
+
+	var created []*models.Subscription
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, sub *models.Subscription) (int, error) {
+			created = append(created, sub)
+			return len(created), nil
+		}).
+		Times(2)
+
+	var resp handlers.ImportResponse
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/import", strings.NewReader(importCSV))
+	w := serve("POST /subscriptions/import", handler.Import, r, &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 3, resp.Rows)
+	assert.Equal(t, 2, resp.Imported)
+	assert.Equal(t, []int{1, 2}, resp.IDs)
+	assert.Equal(t, 1, len(resp.Errors))
+	assert.Equal(t, 3, resp.Errors[0].Line)
+	assert.Equal(t, 2, len(resp.Errors[0].Errors))
+	assert.Equal(t, "Price", resp.Errors[0].Errors[0].Field)
+	assert.Equal(t, "number", resp.Errors[0].Errors[0].Tag)
+	assert.Equal(t, "StartDate", resp.Errors[0].Errors[1].Field)
+
+	assert.Equal(t, "Yandex, Plus", created[1].ServiceName)
+	assert.Equal(t, time.Date(2025, time.July, 15, 0, 0, 0, 0, time.UTC), created[1].StartDate)
+	assert.Equal(t, time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC), created[1].EndDate.Time)
+	assert.Equal(t, "RUB", created[0].Currency)
+}
+
+func TestImportDryRun(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		InTx(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	var resp handlers.ImportResponse
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/import?dry_run=true", strings.NewReader(importCSV))
+	w := serve("POST /subscriptions/import", handler.Import, r, &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, resp.DryRun)
+	assert.Equal(t, 2, resp.Valid)
+	assert.Equal(t, 0, resp.Imported)
+	assert.Equal(t, 1, len(resp.Errors))
+}
+
+func TestImportDelimiter(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		InTx(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	body := "\ufeffService_Name;price;user_id;start_date\n" +
+		"Netflix;400;60601fee-2bf1-4721-ae6f-7636e79a0cba;07-2025\n" +
+		"Spotify;300;60601fee-2bf1-4721-ae6f-7636e79a0cba\n"
+	var resp handlers.ImportResponse
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/import?dry_run=1&delimiter=semicolon", strings.NewReader(body))
+	w := serve("POST /subscriptions/import", handler.Import, r, &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, resp.Valid)
+	assert.Equal(t, 1, len(resp.Errors))
+	assert.Equal(t, 3, resp.Errors[0].Line)
+}
+
+func TestImportMissingColumn(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		InTx(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/import", strings.NewReader("service_name,price,start_date\nNetflix,400,07-2025\n"))
+	w := serve("POST /subscriptions/import", handler.Import, r, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestImportUnknownColumn(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		InTx(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/import", strings.NewReader("service_name,price,user_id,start_date,comment\n"))
+	w := serve("POST /subscriptions/import", handler.Import, r, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestImportInvalidDryRun(t *testing.T) {
+	handler, _ := setupTest(t)
+
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/import?dry_run=maybe", strings.NewReader(importCSV))
+	w := serve("POST /subscriptions/import", handler.Import, r, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestImportPriceOutOfRange(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		InTx(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	body := "service_name,price,user_id,start_date\n" +
+		"Netflix,2147483648,60601fee-2bf1-4721-ae6f-7636e79a0cba,07-2025\n"
+	var resp handlers.ImportResponse
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/import", strings.NewReader(body))
+	w := serve("POST /subscriptions/import", handler.Import, r, &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, len(resp.Errors))
+	assert.Equal(t, 2, resp.Errors[0].Line)
+	assert.Equal(t, "Price", resp.Errors[0].Errors[0].Field)
+}
+
+func TestImportRowRefused(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+	expectInTx(mockedSubscriptionStorage)
+
+	gomock.InOrder(
+		mockedSubscriptionStorage.EXPECT().
+			Create(gomock.Any(), gomock.Any()).
+			Return(1, nil),
+		mockedSubscriptionStorage.EXPECT().
+			Create(gomock.Any(), gomock.Any()).
+			Return(0, storage.ErrInvalidSubscription),
+	)
+
+	var resp handlers.ImportResponse
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/import", strings.NewReader(importCSV))
+	w := serve("POST /subscriptions/import", handler.Import, r, &resp)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, 0, resp.Imported)
+	assert.Equal(t, []int{}, resp.IDs)
+	assert.Equal(t, 2, len(resp.Errors))
+	assert.Equal(t, 4, resp.Errors[1].Line)
+	assert.Equal(t, "Subscription is invalid", resp.Errors[1].Message)
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testovoe/internal/currency"
+	"testovoe/internal/models"
+	"testovoe/internal/response"
+	"testovoe/internal/storage"
+	"testovoe/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+// PatchSubscriptionPayload is a JSON Merge Patch (RFC 7396) of a
+// subscription: only the fields present in the document are changed.
+type PatchSubscriptionPayload struct {
+	ServiceID   *int       `json:"service_id" validate:"omitnil,min=1"`
+	ServiceName *string    `json:"service_name" validate:"omitnil,required"`
+	Price       *int       `json:"price" validate:"omitnil,min=0,max=2147483647"`
+	Currency    *string    `json:"currency" validate:"omitnil,iso4217"`
+	UserID      *uuid.UUID `json:"user_id" validate:"omitnil,required,uuid"`
+	StartDate   *string    `json:"start_date" validate:"omitnil,mm_yyyy_or_date"`
+	EndDate     *string    `json:"end_date" validate:"omitnil,mm_yyyy_or_date"`
+
+	BillingPeriod         *string `json:"billing_period" validate:"omitnil,oneof=monthly quarterly yearly weekly custom"`
+	BillingIntervalMonths *int    `json:"billing_interval_months" validate:"omitnil,min=1,max=120"`
+
+	TrialEnd *string `json:"trial_end" validate:"omitnil,mm_yyyy_or_date"`
+
+	Category *string  `json:"category" validate:"omitnil,oneof=streaming music gaming cloud software news education fitness other"`
+	Tags     []string `json:"tags" validate:"max=20,dive,required,max=50"`
+
+	Metadata json.RawMessage `json:"metadata" validate:"omitempty,metadata"`
+	Notes    *string         `json:"notes" validate:"omitnil,max=2000"`
+}
+
+// nonNullableFields can't be removed by a patch.
+var nonNullableFields = []string{"service_name", "price", "user_id", "start_date"}
+
+// patchableFields are the members a patch may contain.
+var patchableFields = map[string]bool{
+	"service_id":              true,
+	"service_name":            true,
+	"price":                   true,
+	"currency":                true,
+	"user_id":                 true,
+	"start_date":              true,
+	"end_date":                true,
+	"billing_period":          true,
+	"billing_interval_months": true,
+	"trial_end":               true,
+	"category":                true,
+	"tags":                    true,
+	"metadata":                true,
+	"notes":                   true,
+}
+
+func isNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+// Patch applies a JSON Merge Patch to a subscription, e.g. only sets the
+// end date to cancel it or only changes the price. A null member removes
+// the field: end_date, trial_end, category and notes are cleared, tags and
+// metadata are emptied, service_id unlinks the subscription from the
+// catalog, currency and billing_period fall back to their defaults. The
+// metadata object is merged with the same rules, so a patch can set or
+// remove a single key of it.
+func (h *SubscriptionHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	intID, err := strconv.Atoi(id)
+	if err != nil {
+		slog.ErrorContext(ctx, "parse id", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	version, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		slog.ErrorContext(ctx, "read body", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	// The members are decoded twice: as raw values to tell a null from a
+	// missing member, and into the payload to validate them.
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil || members == nil {
+		slog.ErrorContext(ctx, "read merge patch", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+	for name := range members {
+		if !patchableFields[name] {
+			response.BadRequest(w, "Unknown field "+name)
+			return
+		}
+	}
+	for _, name := range nonNullableFields {
+		if raw, ok := members[name]; ok && isNull(raw) {
+			response.BadRequest(w, "Field "+name+" can't be null")
+			return
+		}
+	}
+
+	var payload PatchSubscriptionPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		slog.ErrorContext(ctx, "read json", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	if !h.validateInput(w, r, payload) {
+		return
+	}
+
+	patch, err := patchFromPayload(members, payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "parse patch dates", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	sub, err := h.store.Subscription.Patch(ctx, intID, version, patch)
+	if err != nil {
+		slog.ErrorContext(ctx, "patch subscription", "error", err)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			response.NotFound(w, "Not found")
+		case errors.Is(err, storage.ErrInvalidPatch):
+			response.BadRequest(w, "Patched subscription is invalid")
+		case errors.Is(err, storage.ErrServiceNotFound):
+			response.BadRequest(w, "Service not found")
+		case errors.Is(err, storage.ErrStaleVersion):
+			response.PreconditionFailed(w, "Subscription has been modified")
+		default:
+			response.ServerError(w, "Internal server error")
+		}
+		return
+	}
+
+	w.Header().Set("ETag", etag(sub.Version))
+	response.Success(w, newSubscriptionResponse(sub))
+}
+
+// patchFromPayload converts a validated payload into a storage patch.
+// members holds the raw members of the document, so that a null can be
+// told from a missing member.
+func patchFromPayload(members map[string]json.RawMessage, payload PatchSubscriptionPayload) (models.SubscriptionPatch, error) {
+	patch := models.SubscriptionPatch{
+		ServiceName: payload.ServiceName,
+		Price:       payload.Price,
+		Currency:    payload.Currency,
+		UserID:      payload.UserID,
+	}
+
+	// A null service_id unlinks the subscription from the catalog.
+	if _, ok := members["service_id"]; ok {
+		serviceID := sql.NullInt32{}
+		if payload.ServiceID != nil {
+			serviceID = sql.NullInt32{Int32: int32(*payload.ServiceID), Valid: true}
+		}
+		patch.ServiceID = &serviceID
+	}
+
+	if _, ok := members["category"]; ok {
+		category := sql.NullString{}
+		if payload.Category != nil {
+			category = sql.NullString{String: *payload.Category, Valid: true}
+		}
+		patch.Category = &category
+	}
+	// Tags are replaced as a whole, like any array in a merge patch.
+	if _, ok := members["tags"]; ok {
+		tags := normalizeTags(payload.Tags)
+		patch.Tags = &tags
+	}
+	if raw, ok := members["metadata"]; ok {
+		patch.Metadata = raw
+	}
+	if _, ok := members["notes"]; ok {
+		notes := sql.NullString{}
+		if payload.Notes != nil {
+			notes = sql.NullString{String: *payload.Notes, Valid: true}
+		}
+		patch.Notes = &notes
+	}
+
+	if _, ok := members["currency"]; ok && payload.Currency == nil {
+		patch.Currency = utils.String(currency.Default)
+	}
+
+	if payload.StartDate != nil {
+		start, err := utils.ParseStartDate(*payload.StartDate)
+		if err != nil {
+			return models.SubscriptionPatch{}, err
+		}
+		patch.StartDate = &start
+	}
+
+	var err error
+	if patch.EndDate, err = nullableEndDate(members, "end_date", payload.EndDate); err != nil {
+		return models.SubscriptionPatch{}, err
+	}
+	if patch.TrialEnd, err = nullableEndDate(members, "trial_end", payload.TrialEnd); err != nil {
+		return models.SubscriptionPatch{}, err
+	}
+
+	if _, ok := members["billing_period"]; ok {
+		period := models.BillingMonthly
+		if payload.BillingPeriod != nil {
+			period = models.BillingPeriod(*payload.BillingPeriod)
+		}
+		patch.BillingPeriod = &period
+		// Only the custom period has an interval.
+		if period != models.BillingCustom {
+			patch.BillingIntervalMonths = &sql.NullInt32{}
+		}
+	}
+	if _, ok := members["billing_interval_months"]; ok && (patch.BillingPeriod == nil || *patch.BillingPeriod == models.BillingCustom) {
+		interval := sql.NullInt32{}
+		if payload.BillingIntervalMonths != nil {
+			interval = sql.NullInt32{Int32: int32(*payload.BillingIntervalMonths), Valid: true}
+		}
+		patch.BillingIntervalMonths = &interval
+	}
+
+	return patch, nil
+}
+
+// nullableEndDate returns nil if the member is missing, a NULL value if it
+// is null and the parsed last day otherwise.
+func nullableEndDate(members map[string]json.RawMessage, name string, value *string) (*sql.NullTime, error) {
+	if _, ok := members[name]; !ok {
+		return nil, nil
+	}
+	if value == nil {
+		return &sql.NullTime{}, nil
+	}
+	end, err := utils.ParseEndDate(*value)
+	if err != nil {
+		return nil, err
+	}
+	return &sql.NullTime{Time: end, Valid: true}, nil
+}
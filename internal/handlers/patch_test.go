@@ -0,0 +1,226 @@
+package handlers_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testovoe/internal/handlers"
+	"testovoe/internal/models"
+	"testovoe/internal/storage"
+	"testovoe/internal/utils"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func patchedSubscription() *models.Subscription {
+	return &models.Subscription{
+		ID:            1,
+		ServiceName:   "Netflix",
+		Price:         400,
+		Currency:      "RUB",
+		UserID:        uuid.MustParse("60601fee-2bf1-4721-ae6f-7636e79a0cba"),
+		StartDate:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		BillingPeriod: models.BillingMonthly,
+	}
+}
+
+func TestPatchEndDateOnly(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	end := time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC)
+	sub := patchedSubscription()
+	sub.EndDate = sql.NullTime{Time: end, Valid: true}
+
+	mockedSubscriptionStorage.EXPECT().
+		Patch(gomock.Any(), 1, 0, models.SubscriptionPatch{
+			EndDate: &sql.NullTime{Time: end, Valid: true},
+		}).
+		Return(sub, nil).
+		Times(1)
+
+	var resp handlers.SubscriptionResponse
+	r := httptest.NewRequest(http.MethodPatch, "/subscriptions/1", strings.NewReader(`{"end_date": "06-2025"}`))
+	w := serve("PATCH /subscriptions/{id}", handler.Patch, r, &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, utils.String("06-2025"), resp.EndDate)
+	assert.Equal(t, "Netflix", resp.ServiceName)
+}
+
+func TestPatchClearEndDate(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Patch(gomock.Any(), 1, 0, models.SubscriptionPatch{
+			EndDate: &sql.NullTime{},
+		}).
+		Return(patchedSubscription(), nil).
+		Times(1)
+
+	r := httptest.NewRequest(http.MethodPatch, "/subscriptions/1", strings.NewReader(`{"end_date": null}`))
+	w := serve("PATCH /subscriptions/{id}", handler.Patch, r, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestPatchPriceOnly(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	sub := patchedSubscription()
+	sub.Price = 500
+
+	mockedSubscriptionStorage.EXPECT().
+		Patch(gomock.Any(), 1, 0, models.SubscriptionPatch{
+			Price: utils.Int(500),
+		}).
+		Return(sub, nil).
+		Times(1)
+
+	var resp handlers.SubscriptionResponse
+	r := httptest.NewRequest(http.MethodPatch, "/subscriptions/1", strings.NewReader(`{"price": 500}`))
+	w := serve("PATCH /subscriptions/{id}", handler.Patch, r, &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 500, resp.Price)
+}
+
+func TestPatchBillingPeriodClearsInterval(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	period := models.BillingYearly
+	mockedSubscriptionStorage.EXPECT().
+		Patch(gomock.Any(), 1, 0, models.SubscriptionPatch{
+			BillingPeriod:         &period,
+			BillingIntervalMonths: &sql.NullInt32{},
+		}).
+		Return(patchedSubscription(), nil).
+		Times(1)
+
+	r := httptest.NewRequest(http.MethodPatch, "/subscriptions/1", strings.NewReader(`{"billing_period": "yearly"}`))
+	w := serve("PATCH /subscriptions/{id}", handler.Patch, r, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestPatchCategoryAndTags(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	tags := []string{"work"}
+	mockedSubscriptionStorage.EXPECT().
+		Patch(gomock.Any(), 1, 0, models.SubscriptionPatch{
+			Category: &sql.NullString{String: "cloud", Valid: true},
+			Tags:     &tags,
+		}).
+		Return(patchedSubscription(), nil).
+		Times(1)
+	var noTags []string
+	mockedSubscriptionStorage.EXPECT().
+		Patch(gomock.Any(), 2, 0, models.SubscriptionPatch{
+			Category: &sql.NullString{},
+			Tags:     &noTags,
+		}).
+		Return(patchedSubscription(), nil).
+		Times(1)
+
+	r := httptest.NewRequest(http.MethodPatch, "/subscriptions/1", strings.NewReader(`{"category": "cloud", "tags": ["Work"]}`))
+	w := serve("PATCH /subscriptions/{id}", handler.Patch, r, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	r = httptest.NewRequest(http.MethodPatch, "/subscriptions/2", strings.NewReader(`{"category": null, "tags": null}`))
+	w = serve("PATCH /subscriptions/{id}", handler.Patch, r, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestPatchMetadata(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	// The merge patch of the metadata goes to the storage as is.
+	mockedSubscriptionStorage.EXPECT().
+		Patch(gomock.Any(), 1, 0, models.SubscriptionPatch{
+			Metadata: json.RawMessage(`{"bank_ref": "TX-1", "app_store_id": null}`),
+			Notes:    &sql.NullString{},
+		}).
+		Return(patchedSubscription(), nil).
+		Times(1)
+
+	r := httptest.NewRequest(http.MethodPatch, "/subscriptions/1", strings.NewReader(`{"metadata": {"bank_ref": "TX-1", "app_store_id": null}, "notes": null}`))
+	w := serve("PATCH /subscriptions/{id}", handler.Patch, r, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	r = httptest.NewRequest(http.MethodPatch, "/subscriptions/1", strings.NewReader(`{"metadata": [1]}`))
+	w = serve("PATCH /subscriptions/{id}", handler.Patch, r, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPatchNullRequiredField(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Patch(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	r := httptest.NewRequest(http.MethodPatch, "/subscriptions/1", strings.NewReader(`{"price": null}`))
+	w := serve("PATCH /subscriptions/{id}", handler.Patch, r, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPatchUnknownField(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Patch(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	r := httptest.NewRequest(http.MethodPatch, "/subscriptions/1", strings.NewReader(`{"prise": 500}`))
+	w := serve("PATCH /subscriptions/{id}", handler.Patch, r, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPatchInvalidDate(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Patch(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	r := httptest.NewRequest(http.MethodPatch, "/subscriptions/1", strings.NewReader(`{"end_date": "2025/06/30"}`))
+	w := serve("PATCH /subscriptions/{id}", handler.Patch, r, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPatchNotFound(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Patch(gomock.Any(), 999, 0, gomock.Any()).
+		Return(nil, storage.ErrNotFound).
+		Times(1)
+
+	r := httptest.NewRequest(http.MethodPatch, "/subscriptions/999", strings.NewReader(`{"price": 500}`))
+	w := serve("PATCH /subscriptions/{id}", handler.Patch, r, nil)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPatchInvalidResult(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Patch(gomock.Any(), 1, 0, gomock.Any()).
+		Return(nil, storage.ErrInvalidPatch).
+		Times(1)
+
+	r := httptest.NewRequest(http.MethodPatch, "/subscriptions/1", strings.NewReader(`{"billing_period": "custom"}`))
+	w := serve("PATCH /subscriptions/{id}", handler.Patch, r, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
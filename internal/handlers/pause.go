@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testovoe/internal/models"
+	"testovoe/internal/response"
+	"testovoe/internal/storage"
+	"testovoe/internal/utils"
+	"time"
+)
+
+type PausePayload struct {
+	From  *string `json:"from,omitempty" validate:"omitempty,mm_yyyy_or_date"`
+	Until *string `json:"until,omitempty" validate:"omitempty,mm_yyyy_or_date"`
+}
+
+type ResumePayload struct {
+	On *string `json:"on,omitempty" validate:"omitempty,mm_yyyy_or_date"`
+}
+
+type PauseResponse struct {
+	From  string  `json:"from"`
+	Until *string `json:"until,omitempty"`
+}
+
+type PausesResponse struct {
+	SubscriptionID int             `json:"subscription_id"`
+	Pauses         []PauseResponse `json:"pauses"`
+}
+
+func newPauseResponse(pause models.Pause) PauseResponse {
+	resp := PauseResponse{From: utils.FormatStartDate(pause.From)}
+	if pause.Until.Valid {
+		resp.Until = utils.String(utils.FormatEndDate(pause.Until.Time))
+	}
+	return resp
+}
+
+// today returns midnight UTC of the current day.
+func today() time.Time {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Pause freezes a subscription: billing dates from the first to the last
+// day of the pause are not charged. Both days are optional, the pause
+// starts today by default and lasts until the subscription is resumed if
+// it has no last day. Pauses of a subscription can't overlap.
+func (h *SubscriptionHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	intID, err := strconv.Atoi(id)
+	if err != nil {
+		slog.ErrorContext(ctx, "parse id", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	// The body is optional.
+	var payload PausePayload
+	if err := utils.ReadJSON(r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		slog.ErrorContext(ctx, "read json", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	if !h.validateInput(w, r, payload) {
+		return
+	}
+
+	pause := models.Pause{From: today()}
+	if payload.From != nil {
+		pause.From, err = utils.ParseStartDate(*payload.From)
+		if err != nil {
+			slog.ErrorContext(ctx, "parse pause start", "error", err)
+			response.BadRequest(w, "Bad request")
+			return
+		}
+	}
+	if payload.Until != nil {
+		until, err := utils.ParseEndDate(*payload.Until)
+		if err != nil {
+			slog.ErrorContext(ctx, "parse pause end", "error", err)
+			response.BadRequest(w, "Bad request")
+			return
+		}
+		if until.Before(pause.From) {
+			slog.ErrorContext(ctx, "parse pause", "error", ErrInvalidPeriod)
+			response.BadRequest(w, "Period end is before period start")
+			return
+		}
+		pause.Until = sql.NullTime{Time: until, Valid: true}
+	}
+
+	if err := h.store.Subscription.Pause(ctx, intID, pause); err != nil {
+		slog.ErrorContext(ctx, "pause subscription", "error", err)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			response.NotFound(w, "Not found")
+		case errors.Is(err, storage.ErrPauseOverlaps):
+			response.Conflict(w, "Subscription is already paused in this period")
+		default:
+			response.ServerError(w, "Internal server error")
+		}
+		return
+	}
+
+	response.Created(w, newPauseResponse(pause))
+}
+
+// Resume ends the current pause of a subscription, so that it is charged
+// again from the given day (today by default). It responds with the
+// shortened pause, which is empty if the subscription is resumed on the
+// day the pause starts.
+func (h *SubscriptionHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	intID, err := strconv.Atoi(id)
+	if err != nil {
+		slog.ErrorContext(ctx, "parse id", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	// The body is optional.
+	var payload ResumePayload
+	if err := utils.ReadJSON(r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		slog.ErrorContext(ctx, "read json", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	if !h.validateInput(w, r, payload) {
+		return
+	}
+
+	on := today()
+	if payload.On != nil {
+		on, err = utils.ParseStartDate(*payload.On)
+		if err != nil {
+			slog.ErrorContext(ctx, "parse resume date", "error", err)
+			response.BadRequest(w, "Bad request")
+			return
+		}
+	}
+
+	pause, err := h.store.Subscription.Resume(ctx, intID, on)
+	if err != nil {
+		slog.ErrorContext(ctx, "resume subscription", "error", err)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			response.NotFound(w, "Not found")
+		case errors.Is(err, storage.ErrNotPaused):
+			response.Conflict(w, "Subscription is not paused")
+		default:
+			response.ServerError(w, "Internal server error")
+		}
+		return
+	}
+
+	if pause == nil {
+		response.Success(w, nil)
+		return
+	}
+	response.Success(w, newPauseResponse(*pause))
+}
+
+// Pauses returns every pause of a subscription, oldest first.
+func (h *SubscriptionHandler) Pauses(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	intID, err := strconv.Atoi(id)
+	if err != nil {
+		slog.ErrorContext(ctx, "parse id", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	pauses, err := h.store.Subscription.Pauses(ctx, intID)
+	if err != nil {
+		slog.ErrorContext(ctx, "list pauses", "error", err)
+		if errors.Is(err, storage.ErrNotFound) {
+			response.NotFound(w, "Not found")
+			return
+		}
+		response.ServerError(w, "Internal server error")
+		return
+	}
+
+	resp := PausesResponse{
+		SubscriptionID: intID,
+		Pauses:         []PauseResponse{},
+	}
+	for _, pause := range pauses {
+		resp.Pauses = append(resp.Pauses, newPauseResponse(pause))
+	}
+
+	response.Success(w, resp)
+}
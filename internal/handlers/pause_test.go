@@ -0,0 +1,166 @@
+package handlers_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testovoe/internal/handlers"
+	"testovoe/internal/models"
+	"testovoe/internal/storage"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"go.uber.org/mock/gomock"
+)
+
+func TestPause(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Pause(gomock.Any(), 1, models.Pause{
+			From:  time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+			Until: sql.NullTime{Time: time.Date(2025, time.May, 31, 0, 0, 0, 0, time.UTC), Valid: true},
+		}).
+		Return(nil).
+		Times(1)
+
+	body := `{"from": "03-2025", "until": "05-2025"}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/1/pause", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subscriptions/{id}/pause", handler.Pause)
+
+	mux.ServeHTTP(w, r)
+
+	var resp struct {
+		Data handlers.PauseResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "03-2025", resp.Data.From)
+	assert.Equal(t, "05-2025", *resp.Data.Until)
+}
+
+func TestPauseWithoutBody(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Pause(gomock.Any(), 1, gomock.Any()).
+		DoAndReturn(func(_ any, _ int, pause models.Pause) error {
+			if pause.Until.Valid {
+				t.Errorf("expected an open pause, got %v", pause)
+			}
+			return nil
+		}).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/1/pause", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subscriptions/{id}/pause", handler.Pause)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestPauseOverlaps(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Pause(gomock.Any(), 1, gomock.Any()).
+		Return(storage.ErrPauseOverlaps).
+		Times(1)
+
+	body := `{"from": "2025-03-10"}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/1/pause", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subscriptions/{id}/pause", handler.Pause)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestPauseUntilBeforeFrom(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Pause(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	body := `{"from": "05-2025", "until": "03-2025"}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/1/pause", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subscriptions/{id}/pause", handler.Pause)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestResume(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Resume(gomock.Any(), 1, time.Date(2025, time.April, 10, 0, 0, 0, 0, time.UTC)).
+		Return(&models.Pause{
+			From:  time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+			Until: sql.NullTime{Time: time.Date(2025, time.April, 9, 0, 0, 0, 0, time.UTC), Valid: true},
+		}, nil).
+		Times(1)
+
+	body := `{"on": "2025-04-10"}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/1/resume", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subscriptions/{id}/resume", handler.Resume)
+
+	mux.ServeHTTP(w, r)
+
+	var resp struct {
+		Data handlers.PauseResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2025-04-09", *resp.Data.Until)
+}
+
+func TestResumeNotPaused(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Resume(gomock.Any(), 1, gomock.Any()).
+		Return(nil, storage.ErrNotPaused).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/1/resume", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subscriptions/{id}/resume", handler.Resume)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
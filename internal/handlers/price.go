@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testovoe/internal/models"
+	"testovoe/internal/response"
+	"testovoe/internal/storage"
+	"testovoe/internal/utils"
+)
+
+type PriceChangePayload struct {
+	Price         int    `json:"price" validate:"min=0,max=2147483647"`
+	EffectiveFrom string `json:"effective_from" validate:"required,mm_yyyy"`
+}
+
+type PriceChangeResponse struct {
+	Price         int    `json:"price"`
+	EffectiveFrom string `json:"effective_from"`
+}
+
+type PriceHistoryResponse struct {
+	SubscriptionID int                   `json:"subscription_id"`
+	Prices         []PriceChangeResponse `json:"prices"`
+}
+
+// ChangePrice records a new price of a subscription effective from the given
+// month. Months before it keep being charged at the old price, so past
+// totals don't change. A second change for the same month replaces the first.
+func (h *SubscriptionHandler) ChangePrice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	intID, err := strconv.Atoi(id)
+	if err != nil {
+		slog.ErrorContext(ctx, "parse id", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	var payload PriceChangePayload
+	if err := utils.ReadJSON(r, &payload); err != nil {
+		slog.ErrorContext(ctx, "read json", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	if !h.validateInput(w, r, payload) {
+		return
+	}
+
+	effectiveFrom, err := utils.ParseMonthYear(payload.EffectiveFrom)
+	if err != nil {
+		slog.ErrorContext(ctx, "parse effective from", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	change := models.PriceChange{
+		Price:         payload.Price,
+		EffectiveFrom: effectiveFrom,
+	}
+	if err := h.store.Subscription.ChangePrice(ctx, intID, change); err != nil {
+		slog.ErrorContext(ctx, "change price", "error", err)
+		if errors.Is(err, storage.ErrNotFound) {
+			response.NotFound(w, "Not found")
+			return
+		}
+		response.ServerError(w, "Internal server error")
+		return
+	}
+
+	response.Created(w, PriceChangeResponse{
+		Price:         change.Price,
+		EffectiveFrom: change.EffectiveFrom.Format("01-2006"),
+	})
+}
+
+// PriceHistory returns every price a subscription has had, oldest first.
+func (h *SubscriptionHandler) PriceHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	intID, err := strconv.Atoi(id)
+	if err != nil {
+		slog.ErrorContext(ctx, "parse id", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	history, err := h.store.Subscription.PriceHistory(ctx, intID)
+	if err != nil {
+		slog.ErrorContext(ctx, "price history", "error", err)
+		if errors.Is(err, storage.ErrNotFound) {
+			response.NotFound(w, "Not found")
+			return
+		}
+		response.ServerError(w, "Internal server error")
+		return
+	}
+
+	resp := PriceHistoryResponse{
+		SubscriptionID: intID,
+		Prices:         []PriceChangeResponse{},
+	}
+	for _, change := range history {
+		resp.Prices = append(resp.Prices, PriceChangeResponse{
+			Price:         change.Price,
+			EffectiveFrom: change.EffectiveFrom.Format("01-2006"),
+		})
+	}
+
+	response.Success(w, resp)
+}
@@ -0,0 +1,135 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testovoe/internal/handlers"
+	"testovoe/internal/models"
+	"testovoe/internal/storage"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"go.uber.org/mock/gomock"
+)
+
+func TestChangePrice(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		ChangePrice(gomock.Any(), 1, models.PriceChange{
+			Price:         500,
+			EffectiveFrom: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+		}).
+		Return(nil).
+		Times(1)
+
+	body := `{"price": 500, "effective_from": "03-2025"}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/1/price-changes", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subscriptions/{id}/price-changes", handler.ChangePrice)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestChangePriceInvalidMonth(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		ChangePrice(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	body := `{"price": 500, "effective_from": "2025-03"}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/1/price-changes", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subscriptions/{id}/price-changes", handler.ChangePrice)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestChangePriceNegative(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		ChangePrice(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	body := `{"price": -500, "effective_from": "03-2025"}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/1/price-changes", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subscriptions/{id}/price-changes", handler.ChangePrice)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestChangePriceNotFound(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		ChangePrice(gomock.Any(), 999, gomock.Any()).
+		Return(storage.ErrNotFound).
+		Times(1)
+
+	body := `{"price": 500, "effective_from": "03-2025"}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/999/price-changes", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subscriptions/{id}/price-changes", handler.ChangePrice)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPriceHistory(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		PriceHistory(gomock.Any(), 1).
+		Return([]models.PriceChange{
+			{Price: 400, EffectiveFrom: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+			{Price: 500, EffectiveFrom: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		}, nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/1/price-history", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions/{id}/price-history", handler.PriceHistory)
+
+	mux.ServeHTTP(w, r)
+
+	var resp struct {
+		Data handlers.PriceHistoryResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, len(resp.Data.Prices))
+	assert.Equal(t, "01-2025", resp.Data.Prices[0].EffectiveFrom)
+	assert.Equal(t, 500, resp.Data.Prices[1].Price)
+}
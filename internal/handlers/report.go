@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"testovoe/internal/response"
+	"testovoe/internal/storage"
+	"testovoe/internal/utils"
+)
+
+type BreakdownQuery struct {
+	PeriodQuery
+	GroupBy string `validate:"required,oneof=service_name user_id category"`
+	Prorate string `validate:"omitempty,boolean"`
+}
+
+type BreakdownGroup struct {
+	Key   string  `json:"key"`
+	Total int64   `json:"total"`
+	Share float64 `json:"share"`
+	Count int     `json:"count"`
+}
+
+type BreakdownResponse struct {
+	From     string           `json:"from"`
+	To       string           `json:"to"`
+	GroupBy  string           `json:"group_by"`
+	Currency string           `json:"currency"`
+	Total    int64            `json:"total"`
+	Prorated bool             `json:"prorated,omitempty"`
+	Groups   []BreakdownGroup `json:"groups"`
+}
+
+// Breakdown returns the cost of subscriptions over a period grouped by
+// service, by user or by category, most expensive group first.
+// Query parameters:
+//   - from, to, user_id, service_name, currency: same as for Total
+//   - group_by: service_name, user_id or category (required); the
+//     subscriptions without a category are grouped under an empty key
+//   - prorate: same as for Total
+//
+// Every group carries its total, its share of the grand total (0..1)
+// and the number of subscriptions it counted.
+func (h *SubscriptionHandler) Breakdown(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := BreakdownQuery{
+		PeriodQuery: readPeriodQuery(r),
+		GroupBy:     r.URL.Query().Get("group_by"),
+		Prorate:     r.URL.Query().Get("prorate"),
+	}
+	if !h.validateInput(w, r, query) {
+		return
+	}
+
+	filter, ok := parsePeriodQuery(w, r, query.PeriodQuery)
+	if !ok {
+		return
+	}
+
+	prorate := prorated(query.Prorate)
+	items, err := h.store.Subscription.Breakdown(ctx, filter.From, filter.To, filter.UserID, filter.ServiceName, query.GroupBy, prorate)
+	if err != nil {
+		slog.ErrorContext(ctx, "breakdown", "error", err)
+		if errors.Is(err, storage.ErrInvalidGroupBy) {
+			response.BadRequest(w, "Bad request")
+			return
+		}
+		response.ServerError(w, "Internal server error")
+		return
+	}
+
+	rates, ok := h.loadRates(w, r)
+	if !ok {
+		return
+	}
+
+	resp := BreakdownResponse{
+		From:     utils.FormatStartDate(filter.From),
+		To:       utils.FormatEndDate(filter.To),
+		GroupBy:  query.GroupBy,
+		Currency: filter.Currency,
+		Prorated: prorate,
+		Groups:   []BreakdownGroup{},
+	}
+
+	// Rows are ordered by group, so one group in several currencies
+	// comes in adjacent rows.
+	for _, item := range items {
+		amount, err := rates.Convert(item.Total, item.Currency, filter.Currency)
+		if err != nil {
+			conversionError(w, r, err)
+			return
+		}
+
+		if n := len(resp.Groups); n > 0 && resp.Groups[n-1].Key == item.Key {
+			resp.Groups[n-1].Total += amount
+			resp.Groups[n-1].Count += item.Count
+		} else {
+			resp.Groups = append(resp.Groups, BreakdownGroup{
+				Key:   item.Key,
+				Total: amount,
+				Count: item.Count,
+			})
+		}
+		resp.Total += amount
+	}
+
+	for i := range resp.Groups {
+		if resp.Total > 0 {
+			resp.Groups[i].Share = math.Round(float64(resp.Groups[i].Total)/float64(resp.Total)*10000) / 10000
+		}
+	}
+	sort.SliceStable(resp.Groups, func(i, j int) bool {
+		return resp.Groups[i].Total > resp.Groups[j].Total
+	})
+
+	response.Success(w, resp)
+}
@@ -0,0 +1,133 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/handlers"
+	"testovoe/internal/models"
+	"testovoe/internal/storage"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestBreakdown(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Breakdown(
+			gomock.Any(),
+			time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC),
+			uuid.Nil,
+			"",
+			storage.GroupByServiceName,
+			false,
+		).
+		Return([]models.BreakdownItem{
+			{Key: "Netflix", Currency: "RUB", Count: 1, Total: 300},
+			{Key: "Netflix", Currency: "USD", Count: 1, Total: 5},
+			{Key: "Spotify", Currency: "RUB", Count: 1, Total: 250},
+		}, nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/reports/breakdown?from=01-2025&to=03-2025&group_by=service_name", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /reports/breakdown", handler.Breakdown)
+
+	mux.ServeHTTP(w, r)
+
+	var resp struct {
+		Data handlers.BreakdownResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(1000), resp.Data.Total)
+	assert.Equal(t, 2, len(resp.Data.Groups))
+	assert.Equal(t, "Netflix", resp.Data.Groups[0].Key)
+	assert.Equal(t, 0.75, resp.Data.Groups[0].Share)
+	assert.Equal(t, 2, resp.Data.Groups[0].Count)
+	assert.Equal(t, 0.25, resp.Data.Groups[1].Share)
+}
+
+func TestBreakdownMissingGroupBy(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Breakdown(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/reports/breakdown?from=01-2025&to=03-2025", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /reports/breakdown", handler.Breakdown)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestBreakdownByCategory(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Breakdown(gomock.Any(), gomock.Any(), gomock.Any(), uuid.Nil, "", storage.GroupByCategory, false).
+		Return([]models.BreakdownItem{
+			{Key: "", Currency: "RUB", Count: 1, Total: 100},
+			{Key: "streaming", Currency: "RUB", Count: 2, Total: 300},
+		}, nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/reports/breakdown?from=01-2025&to=03-2025&group_by=category", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /reports/breakdown", handler.Breakdown)
+
+	mux.ServeHTTP(w, r)
+
+	var resp struct {
+		Data handlers.BreakdownResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "category", resp.Data.GroupBy)
+	assert.Equal(t, 2, len(resp.Data.Groups))
+	assert.Equal(t, "streaming", resp.Data.Groups[0].Key)
+	assert.Equal(t, "", resp.Data.Groups[1].Key)
+}
+
+func TestBreakdownProrate(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Breakdown(gomock.Any(), gomock.Any(), gomock.Any(), uuid.Nil, "", storage.GroupByServiceName, true).
+		Return([]models.BreakdownItem{{Key: "Netflix", Currency: "RUB", Count: 1, Total: 150}}, nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/reports/breakdown?from=01-2025&to=03-2025&group_by=service_name&prorate=true", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /reports/breakdown", handler.Breakdown)
+
+	mux.ServeHTTP(w, r)
+
+	var resp struct {
+		Data handlers.BreakdownResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(150), resp.Data.Total)
+	assert.Equal(t, true, resp.Data.Prorated)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"testovoe/internal/response"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+type SearchQuery struct {
+	Q      string `validate:"required,max=200"`
+	Limit  string `validate:"omitempty,number"`
+	Offset string `validate:"omitempty,number"`
+}
+
+type SearchResultResponse struct {
+	SubscriptionResponse
+	Rank float64 `json:"rank"`
+}
+
+type SearchResponse struct {
+	Results []SearchResultResponse `json:"results"`
+	HasMore bool                   `json:"has_more"`
+}
+
+// Search finds the subscriptions whose service name looks like q, even
+// misspelled or written in Cyrillic, best matches first. The filters of
+// List apply. Pages are selected with limit (default 20, at most 100, 0 for
+// the most) and offset.
+func (h *SubscriptionHandler) Search(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := SearchQuery{
+		Q:      r.URL.Query().Get("q"),
+		Limit:  r.URL.Query().Get("limit"),
+		Offset: r.URL.Query().Get("offset"),
+	}
+	if !h.validateInput(w, r, query) {
+		return
+	}
+	filter, ok := h.readListFilter(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, ok := parseLimitOffset(w, query.Limit, query.Offset, DefaultSearchLimit, MaxSearchLimit)
+	if !ok {
+		return
+	}
+	if limit == 0 {
+		limit = MaxSearchLimit
+	}
+
+	results, hasMore, err := h.store.Subscription.Search(ctx, query.Q, filter, limit, offset)
+	if err != nil {
+		slog.ErrorContext(ctx, "search subscriptions", "error", err)
+		response.ServerError(w, "Internal server error")
+		return
+	}
+
+	resp := SearchResponse{Results: []SearchResultResponse{}, HasMore: hasMore}
+	for _, result := range results {
+		resp.Results = append(resp.Results, SearchResultResponse{
+			SubscriptionResponse: newSubscriptionResponse(&result.Subscription),
+			Rank:                 result.Rank,
+		})
+	}
+
+	response.Success(w, resp)
+}
@@ -0,0 +1,71 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/handlers"
+	"testovoe/internal/models"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSearch(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	filter := models.SubscriptionFilter{
+		UserID:     "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+		ActiveFrom: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+		ActiveTo:   time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC),
+	}
+	mockedSubscriptionStorage.EXPECT().
+		Search(gomock.Any(), "Яндекс плюс", filter, handlers.DefaultSearchLimit, 0).
+		Return([]models.SearchResult{
+			{Subscription: models.Subscription{ID: 2, ServiceName: "Yandex Plus"}, Rank: 0.8},
+			{Subscription: models.Subscription{ID: 1, ServiceName: "yandex"}, Rank: 0.4},
+		}, true, nil).
+		Times(1)
+
+	var resp handlers.SearchResponse
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/search?q=%D0%AF%D0%BD%D0%B4%D0%B5%D0%BA%D1%81+%D0%BF%D0%BB%D1%8E%D1%81&user_id=60601fee-2bf1-4721-ae6f-7636e79a0cba&active_at=03-2025", nil)
+	w := serve("GET /subscriptions/search", handler.Search, r, &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, resp.HasMore)
+	assert.Equal(t, 2, len(resp.Results))
+	assert.Equal(t, "Yandex Plus", resp.Results[0].ServiceName)
+	assert.Equal(t, 0.8, resp.Results[0].Rank)
+}
+
+func TestSearchPage(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Search(gomock.Any(), "netflix", models.SubscriptionFilter{}, 5, 10).
+		Return(nil, false, nil).
+		Times(1)
+
+	var resp handlers.SearchResponse
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/search?q=netflix&limit=5&offset=10", nil)
+	w := serve("GET /subscriptions/search", handler.Search, r, &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 0, len(resp.Results))
+	assert.NotEqual(t, nil, resp.Results)
+}
+
+func TestSearchInvalidQuery(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Search(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	for _, query := range []string{"", "?q=", "?q=netflix&limit=-1", "?q=netflix&limit=100000000", "?q=netflix&status=paused"} {
+		r := httptest.NewRequest(http.MethodGet, "/subscriptions/search"+query, nil)
+		w := serve("GET /subscriptions/search", handler.Search, r, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
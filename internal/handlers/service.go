@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testovoe/internal/models"
+	"testovoe/internal/response"
+	"testovoe/internal/storage"
+	"testovoe/internal/utils"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ServiceHandler manages the service catalog. Subscriptions are linked to
+// the services by service_id, or by name and aliases when created without
+// one.
+type ServiceHandler struct {
+	store    *storage.Storage
+	validate *validator.Validate
+}
+
+func NewServiceHandler(store *storage.Storage, validate *validator.Validate) *ServiceHandler {
+	return &ServiceHandler{store: store, validate: validate}
+}
+
+type ServicePayload struct {
+	Name         string  `json:"name" validate:"required,max=200"`
+	Category     *string `json:"category,omitempty" validate:"omitnil,oneof=streaming music gaming cloud software news education fitness other"`
+	Homepage     *string `json:"homepage,omitempty" validate:"omitnil,http_url"`
+	DefaultPrice *int    `json:"default_price,omitempty" validate:"omitnil,min=0"`
+	Currency     string  `json:"currency,omitempty" validate:"omitempty,iso4217"`
+	// Aliases are the other names of the service, like "Яндекс Плюс" for
+	// "Yandex Plus". Subscriptions named after an alias are linked too.
+	Aliases []string `json:"aliases,omitempty" validate:"max=50,dive,required,max=200"`
+}
+
+type ServiceResponse struct {
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	Category     *string  `json:"category,omitempty"`
+	Homepage     *string  `json:"homepage,omitempty"`
+	DefaultPrice *int     `json:"default_price,omitempty"`
+	Currency     string   `json:"currency"`
+	Aliases      []string `json:"aliases"`
+}
+
+func newServiceResponse(svc *models.Service) ServiceResponse {
+	resp := ServiceResponse{
+		ID:       svc.ID,
+		Name:     svc.Name,
+		Currency: svc.Currency,
+		Aliases:  svc.Aliases,
+	}
+	if svc.Category.Valid {
+		resp.Category = utils.String(svc.Category.String)
+	}
+	if svc.Homepage.Valid {
+		resp.Homepage = utils.String(svc.Homepage.String)
+	}
+	if svc.DefaultPrice.Valid {
+		resp.DefaultPrice = utils.Int(int(svc.DefaultPrice.Int32))
+	}
+	if resp.Aliases == nil {
+		resp.Aliases = []string{}
+	}
+	return resp
+}
+
+func serviceFromPayload(payload ServicePayload) *models.Service {
+	svc := &models.Service{
+		Name:     payload.Name,
+		Currency: payload.Currency,
+		Aliases:  payload.Aliases,
+	}
+	if svc.Currency == "" {
+		svc.Currency = "RUB"
+	}
+	if payload.Category != nil {
+		svc.Category = sql.NullString{String: *payload.Category, Valid: true}
+	}
+	if payload.Homepage != nil {
+		svc.Homepage = sql.NullString{String: *payload.Homepage, Valid: true}
+	}
+	if payload.DefaultPrice != nil {
+		svc.DefaultPrice = sql.NullInt32{Int32: int32(*payload.DefaultPrice), Valid: true}
+	}
+	return svc
+}
+
+// readServicePayload reads and validates a service, writing the error
+// response if it is invalid.
+func (h *ServiceHandler) readServicePayload(w http.ResponseWriter, r *http.Request) (*models.Service, bool) {
+	ctx := r.Context()
+
+	var payload ServicePayload
+	if err := utils.ReadJSON(r, &payload); err != nil {
+		slog.ErrorContext(ctx, "read json", "error", err)
+		response.BadRequest(w, "Bad request")
+		return nil, false
+	}
+
+	if err := h.validate.Struct(payload); err != nil {
+		slog.ErrorContext(ctx, "validate", "error", err)
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.ValidationError(w, verrs)
+		} else {
+			response.BadRequest(w, "Invalid input")
+		}
+		return nil, false
+	}
+
+	return serviceFromPayload(payload), true
+}
+
+// Create adds a service to the catalog. The subscriptions named after it
+// or one of its aliases are linked to it. A name or an alias taken by
+// another service is a conflict.
+func (h *ServiceHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	svc, ok := h.readServicePayload(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := h.store.Service.Create(ctx, svc)
+	if err != nil {
+		slog.ErrorContext(ctx, "create service", "error", err)
+		if errors.Is(err, storage.ErrServiceExists) {
+			response.Conflict(w, "Service name or alias already exists")
+			return
+		}
+		response.ServerError(w, "Internal server error")
+		return
+	}
+
+	response.Created(w, map[string]any{
+		"id": id,
+	})
+}
+
+func (h *ServiceHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		slog.ErrorContext(ctx, "parse id", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	svc, err := h.store.Service.Get(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "get service", "error", err)
+		if errors.Is(err, storage.ErrNotFound) {
+			response.NotFound(w, "Not found")
+			return
+		}
+		response.ServerError(w, "Internal server error")
+		return
+	}
+
+	response.Success(w, newServiceResponse(svc))
+}
+
+// Update replaces a service and its aliases. The subscriptions named after
+// its new name or aliases are linked to it; the linked ones keep their
+// names.
+func (h *ServiceHandler) Update(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		slog.ErrorContext(ctx, "parse id", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	svc, ok := h.readServicePayload(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.Service.Update(ctx, id, svc); err != nil {
+		slog.ErrorContext(ctx, "update service", "error", err)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			response.NotFound(w, "Not found")
+		case errors.Is(err, storage.ErrServiceExists):
+			response.Conflict(w, "Service name or alias already exists")
+		default:
+			response.ServerError(w, "Internal server error")
+		}
+		return
+	}
+
+	svc.ID = id
+	response.Success(w, newServiceResponse(svc))
+}
+
+// Delete removes a service from the catalog. Its subscriptions are kept,
+// unlinked.
+func (h *ServiceHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		slog.ErrorContext(ctx, "parse id", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	if err := h.store.Service.Delete(ctx, id); err != nil {
+		slog.ErrorContext(ctx, "delete service", "error", err)
+		if errors.Is(err, storage.ErrNotFound) {
+			response.NotFound(w, "Not found")
+			return
+		}
+		response.ServerError(w, "Internal server error")
+		return
+	}
+
+	response.NoContent(w)
+}
+
+func (h *ServiceHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	services, err := h.store.Service.List(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "list services", "error", err)
+		response.ServerError(w, "Internal server error")
+		return
+	}
+
+	resp := make([]ServiceResponse, 0, len(services))
+	for i := range services {
+		resp = append(resp, newServiceResponse(&services[i]))
+	}
+	response.Success(w, resp)
+}
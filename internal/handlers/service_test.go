@@ -0,0 +1,223 @@
+package handlers_test
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testovoe/internal/handlers"
+	"testovoe/internal/models"
+	"testovoe/internal/storage"
+	mock_storage "testovoe/internal/storage/mocks"
+	"testovoe/internal/utils"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/mock/gomock"
+)
+
+func setupServiceTest(t *testing.T) (*handlers.ServiceHandler, *mock_storage.MockServiceStorage) {
+	t.Helper()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	mockedServiceStorage := mock_storage.NewMockServiceStorage(ctrl)
+	handler := handlers.NewServiceHandler(
+		&storage.Storage{Service: mockedServiceStorage},
+		validator.New(validator.WithRequiredStructEnabled()),
+	)
+
+	return handler, mockedServiceStorage
+}
+
+func TestCreateService(t *testing.T) {
+	handler, mockedServiceStorage := setupServiceTest(t)
+
+	mockedServiceStorage.EXPECT().
+		Create(gomock.Any(), &models.Service{
+			Name:         "Yandex Plus",
+			Category:     sql.NullString{String: "music", Valid: true},
+			DefaultPrice: sql.NullInt32{Int32: 400, Valid: true},
+			Currency:     "RUB",
+			Aliases:      []string{"Яндекс Плюс"},
+		}).
+		Return(3, nil).
+		Times(1)
+
+	r := httptest.NewRequest(http.MethodPost, "/services", strings.NewReader(`{
+		"name": "Yandex Plus",
+		"category": "music",
+		"default_price": 400,
+		"aliases": ["Яндекс Плюс"]
+	}`))
+	w := serve("POST /services", handler.Create, r, nil)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestCreateServiceInvalid(t *testing.T) {
+	handler, mockedServiceStorage := setupServiceTest(t)
+
+	mockedServiceStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	for _, body := range []string{
+		`{"category": "music"}`,
+		`{"name": "Yandex Plus", "homepage": "plus"}`,
+		`{"name": "Yandex Plus", "default_price": -1}`,
+		`{"name": "Yandex Plus", "aliases": [""]}`,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/services", strings.NewReader(body))
+		w := serve("POST /services", handler.Create, r, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestCreateServiceExists(t *testing.T) {
+	handler, mockedServiceStorage := setupServiceTest(t)
+
+	mockedServiceStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Return(0, storage.ErrServiceExists).
+		Times(1)
+
+	r := httptest.NewRequest(http.MethodPost, "/services", strings.NewReader(`{"name": "Yandex Plus"}`))
+	w := serve("POST /services", handler.Create, r, nil)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestGetService(t *testing.T) {
+	handler, mockedServiceStorage := setupServiceTest(t)
+
+	mockedServiceStorage.EXPECT().
+		Get(gomock.Any(), 3).
+		Return(&models.Service{
+			ID:       3,
+			Name:     "Yandex Plus",
+			Homepage: sql.NullString{String: "https://plus.yandex.ru", Valid: true},
+			Currency: "RUB",
+		}, nil).
+		Times(1)
+	mockedServiceStorage.EXPECT().
+		Get(gomock.Any(), 4).
+		Return(nil, storage.ErrNotFound).
+		Times(1)
+
+	var svc handlers.ServiceResponse
+	r := httptest.NewRequest(http.MethodGet, "/services/3", nil)
+	w := serve("GET /services/{id}", handler.Get, r, &svc)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, "Yandex Plus", svc.Name)
+	assert.Equal(t, utils.String("https://plus.yandex.ru"), svc.Homepage)
+	assert.Equal(t, (*int)(nil), svc.DefaultPrice)
+	assert.Equal(t, []string{}, svc.Aliases)
+
+	r = httptest.NewRequest(http.MethodGet, "/services/4", nil)
+	w = serve("GET /services/{id}", handler.Get, r, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUpdateService(t *testing.T) {
+	handler, mockedServiceStorage := setupServiceTest(t)
+
+	mockedServiceStorage.EXPECT().
+		Update(gomock.Any(), 3, &models.Service{Name: "Яндекс Плюс", Currency: "RUB"}).
+		Return(nil).
+		Times(1)
+	mockedServiceStorage.EXPECT().
+		Update(gomock.Any(), 4, gomock.Any()).
+		Return(storage.ErrNotFound).
+		Times(1)
+
+	r := httptest.NewRequest(http.MethodPut, "/services/3", strings.NewReader(`{"name": "Яндекс Плюс"}`))
+	w := serve("PUT /services/{id}", handler.Update, r, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	r = httptest.NewRequest(http.MethodPut, "/services/4", strings.NewReader(`{"name": "Яндекс Плюс"}`))
+	w = serve("PUT /services/{id}", handler.Update, r, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeleteService(t *testing.T) {
+	handler, mockedServiceStorage := setupServiceTest(t)
+
+	mockedServiceStorage.EXPECT().
+		Delete(gomock.Any(), 3).
+		Return(nil).
+		Times(1)
+	mockedServiceStorage.EXPECT().
+		Delete(gomock.Any(), 4).
+		Return(storage.ErrNotFound).
+		Times(1)
+
+	r := httptest.NewRequest(http.MethodDelete, "/services/3", nil)
+	w := serve("DELETE /services/{id}", handler.Delete, r, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	r = httptest.NewRequest(http.MethodDelete, "/services/4", nil)
+	w = serve("DELETE /services/{id}", handler.Delete, r, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestListServices(t *testing.T) {
+	handler, mockedServiceStorage := setupServiceTest(t)
+
+	mockedServiceStorage.EXPECT().
+		List(gomock.Any()).
+		Return([]models.Service{{ID: 1, Name: "Netflix", Currency: "RUB"}}, nil).
+		Times(1)
+
+	var resp []handlers.ServiceResponse
+	r := httptest.NewRequest(http.MethodGet, "/services", nil)
+	w := serve("GET /services", handler.List, r, &resp)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, 1, len(resp))
+
+	mockedServiceStorage.EXPECT().
+		List(gomock.Any()).
+		Return(nil, errors.New("connection refused")).
+		Times(1)
+
+	r = httptest.NewRequest(http.MethodGet, "/services", nil)
+	w = serve("GET /services", handler.List, r, nil)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestCreateSubscriptionWithService(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, sub *models.Subscription) (int, error) {
+			assert.Equal(t, sql.NullInt32{Int32: 3, Valid: true}, sub.ServiceID)
+			return 0, storage.ErrServiceNotFound
+		}).
+		Times(1)
+
+	// The service name comes from the catalog.
+	body := `{
+		"service_id": 3,
+		"start_date": "01-2006",
+		"price": 100,
+		"user_id": "550e8400-e29b-41d4-a716-446655440000"
+	}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	handler.Create(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "Service not found"))
+}
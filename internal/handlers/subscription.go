@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"testovoe/internal/currency"
 	"testovoe/internal/models"
 	"testovoe/internal/response"
 	"testovoe/internal/storage"
@@ -16,34 +22,302 @@ import (
 	"github.com/google/uuid"
 )
 
+var (
+	ErrInvalidPeriod    = errors.New("period end is before period start")
+	ErrTrialBeforeStart = errors.New("trial end is before start date")
+)
+
 type SubscriptionHandler struct {
 	store    *storage.Storage
 	validate *validator.Validate
+	rates    currency.RateProvider
 }
 
-
 func NewSubscriptionHandler(
 	store *storage.Storage,
 	validate *validator.Validate,
+	rates currency.RateProvider,
 ) *SubscriptionHandler {
-	return &SubscriptionHandler{store: store, validate: validate}
+	return &SubscriptionHandler{store: store, validate: validate, rates: rates}
 }
 
 type SubscriptionResponse struct {
 	ID          int       `json:"id"`
+	ServiceID   *int      `json:"service_id,omitempty"`
 	ServiceName string    `json:"service_name"`
 	Price       int       `json:"price"`
+	Currency    string    `json:"currency"`
 	UserID      uuid.UUID `json:"user_id"`
 	StartDate   string    `json:"start_date"`
 	EndDate     *string   `json:"end_date,omitempty"`
+
+	BillingPeriod         models.BillingPeriod `json:"billing_period"`
+	BillingIntervalMonths *int                 `json:"billing_interval_months,omitempty"`
+
+	TrialEnd *string `json:"trial_end,omitempty"`
+	InTrial  bool    `json:"in_trial"`
+	Paused   bool    `json:"paused"`
+
+	Category *string         `json:"category,omitempty"`
+	Tags     []string        `json:"tags"`
+	Metadata json.RawMessage `json:"metadata"`
+	Notes    *string         `json:"notes,omitempty"`
+
+	// DeletedAt is set for the subscriptions in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// ETag is the entity tag of the subscription, the same as in the ETag
+	// header of Get, to be sent back in If-Match.
+	ETag string `json:"etag"`
+}
+
+func newSubscriptionResponse(sub *models.Subscription) SubscriptionResponse {
+	var endDateFormated *string = nil
+	if sub.EndDate.Valid {
+		endDateFormated = utils.String(utils.FormatEndDate(sub.EndDate.Time))
+	}
+
+	var billingInterval *int
+	if sub.BillingIntervalMonths.Valid {
+		billingInterval = utils.Int(int(sub.BillingIntervalMonths.Int32))
+	}
+
+	var trialEnd *string
+	if sub.TrialEnd.Valid {
+		trialEnd = utils.String(utils.FormatEndDate(sub.TrialEnd.Time))
+	}
+
+	var serviceID *int
+	if sub.ServiceID.Valid {
+		serviceID = utils.Int(int(sub.ServiceID.Int32))
+	}
+
+	var category *string
+	if sub.Category.Valid {
+		category = utils.String(sub.Category.String)
+	}
+	tags := sub.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	metadata := sub.Metadata
+	if metadata == nil {
+		metadata = json.RawMessage("{}")
+	}
+	var notes *string
+	if sub.Notes.Valid {
+		notes = utils.String(sub.Notes.String)
+	}
+	var deletedAt *time.Time
+	if sub.DeletedAt.Valid {
+		deletedAt = &sub.DeletedAt.Time
+	}
+
+	return SubscriptionResponse{
+		ID:          sub.ID,
+		ServiceID:   serviceID,
+		ServiceName: sub.ServiceName,
+		Price:       sub.Price,
+		Currency:    sub.Currency,
+		UserID:      sub.UserID,
+		StartDate:   utils.FormatStartDate(sub.StartDate),
+		EndDate:     endDateFormated,
+
+		BillingPeriod:         sub.BillingPeriod,
+		BillingIntervalMonths: billingInterval,
+
+		TrialEnd: trialEnd,
+		InTrial:  sub.InTrial(time.Now()),
+		Paused:   sub.Paused,
+
+		Category: category,
+		Tags:     tags,
+		Metadata: metadata,
+		Notes:    notes,
+
+		DeletedAt: deletedAt,
+
+		ETag: etag(sub.Version),
+	}
+}
+
+// billingFromPayload applies the default billing period and drops the
+// interval unless the period is custom.
+func billingFromPayload(period string, intervalMonths *int) (models.BillingPeriod, sql.NullInt32) {
+	if period == "" {
+		return models.BillingMonthly, sql.NullInt32{}
+	}
+	if models.BillingPeriod(period) != models.BillingCustom || intervalMonths == nil {
+		return models.BillingPeriod(period), sql.NullInt32{}
+	}
+	return models.BillingCustom, sql.NullInt32{Int32: int32(*intervalMonths), Valid: true}
+}
+
+// trialFromPayload returns the last day of the trial given either as its
+// length in months from the start date or as its end date.
+func trialFromPayload(startDate time.Time, months *int, end *string) (sql.NullTime, error) {
+	var trialEnd time.Time
+	switch {
+	case months != nil:
+		trialEnd = utils.AddMonths(startDate, *months).AddDate(0, 0, -1)
+	case end != nil:
+		var err error
+		trialEnd, err = utils.ParseEndDate(*end)
+		if err != nil {
+			return sql.NullTime{}, err
+		}
+	default:
+		return sql.NullTime{}, nil
+	}
+
+	if trialEnd.Before(startDate) {
+		return sql.NullTime{}, ErrTrialBeforeStart
+	}
+	return sql.NullTime{Time: trialEnd, Valid: true}, nil
+}
+
+// normalizeTags lowercases and trims the tags and drops the empty and the
+// repeated ones, keeping the order.
+func normalizeTags(tags []string) []string {
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+// payloadError writes the error response for a payload that passed
+// validation but can't be converted into a subscription.
+func payloadError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "parse payload", "error", err)
+	if errors.Is(err, ErrTrialBeforeStart) {
+		response.BadRequest(w, "Trial ends before start date")
+		return
+	}
+	response.BadRequest(w, "Bad request")
+}
+
+// subscriptionFromPayload parses the dates of a validated payload and
+// applies the defaults. Update uses the same payload.
+func subscriptionFromPayload(payload CreateSubscriptionPayload) (*models.Subscription, error) {
+	startDate, err := utils.ParseStartDate(payload.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	var endDate sql.NullTime
+	if payload.EndDate != nil {
+		end, err := utils.ParseEndDate(*payload.EndDate)
+		if err != nil {
+			return nil, err
+		}
+		endDate = sql.NullTime{Time: end, Valid: true}
+	}
+
+	if payload.Currency == "" {
+		payload.Currency = currency.Default
+	}
+	billingPeriod, billingInterval := billingFromPayload(payload.BillingPeriod, payload.BillingIntervalMonths)
+
+	trialEnd, err := trialFromPayload(startDate, payload.TrialMonths, payload.TrialEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	var serviceID sql.NullInt32
+	if payload.ServiceID != nil {
+		serviceID = sql.NullInt32{Int32: int32(*payload.ServiceID), Valid: true}
+	}
+
+	var category sql.NullString
+	if payload.Category != nil {
+		category = sql.NullString{String: *payload.Category, Valid: true}
+	}
+
+	var notes sql.NullString
+	if payload.Notes != nil {
+		notes = sql.NullString{String: *payload.Notes, Valid: true}
+	}
+	metadata := payload.Metadata
+	if bytes.Equal(bytes.TrimSpace(metadata), []byte("null")) {
+		metadata = nil
+	}
+
+	return &models.Subscription{
+		ServiceID:   serviceID,
+		ServiceName: payload.ServiceName,
+		Price:       payload.Price,
+		Currency:    payload.Currency,
+		UserID:      payload.UserID,
+		StartDate:   startDate,
+		EndDate:     endDate,
+
+		BillingPeriod:         billingPeriod,
+		BillingIntervalMonths: billingInterval,
+
+		TrialEnd: trialEnd,
+
+		Category: category,
+		Tags:     normalizeTags(payload.Tags),
+		Metadata: metadata,
+		Notes:    notes,
+	}, nil
+}
+
+// keepStoredFields gives an updated subscription the stored currency,
+// billing period and trial when the payload leaves them out, rather than
+// the defaults of a new subscription. The trial can be removed with PATCH.
+func keepStoredFields(sub *models.Subscription, payload CreateSubscriptionPayload, stored *models.Subscription) error {
+	if payload.Currency == "" {
+		sub.Currency = stored.Currency
+	}
+	if payload.BillingPeriod == "" {
+		sub.BillingPeriod = stored.BillingPeriod
+		sub.BillingIntervalMonths = stored.BillingIntervalMonths
+	}
+	if payload.TrialMonths == nil && payload.TrialEnd == nil && stored.TrialEnd.Valid {
+		if stored.TrialEnd.Time.Before(sub.StartDate) {
+			return ErrTrialBeforeStart
+		}
+		sub.TrialEnd = stored.TrialEnd
+	}
+	return nil
+}
+
+// omitsStoredFields tells whether the payload leaves out a field that
+// keepStoredFields takes from the stored subscription.
+func omitsStoredFields(payload CreateSubscriptionPayload) bool {
+	return payload.Currency == "" || payload.BillingPeriod == "" || (payload.TrialMonths == nil && payload.TrialEnd == nil)
 }
 
 type CreateSubscriptionPayload struct {
-	ServiceName string    `json:"service_name" validate:"required"`
-	Price       int       `json:"price" validate:"required"`
+	// ServiceID links the subscription to the service catalog, it is
+	// named after the service if ServiceName is empty. Without it, the
+	// service named ServiceName is linked if there is one.
+	ServiceID   *int      `json:"service_id,omitempty" validate:"omitnil,min=1"`
+	ServiceName string    `json:"service_name" validate:"required_without=ServiceID"`
+	Price       int       `json:"price" validate:"min=0,max=2147483647"`
+	Currency    string    `json:"currency,omitempty" validate:"omitempty,iso4217"`
 	UserID      uuid.UUID `json:"user_id" validate:"required,uuid"`
-	StartDate   string    `json:"start_date" validate:"required,mm_yyyy"`
-	EndDate     *string   `json:"end_date,omitempty" validate:"omitempty,mm_yyyy"`
+	StartDate   string    `json:"start_date" validate:"required,mm_yyyy_or_date"`
+	EndDate     *string   `json:"end_date,omitempty" validate:"omitempty,mm_yyyy_or_date"`
+
+	BillingPeriod         string `json:"billing_period,omitempty" validate:"omitempty,oneof=monthly quarterly yearly weekly custom"`
+	BillingIntervalMonths *int   `json:"billing_interval_months,omitempty" validate:"required_if=BillingPeriod custom,omitempty,min=1,max=120"`
+
+	TrialMonths *int    `json:"trial_months,omitempty" validate:"omitempty,min=1,max=120,excluded_with=TrialEnd"`
+	TrialEnd    *string `json:"trial_end,omitempty" validate:"omitempty,mm_yyyy_or_date"`
+
+	Category *string  `json:"category,omitempty" validate:"omitnil,oneof=streaming music gaming cloud software news education fitness other"`
+	Tags     []string `json:"tags,omitempty" validate:"max=20,dive,required,max=50"`
+
+	// Metadata is a JSON object of at most validators.MaxMetadataBytes,
+	// nested at most validators.MaxMetadataDepth levels deep.
+	Metadata json.RawMessage `json:"metadata,omitempty" validate:"omitempty,metadata"`
+	Notes    *string         `json:"notes,omitempty" validate:"omitnil,max=2000"`
 }
 
 // Create handles the creation of a new subscription.
@@ -70,41 +344,23 @@ func (h *SubscriptionHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	startDate, err := utils.ParseMonthYear(payload.StartDate)
+	sub, err := subscriptionFromPayload(payload)
 	if err != nil {
-		slog.ErrorContext(ctx, "parse start date", "error", err)
-		response.BadRequest(w, "Bad request")
+		payloadError(w, r, err)
 		return
 	}
 
-	var endDate sql.NullTime = sql.NullTime{
-		Valid: false,
-	}
-	if payload.EndDate != nil {
-		end, err := utils.ParseMonthYear(*payload.EndDate)
-		if err != nil {
-			slog.ErrorContext(ctx, "parse end date", "error", err)
-			response.BadRequest(w, "Bad request")
-			return
-		}
-		endDate = sql.NullTime{
-			Time:  end,
-			Valid: true,
-		}
-	}
-
-	sub := &models.Subscription{
-		ServiceName: payload.ServiceName,
-		Price:       payload.Price,
-		UserID:      payload.UserID,
-		StartDate:   startDate,
-		EndDate:     endDate,
-	}
-
 	id, err := h.store.Subscription.Create(ctx, sub)
 	if err != nil {
 		slog.ErrorContext(ctx, "create subscription", "error", err)
-		response.ServerError(w, "Internal server error")
+		switch {
+		case errors.Is(err, storage.ErrServiceNotFound):
+			response.BadRequest(w, "Service not found")
+		case errors.Is(err, storage.ErrInvalidSubscription):
+			response.BadRequest(w, "Subscription is invalid")
+		default:
+			response.ServerError(w, "Internal server error")
+		}
 		return
 	}
 
@@ -140,34 +396,49 @@ func (h *SubscriptionHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var endDateFormated *string = nil
-	if sub.EndDate.Valid {
-		endDateFormated = utils.String(sub.EndDate.Time.Format("01-2006"))
+	w.Header().Set("ETag", etag(sub.Version))
+	if notModified(r, sub.Version) {
+		response.NotModified(w)
+		return
 	}
 
-	response.Success(w, SubscriptionResponse{
-		ID:          sub.ID,
-		ServiceName: sub.ServiceName,
-		Price:       sub.Price,
-		UserID:      sub.UserID,
-		StartDate:   sub.StartDate.Format("01-2006"),
-		EndDate:     endDateFormated,
-	})
+	response.Success(w, newSubscriptionResponse(sub))
 }
 
 type UpdateSubscriptionPayload struct {
-	ServiceName string    `json:"service_name" validate:"required"`
-	Price       int       `json:"price" validate:"required"`
+	// ServiceID links the subscription to the service catalog, it is
+	// named after the service if ServiceName is empty. Without it, the
+	// service named ServiceName is linked if there is one.
+	ServiceID   *int      `json:"service_id,omitempty" validate:"omitnil,min=1"`
+	ServiceName string    `json:"service_name" validate:"required_without=ServiceID"`
+	Price       int       `json:"price" validate:"min=0,max=2147483647"`
+	Currency    string    `json:"currency,omitempty" validate:"omitempty,iso4217"`
 	UserID      uuid.UUID `json:"user_id" validate:"required,uuid"`
-	StartDate   string    `json:"start_date" validate:"required,mm_yyyy"`
-	EndDate     *string   `json:"end_date,omitempty" validate:"omitempty,mm_yyyy"`
+	StartDate   string    `json:"start_date" validate:"required,mm_yyyy_or_date"`
+	EndDate     *string   `json:"end_date,omitempty" validate:"omitempty,mm_yyyy_or_date"`
+
+	BillingPeriod         string `json:"billing_period,omitempty" validate:"omitempty,oneof=monthly quarterly yearly weekly custom"`
+	BillingIntervalMonths *int   `json:"billing_interval_months,omitempty" validate:"required_if=BillingPeriod custom,omitempty,min=1,max=120"`
+
+	TrialMonths *int    `json:"trial_months,omitempty" validate:"omitempty,min=1,max=120,excluded_with=TrialEnd"`
+	TrialEnd    *string `json:"trial_end,omitempty" validate:"omitempty,mm_yyyy_or_date"`
+
+	Category *string  `json:"category,omitempty" validate:"omitnil,oneof=streaming music gaming cloud software news education fitness other"`
+	Tags     []string `json:"tags,omitempty" validate:"max=20,dive,required,max=50"`
+
+	// Metadata is a JSON object of at most validators.MaxMetadataBytes,
+	// nested at most validators.MaxMetadataDepth levels deep.
+	Metadata json.RawMessage `json:"metadata,omitempty" validate:"omitempty,metadata"`
+	Notes    *string         `json:"notes,omitempty" validate:"omitnil,max=2000"`
 }
 
 // Update handles the HTTP request to update an existing subscription.
 // It parses the subscription ID from the URL, decodes the JSON payload,
 // validates the payload, parses the start and end dates, and updates
 // the subscription in the database. It returns appropriate HTTP responses
-// based on the outcome of these operations.
+// based on the outcome of these operations. The currency, billing period
+// and trial left out of the payload keep their stored values. The response
+// holds the subscription as stored after the update.
 func (h *SubscriptionHandler) Update(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id := r.PathValue("id")
@@ -178,6 +449,11 @@ func (h *SubscriptionHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
 	var payload UpdateSubscriptionPayload
 	if err := utils.ReadJSON(r, &payload); err != nil {
 		response.BadRequest(w, "Bad request")
@@ -189,65 +465,53 @@ func (h *SubscriptionHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	startDate, err := utils.ParseMonthYear(payload.StartDate)
+	sub, err := subscriptionFromPayload(CreateSubscriptionPayload(payload))
 	if err != nil {
-		slog.ErrorContext(ctx, "parse start date", "error", err)
-		response.BadRequest(w, "Bad request")
+		payloadError(w, r, err)
 		return
 	}
-
-	var endDate sql.NullTime = sql.NullTime{
-		Valid: false,
-	}
-	if payload.EndDate != nil {
-		end, err := utils.ParseMonthYear(*payload.EndDate)
+	if omitsStoredFields(CreateSubscriptionPayload(payload)) {
+		stored, err := h.store.Subscription.Get(ctx, intID)
 		if err != nil {
-			slog.ErrorContext(ctx, "parse end date", "error", err)
-			response.BadRequest(w, "Bad request")
+			if errors.Is(err, storage.ErrNotFound) {
+				response.NotFound(w, "Not found")
+				return
+			}
+			slog.ErrorContext(ctx, "get subscription", "error", err)
+			response.ServerError(w, "Internal server error")
 			return
 		}
-		endDate = sql.NullTime{
-			Time:  end,
-			Valid: true,
+		if err := keepStoredFields(sub, CreateSubscriptionPayload(payload), stored); err != nil {
+			payloadError(w, r, err)
+			return
 		}
 	}
+	sub.ID = intID
+	sub.Version = version
 
-	sub := &models.Subscription{
-		ID:          intID,
-		ServiceName: payload.ServiceName,
-		Price:       payload.Price,
-		UserID:      payload.UserID,
-		StartDate:   startDate,
-		EndDate:     endDate,
-	}
-
-	if err := h.store.Subscription.Update(ctx, intID, sub); err != nil {
-		if errors.Is(err, storage.ErrNotFound) {
+	updated, err := h.store.Subscription.Update(ctx, intID, sub)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
 			response.NotFound(w, "Not found")
-			return
+		case errors.Is(err, storage.ErrServiceNotFound):
+			response.BadRequest(w, "Service not found")
+		case errors.Is(err, storage.ErrStaleVersion):
+			response.PreconditionFailed(w, "Subscription has been modified")
+		default:
+			response.ServerError(w, "Internal server error")
 		}
-		response.ServerError(w, "Internal server error")
 		return
 	}
 
-	var endDateFormated *string = nil
-	if sub.EndDate.Valid {
-		endDateFormated = utils.String(sub.EndDate.Time.Format("01-2006"))
-	}
-
-	response.Success(w, SubscriptionResponse{
-		ID:          sub.ID,
-		ServiceName: sub.ServiceName,
-		Price:       sub.Price,
-		UserID:      sub.UserID,
-		StartDate:   sub.StartDate.Format("01-2006"),
-		EndDate:     endDateFormated,
-	})
+	w.Header().Set("ETag", etag(updated.Version))
+	response.Success(w, newSubscriptionResponse(updated))
 }
 
-// Delete removes a subscription by its ID.
+// Delete moves a subscription to the trash by its ID.
 // It extracts the ID from the request path, converts it to an integer,
 // and attempts to delete the corresponding subscription from the store.
+// A deleted subscription can be restored until it is purged, see Restore.
 // If the ID is invalid, it responds with a bad request error.
 // If the subscription is not found, it responds with a not found error.
 // If an internal error occurs during deletion, it responds with a server error.
@@ -262,12 +526,20 @@ func (h *SubscriptionHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.store.Subscription.Delete(ctx, intID); err != nil {
-		if errors.Is(err, storage.ErrNotFound) {
+	version, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.Subscription.Delete(ctx, intID, version); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
 			response.NotFound(w, "Not found")
-			return
+		case errors.Is(err, storage.ErrStaleVersion):
+			response.PreconditionFailed(w, "Subscription has been modified")
+		default:
+			response.ServerError(w, "Internal server error")
 		}
-		response.ServerError(w, "Internal server error")
 		return
 	}
 
@@ -279,16 +551,40 @@ func (h *SubscriptionHandler) Delete(w http.ResponseWriter, r *http.Request) {
 // Query parameters:
 //   - user_id: filters subscriptions by user ID
 //   - service_name: filters subscriptions by service name
+//   - service_name_prefix, price_min, price_max, active_at, started_after,
+//     ended_before, status: more filters, see readListFilter
+//   - sort: comma separated columns, "-" for descending, like
+//     "price,-start_date" (default: newest first)
 //   - limit: limits the number of subscriptions returned (default: 0, meaning no limit)
-//   - offset: skips the specified number of subscriptions (default: 0)
+//   - offset: skips the specified number of subscriptions (default: 0), the
+//     legacy pagination
+//   - cursor: the next_cursor of the previous page, for keyset pagination
+//
+// The response holds totals, the cost of the current month per currency,
+// and total, the same in RUB, which is left out if an exchange rate is
+// missing. The totals only apply user_id and service_name, the other
+// filters are ignored, so they can cover more than the listed subscriptions. It also holds next_cursor and has_more. Unlike offset pagination,
+// following next_cursor doesn't skip or repeat subscriptions when they are
+// added or deleted between pages, so cursor and offset can't be combined.
 //
 // If limit or offset are negative, they are reset to 0.
 // If userID is invalid, it defaults to uuid.Nil.
 // If an error occurs during processing, an appropriate HTTP error response is sent.
 func (h *SubscriptionHandler) List(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	userID := r.URL.Query().Get("user_id")
-	serviceName := r.URL.Query().Get("service_name")
+	filter, ok := h.readListFilter(w, r)
+	if !ok {
+		return
+	}
+	userID := filter.UserID
+	serviceName := filter.ServiceName
+	sort := r.URL.Query().Get("sort")
+	keys, err := parseSort(sort)
+	if err != nil {
+		slog.WarnContext(ctx, "parse sort", "error", err)
+		response.BadRequest(w, "Invalid sort: "+err.Error())
+		return
+	}
 	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
 	if err != nil {
 		slog.WarnContext(ctx, "parse limit", "error", err)
@@ -306,9 +602,26 @@ func (h *SubscriptionHandler) List(w http.ResponseWriter, r *http.Request) {
 		offset = 0
 	}
 
-	subscriptions, err := h.store.Subscription.List(ctx, userID, serviceName, limit, offset)
+	page := models.Page{Limit: limit, Offset: offset, Sort: keys}
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		if offset > 0 {
+			response.BadRequest(w, "Cursor and offset can't be combined")
+			return
+		}
+		if page.After, err = decodeCursor(c, sort); err != nil {
+			slog.WarnContext(ctx, "decode cursor", "error", err)
+			response.BadRequest(w, "Invalid cursor")
+			return
+		}
+	}
+
+	subscriptions, hasMore, err := h.store.Subscription.List(ctx, filter, page)
 	if err != nil {
 		slog.ErrorContext(ctx, "list subscriptions", "error", err)
+		if errors.Is(err, storage.ErrInvalidSort) {
+			response.BadRequest(w, "Invalid sort")
+			return
+		}
 		response.ServerError(w, "Internal server error")
 		return
 	}
@@ -319,35 +632,383 @@ func (h *SubscriptionHandler) List(w http.ResponseWriter, r *http.Request) {
 		userUUID = uuid.Nil
 	}
 
-	total, err := h.store.Subscription.TotalForPeriod(ctx, time.Now(), time.Now(), userUUID, serviceName)
+	now := time.Now()
+	totals, err := h.store.Subscription.TotalForPeriod(ctx, utils.StartOfMonth(now), utils.EndOfMonth(now), userUUID, serviceName, false)
 	if err != nil {
 		slog.ErrorContext(ctx, "list subscriptions", "error", err)
 		response.ServerError(w, "Internal server error")
 		return
 	}
 
+	if totals == nil {
+		totals = map[string]int64{}
+	}
+
 	if len(subscriptions) == 0 {
 		subscriptions = []models.Subscription{}
 	}
 
 	var resp []SubscriptionResponse
 	for _, sub := range subscriptions {
-		var endDateFormated *string = nil
-		if sub.EndDate.Valid {
-			endDateFormated = utils.String(sub.EndDate.Time.Format("01-2006"))
-		}
-		resp = append(resp, SubscriptionResponse{
-			ID:          sub.ID,
-			ServiceName: sub.ServiceName,
-			Price:       sub.Price,
-			UserID:      sub.UserID,
-			StartDate:   sub.StartDate.Format("01-2006"),
-			EndDate:     endDateFormated,
-		})
+		resp = append(resp, newSubscriptionResponse(&sub))
+	}
+
+	var nextCursor *string
+	if hasMore {
+		nextCursor = utils.String(encodeCursor(sort, &subscriptions[len(subscriptions)-1]))
 	}
 
-	response.Success(w, map[string]any{
+	data := map[string]any{
 		"subscriptions": resp,
-		"total":         total,
-	})
+		"totals":        totals,
+		"next_cursor":   nextCursor,
+		"has_more":      hasMore,
+	}
+	// A missing exchange rate only leaves out the total in RUB, the list
+	// and the per-currency totals are still returned.
+	if total, err := h.convertTotals(ctx, totals, currency.Default); err == nil {
+		data["total"] = total
+		data["currency"] = currency.Default
+	} else {
+		slog.WarnContext(ctx, "convert list total", "error", err)
+	}
+
+	response.Success(w, data)
+}
+
+// PeriodQuery holds the query parameters shared by the cost reports.
+type PeriodQuery struct {
+	From        string `validate:"required,mm_yyyy_or_date"`
+	To          string `validate:"required,mm_yyyy_or_date"`
+	UserID      string `validate:"omitempty,uuid"`
+	ServiceName string
+	Currency    string `validate:"omitempty,iso4217"`
+}
+
+// periodFilter is a validated and parsed PeriodQuery.
+type periodFilter struct {
+	From        time.Time
+	To          time.Time
+	UserID      uuid.UUID
+	ServiceName string
+	Currency    string
+}
+
+func readPeriodQuery(r *http.Request) PeriodQuery {
+	return PeriodQuery{
+		From:        r.URL.Query().Get("from"),
+		To:          r.URL.Query().Get("to"),
+		UserID:      r.URL.Query().Get("user_id"),
+		ServiceName: r.URL.Query().Get("service_name"),
+		Currency:    r.URL.Query().Get("currency"),
+	}
+}
+
+// validateInput validates v and writes the error response if it is invalid.
+// It returns false if the handler should stop.
+func (h *SubscriptionHandler) validateInput(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := h.validate.Struct(v); err != nil {
+		slog.ErrorContext(r.Context(), "validate", "error", err)
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.ValidationError(w, verrs)
+		} else {
+			response.BadRequest(w, "Invalid input")
+		}
+		return false
+	}
+	return true
+}
+
+// parsePeriodQuery parses an already validated PeriodQuery and writes the
+// error response if the period is invalid. It returns false if the handler
+// should stop.
+func parsePeriodQuery(w http.ResponseWriter, r *http.Request, query PeriodQuery) (periodFilter, bool) {
+	from, to, err := parsePeriod(query.From, query.To)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "parse period", "error", err)
+		if errors.Is(err, ErrInvalidPeriod) {
+			response.BadRequest(w, "Period end is before period start")
+			return periodFilter{}, false
+		}
+		response.BadRequest(w, "Bad request")
+		return periodFilter{}, false
+	}
+
+	filter := periodFilter{
+		From:        from,
+		To:          to,
+		UserID:      uuid.Nil,
+		ServiceName: query.ServiceName,
+		Currency:    query.Currency,
+	}
+	if query.UserID != "" {
+		filter.UserID = uuid.MustParse(query.UserID)
+	}
+	if filter.Currency == "" {
+		filter.Currency = currency.Default
+	}
+	return filter, true
+}
+
+// loadRates fetches the current exchange rates and writes the error
+// response if that fails. It returns false if the handler should stop.
+func (h *SubscriptionHandler) loadRates(w http.ResponseWriter, r *http.Request) (currency.Rates, bool) {
+	rates, err := h.rates.Rates(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "load exchange rates", "error", err)
+		response.ServerError(w, "Internal server error")
+		return nil, false
+	}
+	return rates, true
+}
+
+// conversionError writes the error response for a failed currency conversion.
+func conversionError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "convert currency", "error", err)
+	if errors.Is(err, currency.ErrUnknownCurrency) {
+		response.BadRequest(w, "No exchange rate for currency")
+		return
+	}
+	response.ServerError(w, "Internal server error")
+}
+
+// sumInCurrency converts per-currency totals to one currency and adds them
+// up, writing the error response on failure. It returns false if the handler
+// should stop.
+func (h *SubscriptionHandler) sumInCurrency(w http.ResponseWriter, r *http.Request, totals map[string]int64, to string) (int64, bool) {
+	rates, ok := h.loadRates(w, r)
+	if !ok {
+		return 0, false
+	}
+
+	total, err := rates.Sum(totals, to)
+	if err != nil {
+		conversionError(w, r, err)
+		return 0, false
+	}
+	return total, true
+}
+
+// convertTotals is sumInCurrency without the error response. The rates
+// are only loaded if one of the totals is in another currency.
+func (h *SubscriptionHandler) convertTotals(ctx context.Context, totals map[string]int64, to string) (int64, error) {
+	var total int64
+	for code, amount := range totals {
+		if code != to {
+			rates, err := h.rates.Rates(ctx)
+			if err != nil {
+				return 0, err
+			}
+			return rates.Sum(totals, to)
+		}
+		total += amount
+	}
+	return total, nil
+}
+
+type TotalQuery struct {
+	PeriodQuery
+	Prorate string `validate:"omitempty,boolean"`
+}
+
+type TotalResponse struct {
+	Total       int64      `json:"total"`
+	Currency    string     `json:"currency"`
+	From        string     `json:"from"`
+	To          string     `json:"to"`
+	UserID      *uuid.UUID `json:"user_id,omitempty"`
+	ServiceName string     `json:"service_name,omitempty"`
+	Prorated    bool       `json:"prorated,omitempty"`
+}
+
+// Total returns the summed cost of subscriptions over an arbitrary period.
+// Query parameters:
+//   - from: first day of the period, MM-YYYY for a whole month or YYYY-MM-DD (required)
+//   - to: last day of the period, MM-YYYY for a whole month or YYYY-MM-DD (required)
+//   - user_id: filters subscriptions by user ID
+//   - service_name: filters subscriptions by service name
+//   - currency: ISO-4217 currency of the result (default: RUB)
+//   - prorate: if true, the last billing cycle of a subscription ended in
+//     the middle of it is only charged for the days it was used
+//
+// Both ends of the period are inclusive. If to is before from, it responds
+// with a bad request error. Prices in other currencies are converted at the
+// current exchange rates.
+func (h *SubscriptionHandler) Total(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := TotalQuery{
+		PeriodQuery: readPeriodQuery(r),
+		Prorate:     r.URL.Query().Get("prorate"),
+	}
+	if !h.validateInput(w, r, query) {
+		return
+	}
+
+	filter, ok := parsePeriodQuery(w, r, query.PeriodQuery)
+	if !ok {
+		return
+	}
+	prorate := prorated(query.Prorate)
+
+	totals, err := h.store.Subscription.TotalForPeriod(ctx, filter.From, filter.To, filter.UserID, filter.ServiceName, prorate)
+	if err != nil {
+		slog.ErrorContext(ctx, "total for period", "error", err)
+		response.ServerError(w, "Internal server error")
+		return
+	}
+
+	total, ok := h.sumInCurrency(w, r, totals, filter.Currency)
+	if !ok {
+		return
+	}
+
+	resp := TotalResponse{
+		Total:       total,
+		Currency:    filter.Currency,
+		From:        utils.FormatStartDate(filter.From),
+		To:          utils.FormatEndDate(filter.To),
+		ServiceName: filter.ServiceName,
+		Prorated:    prorate,
+	}
+	if filter.UserID != uuid.Nil {
+		resp.UserID = &filter.UserID
+	}
+
+	response.Success(w, resp)
+}
+
+// prorated tells whether a validated prorate query parameter is set.
+func prorated(prorate string) bool {
+	return prorate != "" && utils.Must(strconv.ParseBool(prorate))
+}
+
+type TimeSeriesQuery struct {
+	PeriodQuery
+	GroupBy string `validate:"omitempty,oneof=service"`
+	Prorate string `validate:"omitempty,boolean"`
+}
+
+type ServiceTotal struct {
+	ServiceName string `json:"service_name"`
+	Total       int64  `json:"total"`
+}
+
+type MonthBucket struct {
+	Month    string         `json:"month"`
+	Total    int64          `json:"total"`
+	Services []ServiceTotal `json:"services,omitempty"`
+}
+
+type TimeSeriesResponse struct {
+	From     string        `json:"from"`
+	To       string        `json:"to"`
+	GroupBy  string        `json:"group_by,omitempty"`
+	Currency string        `json:"currency"`
+	Total    int64         `json:"total"`
+	Prorated bool          `json:"prorated,omitempty"`
+	Buckets  []MonthBucket `json:"buckets"`
+}
+
+// TimeSeries returns the spend for every calendar month of a period,
+// suitable for drawing charts.
+// Query parameters:
+//   - from, to, user_id, service_name, currency: same as for Total
+//   - group_by: "service" splits every month into per-service totals
+//   - prorate: same as for Total, a reduced charge counts in the month it
+//     is billed in
+//
+// Every month of the period gets a bucket, even if nothing was spent in it.
+// If the period starts or ends in the middle of a month, that bucket only
+// counts the days in the period.
+func (h *SubscriptionHandler) TimeSeries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := TimeSeriesQuery{
+		PeriodQuery: readPeriodQuery(r),
+		GroupBy:     r.URL.Query().Get("group_by"),
+		Prorate:     r.URL.Query().Get("prorate"),
+	}
+	if !h.validateInput(w, r, query) {
+		return
+	}
+
+	filter, ok := parsePeriodQuery(w, r, query.PeriodQuery)
+	if !ok {
+		return
+	}
+
+	groupByService := query.GroupBy == "service"
+	prorate := prorated(query.Prorate)
+	totals, err := h.store.Subscription.TimeSeries(ctx, filter.From, filter.To, filter.UserID, filter.ServiceName, groupByService, prorate)
+	if err != nil {
+		slog.ErrorContext(ctx, "time series", "error", err)
+		response.ServerError(w, "Internal server error")
+		return
+	}
+
+	rates, ok := h.loadRates(w, r)
+	if !ok {
+		return
+	}
+
+	resp := TimeSeriesResponse{
+		From:     utils.FormatStartDate(filter.From),
+		To:       utils.FormatEndDate(filter.To),
+		GroupBy:  query.GroupBy,
+		Currency: filter.Currency,
+		Prorated: prorate,
+		Buckets:  []MonthBucket{},
+	}
+	for _, item := range totals {
+		month := item.Month.Format("01-2006")
+		if n := len(resp.Buckets); n == 0 || resp.Buckets[n-1].Month != month {
+			resp.Buckets = append(resp.Buckets, MonthBucket{Month: month})
+		}
+		bucket := &resp.Buckets[len(resp.Buckets)-1]
+
+		// Months without any spend come without a currency.
+		if item.Currency == "" {
+			continue
+		}
+		amount, err := rates.Convert(item.Total, item.Currency, filter.Currency)
+		if err != nil {
+			conversionError(w, r, err)
+			return
+		}
+
+		bucket.Total += amount
+		if groupByService {
+			// Rows are ordered by service, so one service in
+			// several currencies comes in adjacent rows.
+			if n := len(bucket.Services); n > 0 && bucket.Services[n-1].ServiceName == item.ServiceName {
+				bucket.Services[n-1].Total += amount
+			} else {
+				bucket.Services = append(bucket.Services, ServiceTotal{
+					ServiceName: item.ServiceName,
+					Total:       amount,
+				})
+			}
+		}
+		resp.Total += amount
+	}
+
+	response.Success(w, resp)
+}
+
+// parsePeriod parses both ends of a period and makes sure the end is not
+// before the start. A MM-YYYY end covers the whole month.
+func parsePeriod(fromStr, toStr string) (time.Time, time.Time, error) {
+	from, err := utils.ParseStartDate(fromStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := utils.ParseEndDate(toStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, ErrInvalidPeriod
+	}
+	return from, to, nil
 }
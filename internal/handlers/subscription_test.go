@@ -1,6 +1,7 @@
 package handlers_test
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"testovoe/internal/currency"
 	"testovoe/internal/handlers"
 	"testovoe/internal/models"
 	"testovoe/internal/storage"
@@ -35,6 +37,8 @@ func setupTest(t *testing.T) (*handlers.SubscriptionHandler, *mock_storage.MockS
 
 	validate := validator.New(validator.WithRequiredStructEnabled())
 	validate.RegisterValidation("mm_yyyy", validators.MonthYearValidator)
+	validate.RegisterValidation("mm_yyyy_or_date", validators.MonthYearOrDateValidator)
+	validate.RegisterValidation("metadata", validators.MetadataValidator)
 
 	mockedSubscriptionStorage := mock_storage.NewMockSubscriptionStorage(ctrl)
 
@@ -43,11 +47,32 @@ func setupTest(t *testing.T) (*handlers.SubscriptionHandler, *mock_storage.MockS
 			Subscription: mockedSubscriptionStorage,
 		},
 		validate,
+		currency.NewStaticProvider(currency.Rates{"RUB": 1, "USD": 90, "EUR": 100}),
 	)
 
 	return handler, mockedSubscriptionStorage
 }
 
+// serve serves r with handler registered at pattern, so that the path values
+// are set. If data isn't nil, the data of the response is decoded into it.
+func serve(pattern string, handler http.HandlerFunc, r *http.Request, data any) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, handler)
+
+	mux.ServeHTTP(w, r)
+
+	if data != nil {
+		var resp struct {
+			Data json.RawMessage `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		json.Unmarshal(resp.Data, data)
+	}
+	return w
+}
+
 func TestCreate(t *testing.T) {
 	handler, mockedSubscriptionStorage := setupTest(t)
 
@@ -73,6 +98,33 @@ func TestCreate(t *testing.T) {
 	assert.Equal(t, http.StatusCreated, w.Code)
 }
 
+func TestCreateFree(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, sub *models.Subscription) (int, error) {
+			assert.Equal(t, 0, sub.Price)
+			return 1, nil
+		}).
+		Times(1)
+
+	body := `{
+		"service_name": "Free tier",
+		"start_date": "01-2006",
+		"price": 0,
+		"user_id": "550e8400-e29b-41d4-a716-446655440000"
+	}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	handler.Create(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
 func TestCreateWrongUserID(t *testing.T) {
 	handler, mockedSubscriptionStorage := setupTest(t)
 
@@ -119,16 +171,33 @@ func TestGet(t *testing.T) {
 func TestUpdate(t *testing.T) {
 	handler, mockedSubscriptionStorage := setupTest(t)
 
+	mockedSubscriptionStorage.EXPECT().
+		Get(gomock.Any(), 1).
+		Return(&models.Subscription{ID: 1, Currency: "RUB", BillingPeriod: models.BillingMonthly}, nil).
+		Times(1)
 	mockedSubscriptionStorage.EXPECT().
 		Update(gomock.Any(), gomock.Any(), &models.Subscription{
 			ID:          1,
 			ServiceName: "test",
 			StartDate:   utils.Must(time.Parse("01-2006", "01-2006")),
-			EndDate:     sql.NullTime{Time: utils.Must(time.Parse("01-2006", "01-2006")), Valid: true},
+			EndDate:     sql.NullTime{Time: utils.Must(time.Parse("2006-01-02", "2006-01-31")), Valid: true},
 			Price:       100,
+			Currency:    "RUB",
 			UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+
+			BillingPeriod: models.BillingMonthly,
 		}).
-		Return(nil).
+		Return(&models.Subscription{
+			ID:            1,
+			ServiceID:     sql.NullInt32{Int32: 3, Valid: true},
+			ServiceName:   "test",
+			Price:         100,
+			Currency:      "RUB",
+			StartDate:     utils.Must(time.Parse("01-2006", "01-2006")),
+			BillingPeriod: models.BillingMonthly,
+			Paused:        true,
+			Version:       5,
+		}, nil).
 		Times(1)
 
 	body := `{
@@ -139,6 +208,49 @@ func TestUpdate(t *testing.T) {
 		"user_id": "550e8400-e29b-41d4-a716-446655440000"
 	}`
 
+	r := httptest.NewRequest(http.MethodPut, "/subscriptions/1", strings.NewReader(body))
+	var resp handlers.SubscriptionResponse
+	w := serve("PUT /subscriptions/{id}", handler.Update, r, &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	// The response is the subscription as stored.
+	assert.Equal(t, `"5"`, w.Header().Get("ETag"))
+	assert.Equal(t, true, resp.Paused)
+	assert.Equal(t, utils.Int(3), resp.ServiceID)
+}
+
+func TestUpdateKeepsStoredFields(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	trialEnd := sql.NullTime{Time: time.Date(2006, time.January, 15, 0, 0, 0, 0, time.UTC), Valid: true}
+	mockedSubscriptionStorage.EXPECT().
+		Get(gomock.Any(), 1).
+		Return(&models.Subscription{
+			ID:                    1,
+			Currency:              "USD",
+			BillingPeriod:         models.BillingCustom,
+			BillingIntervalMonths: sql.NullInt32{Int32: 2, Valid: true},
+			TrialEnd:              trialEnd,
+		}, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Update(gomock.Any(), 1, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ int, sub *models.Subscription) (*models.Subscription, error) {
+			assert.Equal(t, "USD", sub.Currency)
+			assert.Equal(t, models.BillingCustom, sub.BillingPeriod)
+			assert.Equal(t, sql.NullInt32{Int32: 2, Valid: true}, sub.BillingIntervalMonths)
+			assert.Equal(t, trialEnd, sub.TrialEnd)
+			return sub, nil
+		}).
+		Times(1)
+
+	body := `{
+		"service_name": "test",
+		"start_date": "01-2006",
+		"price": 100,
+		"user_id": "550e8400-e29b-41d4-a716-446655440000"
+	}`
+
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodPut, "/subscriptions/1", strings.NewReader(body))
 	r.Header.Set("Content-Type", "application/json")
@@ -151,11 +263,46 @@ func TestUpdate(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestUpdateStoredTrialBeforeStart(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Get(gomock.Any(), 1).
+		Return(&models.Subscription{
+			ID:            1,
+			Currency:      "RUB",
+			BillingPeriod: models.BillingMonthly,
+			TrialEnd:      sql.NullTime{Time: time.Date(2005, time.December, 31, 0, 0, 0, 0, time.UTC), Valid: true},
+		}, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Update(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	body := `{
+		"service_name": "test",
+		"start_date": "01-2006",
+		"price": 100,
+		"user_id": "550e8400-e29b-41d4-a716-446655440000"
+	}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, "/subscriptions/1", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /subscriptions/{id}", handler.Update)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestDelete(t *testing.T) {
 	handler, mockedSubscriptionStorage := setupTest(t)
 
 	mockedSubscriptionStorage.EXPECT().
-		Delete(gomock.Any(), gomock.Any()).
+		Delete(gomock.Any(), gomock.Any(), gomock.Any()).
 		Return(nil).
 		Times(1)
 
@@ -173,10 +320,10 @@ func TestDelete(t *testing.T) {
 func TestList(t *testing.T) {
 	handler, mockedSubscriptionStorage := setupTest(t)
 
-	mockedSubscriptionStorage.EXPECT().TotalForPeriod(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(1), nil).Times(1)
+	mockedSubscriptionStorage.EXPECT().TotalForPeriod(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(map[string]int64{"RUB": 1}, nil).Times(1)
 	mockedSubscriptionStorage.EXPECT().
-		List(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
-		Return([]models.Subscription{{ID: 1, ServiceName: "test"}}, nil).
+		List(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return([]models.Subscription{{ID: 1, ServiceName: "test"}}, false, nil).
 		Times(1)
 
 	w := httptest.NewRecorder()
@@ -207,6 +354,162 @@ func TestList(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestListWithoutExchangeRate(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().TotalForPeriod(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(map[string]int64{"RUB": 100, "GBP": 5}, nil).Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		List(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return([]models.Subscription{{ID: 1, ServiceName: "test"}}, false, nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions", handler.List)
+
+	mux.ServeHTTP(w, r)
+
+	var resp struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	// There is no GBP rate, so only the total in RUB is left out.
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"GBP":5,"RUB":100}`, string(resp.Data["totals"]))
+	_, ok := resp.Data["total"]
+	assert.Equal(t, false, ok)
+	_, ok = resp.Data["currency"]
+	assert.Equal(t, false, ok)
+}
+
+func TestListCursor(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		TotalForPeriod(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(map[string]int64{}, nil).
+		Times(2)
+	gomock.InOrder(
+		mockedSubscriptionStorage.EXPECT().
+			List(gomock.Any(), models.SubscriptionFilter{}, models.Page{Limit: 2}).
+			Return([]models.Subscription{{ID: 9}, {ID: 7}}, true, nil),
+		mockedSubscriptionStorage.EXPECT().
+			List(gomock.Any(), models.SubscriptionFilter{}, models.Page{Limit: 2, After: &models.Subscription{ID: 7}}).
+			Return([]models.Subscription{{ID: 3}}, false, nil),
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions", handler.List)
+
+	type ListResponse struct {
+		Data struct {
+			NextCursor *string `json:"next_cursor"`
+			HasMore    bool    `json:"has_more"`
+		} `json:"data"`
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/subscriptions?limit=2", nil))
+
+	var resp ListResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, resp.Data.HasMore)
+	assert.NotEqual(t, nil, resp.Data.NextCursor)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/subscriptions?limit=2&cursor="+*resp.Data.NextCursor, nil))
+
+	resp = ListResponse{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, false, resp.Data.HasMore)
+	assert.Equal(t, (*string)(nil), resp.Data.NextCursor)
+}
+
+func TestListFilters(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		TotalForPeriod(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(map[string]int64{}, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		List(gomock.Any(), models.SubscriptionFilter{
+			UserID:            "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+			ServiceNamePrefix: "net",
+			Category:          "streaming",
+			Tags:              []string{"family", "work"},
+			Metadata:          map[string]string{"app_store_id": "1000000123", "ref": "a:b"},
+			MetadataKeys:      []string{"bank_ref"},
+			PriceMin:          utils.Int(100),
+			PriceMax:          utils.Int(500),
+			ActiveFrom:        time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+			ActiveTo:          time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC),
+			StartedAfter:      time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC),
+			EndedBefore:       time.Date(2025, time.June, 15, 0, 0, 0, 0, time.UTC),
+			Status:            models.StatusEnded,
+		}, models.Page{Sort: []models.SortKey{{Column: "price"}, {Column: "start_date", Desc: true}}}).
+		Return(nil, false, nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions?user_id=60601fee-2bf1-4721-ae6f-7636e79a0cba"+
+		"&service_name_prefix=net&price_min=100&price_max=500&active_at=03-2025&started_after=12-2024"+
+		"&ended_before=2025-06-15&status=ended&sort=price,-start_date&category=streaming&tag=Family&tag=work"+
+		"&metadata=app_store_id:1000000123&metadata=ref:a:b&metadata=bank_ref", nil)
+
+	handler.List(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestListInvalidFilters(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		List(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	for _, query := range []string{
+		"?price_min=-1",
+		"?price_max=99999999999999999999",
+		"?active_at=2025/03",
+		"?status=paused",
+		"?category=entertainment",
+		"?tag=",
+		"?metadata=",
+		"?sort=user_id",
+		"?sort=price,-price",
+		// A cursor only applies to the sort it was made for.
+		"?sort=price&cursor=eyJpZCI6N30",
+	} {
+		w := httptest.NewRecorder()
+		handler.List(w, httptest.NewRequest(http.MethodGet, "/subscriptions"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestListInvalidCursor(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		List(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions", handler.List)
+
+	for _, query := range []string{"?cursor=not-a-cursor", "?cursor=eyJpZCI6N30&offset=10"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/subscriptions"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
+
 func TestUpdateInvalidID(t *testing.T) {
 	handler, _ := setupTest(t)
 
@@ -256,7 +559,7 @@ func TestDeleteNotFound(t *testing.T) {
 	handler, mockedSubscriptionStorage := setupTest(t)
 
 	mockedSubscriptionStorage.EXPECT().
-		Delete(gomock.Any(), gomock.Any()).
+		Delete(gomock.Any(), gomock.Any(), gomock.Any()).
 		Return(storage.ErrNotFound).
 		Times(1)
 
@@ -299,7 +602,7 @@ func TestUpdateInvalidDateFormat(t *testing.T) {
 
 	body := `{
 		"service_name": "test",
-		"start_date": "2006-01-02",
+		"start_date": "2006/01/02",
 		"price": 100,
 		"user_id": "550e8400-e29b-41d4-a716-446655440000"
 	}`
@@ -314,4 +617,660 @@ func TestUpdateInvalidDateFormat(t *testing.T) {
 	mux.ServeHTTP(w, r)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-}
\ No newline at end of file
+}
+
+func TestTotal(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	mockedSubscriptionStorage.EXPECT().
+		TotalForPeriod(
+			gomock.Any(),
+			time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC),
+			userID,
+			"Yandex Plus",
+			false,
+		).
+		Return(map[string]int64{"RUB": 1500, "USD": 10}, nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/total?from=01-2025&to=06-2025&user_id="+userID.String()+"&service_name=Yandex+Plus", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions/total", handler.Total)
+
+	mux.ServeHTTP(w, r)
+
+	type TotalResponse struct {
+		Data struct {
+			Total    int64  `json:"total"`
+			Currency string `json:"currency"`
+			From     string `json:"from"`
+			To       string `json:"to"`
+		} `json:"data"`
+	}
+
+	var resp TotalResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(2400), resp.Data.Total)
+	assert.Equal(t, "RUB", resp.Data.Currency)
+	assert.Equal(t, "01-2025", resp.Data.From)
+	assert.Equal(t, "06-2025", resp.Data.To)
+}
+
+func TestTotalEndBeforeStart(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		TotalForPeriod(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/total?from=06-2025&to=01-2025", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions/total", handler.Total)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestTotalMissingPeriod(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		TotalForPeriod(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/total?from=2025-01&user_id=42", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions/total", handler.Total)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestTimeSeries(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	jan := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)
+	mockedSubscriptionStorage.EXPECT().
+		TimeSeries(gomock.Any(), jan, time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC), uuid.Nil, "", true, false).
+		Return([]models.MonthlyTotal{
+			{Month: jan, ServiceName: "Netflix", Currency: "RUB", Total: 300},
+			{Month: jan, ServiceName: "Spotify", Currency: "RUB", Total: 200},
+			{Month: feb},
+		}, nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/timeseries?from=01-2025&to=02-2025&group_by=service", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions/timeseries", handler.TimeSeries)
+
+	mux.ServeHTTP(w, r)
+
+	var resp struct {
+		Data handlers.TimeSeriesResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(500), resp.Data.Total)
+	assert.Equal(t, 2, len(resp.Data.Buckets))
+	assert.Equal(t, "01-2025", resp.Data.Buckets[0].Month)
+	assert.Equal(t, int64(500), resp.Data.Buckets[0].Total)
+	assert.Equal(t, 2, len(resp.Data.Buckets[0].Services))
+	assert.Equal(t, "02-2025", resp.Data.Buckets[1].Month)
+	assert.Equal(t, int64(0), resp.Data.Buckets[1].Total)
+	assert.Equal(t, 0, len(resp.Data.Buckets[1].Services))
+}
+
+func TestTimeSeriesProrate(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mar := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	mockedSubscriptionStorage.EXPECT().
+		TimeSeries(gomock.Any(), mar, time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC), uuid.Nil, "", false, true).
+		Return([]models.MonthlyTotal{{Month: mar, Currency: "RUB", Total: 150}}, nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/timeseries?from=03-2025&to=03-2025&prorate=true", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions/timeseries", handler.TimeSeries)
+
+	mux.ServeHTTP(w, r)
+
+	var resp struct {
+		Data handlers.TimeSeriesResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(150), resp.Data.Total)
+	assert.Equal(t, true, resp.Data.Prorated)
+}
+
+func TestTimeSeriesInvalidGroupBy(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		TimeSeries(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/timeseries?from=01-2025&to=02-2025&group_by=user", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions/timeseries", handler.TimeSeries)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateWithCurrency(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Cond(func(sub *models.Subscription) bool {
+			return sub.Currency == "USD"
+		})).
+		Return(1, nil).
+		Times(1)
+
+	body := `{
+		"service_name": "Netflix",
+		"start_date": "01-2025",
+		"price": 15,
+		"currency": "USD",
+		"user_id": "550e8400-e29b-41d4-a716-446655440000"
+	}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	handler.Create(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestCreateInvalidCurrency(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	body := `{
+		"service_name": "Netflix",
+		"start_date": "01-2025",
+		"price": 15,
+		"currency": "dollars",
+		"user_id": "550e8400-e29b-41d4-a716-446655440000"
+	}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	handler.Create(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestTotalUnknownCurrency(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		TotalForPeriod(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(map[string]int64{"RUB": 100, "KZT": 5000}, nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/total?from=01-2025&to=06-2025&currency=USD", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions/total", handler.Total)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateCustomBillingPeriod(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Cond(func(sub *models.Subscription) bool {
+			months, days := sub.BillingStep()
+			return sub.BillingPeriod == models.BillingCustom && months == 6 && days == 0
+		})).
+		Return(1, nil).
+		Times(1)
+
+	body := `{
+		"service_name": "Cloud storage",
+		"start_date": "01-2025",
+		"price": 1200,
+		"billing_period": "custom",
+		"billing_interval_months": 6,
+		"user_id": "550e8400-e29b-41d4-a716-446655440000"
+	}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	handler.Create(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestCreateCustomBillingPeriodWithoutInterval(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	body := `{
+		"service_name": "Cloud storage",
+		"start_date": "01-2025",
+		"price": 1200,
+		"billing_period": "custom",
+		"user_id": "550e8400-e29b-41d4-a716-446655440000"
+	}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	handler.Create(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateDayPrecisionDates(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), &models.Subscription{
+			ServiceName: "test",
+			StartDate:   time.Date(2025, time.January, 15, 0, 0, 0, 0, time.UTC),
+			EndDate:     sql.NullTime{Time: time.Date(2025, time.March, 2, 0, 0, 0, 0, time.UTC), Valid: true},
+			Price:       100,
+			Currency:    "RUB",
+			UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+
+			BillingPeriod: models.BillingMonthly,
+		}).
+		Return(1, nil).
+		Times(1)
+
+	body := `{
+		"service_name": "test",
+		"start_date": "2025-01-15",
+		"end_date": "2025-03-02",
+		"price": 100,
+		"user_id": "550e8400-e29b-41d4-a716-446655440000"
+	}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	handler.Create(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestGetDayPrecisionDates(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Get(gomock.Any(), 1).
+		Return(&models.Subscription{
+			ID:            1,
+			ServiceName:   "test",
+			StartDate:     time.Date(2025, time.January, 15, 0, 0, 0, 0, time.UTC),
+			EndDate:       sql.NullTime{Time: time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC), Valid: true},
+			Price:         100,
+			Currency:      "RUB",
+			BillingPeriod: models.BillingMonthly,
+		}, nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/1", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions/{id}", handler.Get)
+
+	mux.ServeHTTP(w, r)
+
+	var resp struct {
+		Data handlers.SubscriptionResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2025-01-15", resp.Data.StartDate)
+	assert.Equal(t, "03-2025", *resp.Data.EndDate)
+}
+
+func TestTotalProrate(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		TotalForPeriod(
+			gomock.Any(),
+			time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC),
+			time.Date(2025, time.April, 30, 0, 0, 0, 0, time.UTC),
+			uuid.Nil,
+			"",
+			true,
+		).
+		Return(map[string]int64{"RUB": 1234}, nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/total?from=2025-03-10&to=04-2025&prorate=true", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions/total", handler.Total)
+
+	mux.ServeHTTP(w, r)
+
+	var resp struct {
+		Data handlers.TotalResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(1234), resp.Data.Total)
+	assert.Equal(t, "2025-03-10", resp.Data.From)
+	assert.Equal(t, "04-2025", resp.Data.To)
+	assert.Equal(t, true, resp.Data.Prorated)
+}
+
+func TestTotalInvalidProrate(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		TotalForPeriod(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/total?from=01-2025&to=06-2025&prorate=maybe", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions/total", handler.Total)
+
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateWithTrialMonths(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), &models.Subscription{
+			ServiceName: "test",
+			StartDate:   time.Date(2025, time.January, 15, 0, 0, 0, 0, time.UTC),
+			Price:       100,
+			Currency:    "RUB",
+			UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+
+			BillingPeriod: models.BillingMonthly,
+
+			TrialEnd: sql.NullTime{Time: time.Date(2025, time.February, 14, 0, 0, 0, 0, time.UTC), Valid: true},
+		}).
+		Return(1, nil).
+		Times(1)
+
+	body := `{
+		"service_name": "test",
+		"start_date": "2025-01-15",
+		"trial_months": 1,
+		"price": 100,
+		"user_id": "550e8400-e29b-41d4-a716-446655440000"
+	}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	handler.Create(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestCreateTrialMonthsAndTrialEnd(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	body := `{
+		"service_name": "test",
+		"start_date": "01-2025",
+		"trial_months": 1,
+		"trial_end": "02-2025",
+		"price": 100,
+		"user_id": "550e8400-e29b-41d4-a716-446655440000"
+	}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	handler.Create(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateTrialEndBeforeStart(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	body := `{
+		"service_name": "test",
+		"start_date": "03-2025",
+		"trial_end": "02-2025",
+		"price": 100,
+		"user_id": "550e8400-e29b-41d4-a716-446655440000"
+	}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	handler.Create(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestListShowsTrial(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	today := time.Now().UTC()
+	mockedSubscriptionStorage.EXPECT().
+		List(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return([]models.Subscription{
+			{
+				ID:            1,
+				StartDate:     utils.StartOfMonth(today),
+				TrialEnd:      sql.NullTime{Time: utils.EndOfMonth(today), Valid: true},
+				BillingPeriod: models.BillingMonthly,
+			},
+			{
+				ID:            2,
+				StartDate:     utils.StartOfMonth(today).AddDate(0, -2, 0),
+				TrialEnd:      sql.NullTime{Time: utils.StartOfMonth(today).AddDate(0, 0, -1), Valid: true},
+				BillingPeriod: models.BillingMonthly,
+			},
+		}, false, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		TotalForPeriod(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(map[string]int64{}, nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+
+	handler.List(w, r)
+
+	var resp struct {
+		Data struct {
+			Subscriptions []handlers.SubscriptionResponse `json:"subscriptions"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, len(resp.Data.Subscriptions))
+	assert.Equal(t, true, resp.Data.Subscriptions[0].InTrial)
+	assert.Equal(t, false, resp.Data.Subscriptions[1].InTrial)
+}
+
+func TestCreateWithCategoryAndTags(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, sub *models.Subscription) (int, error) {
+			assert.Equal(t, sql.NullString{String: "music", Valid: true}, sub.Category)
+			// Tags are lowercased, without duplicates.
+			assert.Equal(t, []string{"family", "shared"}, sub.Tags)
+			return 1, nil
+		}).
+		Times(1)
+
+	body := `{
+		"service_name": "Spotify",
+		"start_date": "01-2025",
+		"price": 100,
+		"user_id": "550e8400-e29b-41d4-a716-446655440000",
+		"category": "music",
+		"tags": ["Family", " shared", "family"]
+	}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	handler.Create(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestCreateInvalidCategory(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	for _, fields := range []string{
+		`"category": "entertainment"`,
+		`"tags": [""]`,
+		`"tags": ["` + strings.Repeat("a", 51) + `"]`,
+	} {
+		body := `{
+			"service_name": "Spotify",
+			"start_date": "01-2025",
+			"price": 100,
+			"user_id": "550e8400-e29b-41d4-a716-446655440000",
+			` + fields + `
+		}`
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+
+		handler.Create(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestCreateWithMetadataAndNotes(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, sub *models.Subscription) (int, error) {
+			assert.Equal(t, `{"app_store_id": "1000000123"}`, string(sub.Metadata))
+			assert.Equal(t, sql.NullString{String: "Family plan", Valid: true}, sub.Notes)
+			return 1, nil
+		}).
+		Times(1)
+
+	body := `{
+		"service_name": "Apple Music",
+		"start_date": "01-2025",
+		"price": 100,
+		"user_id": "550e8400-e29b-41d4-a716-446655440000",
+		"metadata": {"app_store_id": "1000000123"},
+		"notes": "Family plan"
+	}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	handler.Create(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestCreateInvalidMetadata(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	for _, metadata := range []string{
+		`["app_store_id"]`,
+		`"1000000123"`,
+		`{"a": {"b": {"c": {"d": {"e": {"f": 1}}}}}}`,
+		`{"a": "` + strings.Repeat("x", validators.MaxMetadataBytes) + `"}`,
+	} {
+		body := `{
+			"service_name": "Apple Music",
+			"start_date": "01-2025",
+			"price": 100,
+			"user_id": "550e8400-e29b-41d4-a716-446655440000",
+			"metadata": ` + metadata + `
+		}`
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+
+		handler.Create(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testovoe/internal/models"
+	"testovoe/internal/response"
+	"testovoe/internal/storage"
+)
+
+const DefaultTrashLimit = 20
+
+type TrashQuery struct {
+	Limit  string `validate:"omitempty,number"`
+	Offset string `validate:"omitempty,number"`
+}
+
+type TrashResponse struct {
+	Subscriptions []SubscriptionResponse `json:"subscriptions"`
+	HasMore       bool                   `json:"has_more"`
+}
+
+// Restore takes a deleted subscription out of the trash. A subscription
+// that isn't in the trash, because it was never deleted or has already
+// been purged, is not found.
+func (h *SubscriptionHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	intID, err := strconv.Atoi(id)
+	if err != nil {
+		slog.ErrorContext(ctx, "parse id", "error", err)
+		response.BadRequest(w, "Bad request")
+		return
+	}
+
+	sub, err := h.store.Subscription.Restore(ctx, intID)
+	if err != nil {
+		slog.ErrorContext(ctx, "restore subscription", "error", err)
+		if errors.Is(err, storage.ErrNotFound) {
+			response.NotFound(w, "Not found")
+			return
+		}
+		response.ServerError(w, "Internal server error")
+		return
+	}
+
+	w.Header().Set("ETag", etag(sub.Version))
+	response.Success(w, newSubscriptionResponse(sub))
+}
+
+// Trash lists the deleted subscriptions that haven't been purged yet, the
+// last created first. The filters of List apply. Pages are selected with
+// limit (default 20, at most 1000) and offset.
+func (h *SubscriptionHandler) Trash(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := TrashQuery{
+		Limit:  r.URL.Query().Get("limit"),
+		Offset: r.URL.Query().Get("offset"),
+	}
+	if !h.validateInput(w, r, query) {
+		return
+	}
+	filter, ok := h.readListFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.Deleted = true
+
+	limit, offset, ok := parseLimitOffset(w, query.Limit, query.Offset, DefaultTrashLimit, storage.MaxLimit)
+	if !ok {
+		return
+	}
+
+	subscriptions, hasMore, err := h.store.Subscription.List(ctx, filter, models.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		slog.ErrorContext(ctx, "list trash", "error", err)
+		response.ServerError(w, "Internal server error")
+		return
+	}
+
+	resp := TrashResponse{Subscriptions: []SubscriptionResponse{}, HasMore: hasMore}
+	for _, sub := range subscriptions {
+		resp.Subscriptions = append(resp.Subscriptions, newSubscriptionResponse(&sub))
+	}
+
+	response.Success(w, resp)
+}
+
+// parseLimitOffset parses the limit and the offset of a page, validated as
+// numbers, falling back to defaultLimit. A limit above maxLimit is refused.
+func parseLimitOffset(w http.ResponseWriter, limitStr, offsetStr string, defaultLimit, maxLimit int) (limit, offset int, ok bool) {
+	limit = defaultLimit
+	var err error
+	if limitStr != "" {
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit < 0 || limit > maxLimit {
+			response.BadRequest(w, "Invalid limit")
+			return 0, 0, false
+		}
+	}
+	if offsetStr != "" {
+		if offset, err = strconv.Atoi(offsetStr); err != nil || offset < 0 {
+			response.BadRequest(w, "Invalid offset")
+			return 0, 0, false
+		}
+	}
+	return limit, offset, true
+}
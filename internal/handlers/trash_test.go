@@ -0,0 +1,78 @@
+package handlers_test
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/handlers"
+	"testovoe/internal/models"
+	"testovoe/internal/storage"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRestore(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		Restore(gomock.Any(), 1).
+		Return(&models.Subscription{ID: 1, ServiceName: "Netflix", Version: 3}, nil).
+		Times(1)
+	mockedSubscriptionStorage.EXPECT().
+		Restore(gomock.Any(), 2).
+		Return(nil, storage.ErrNotFound).
+		Times(1)
+
+	var resp handlers.SubscriptionResponse
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/1/restore", nil)
+	w := serve("POST /subscriptions/{id}/restore", handler.Restore, r, &resp)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+
+	assert.Equal(t, 1, resp.ID)
+	assert.Equal(t, (*time.Time)(nil), resp.DeletedAt)
+
+	r = httptest.NewRequest(http.MethodPost, "/subscriptions/2/restore", nil)
+	w = serve("POST /subscriptions/{id}/restore", handler.Restore, r, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestTrash(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	deletedAt := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	mockedSubscriptionStorage.EXPECT().
+		List(gomock.Any(), models.SubscriptionFilter{ServiceName: "Netflix", Deleted: true}, models.Page{Limit: 10, Offset: 5}).
+		Return([]models.Subscription{{
+			ID:          1,
+			ServiceName: "Netflix",
+			DeletedAt:   sql.NullTime{Time: deletedAt, Valid: true},
+		}}, true, nil).
+		Times(1)
+
+	var resp handlers.TrashResponse
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/trash?service_name=Netflix&limit=10&offset=5", nil)
+	w := serve("GET /subscriptions/trash", handler.Trash, r, &resp)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, true, resp.HasMore)
+	assert.Equal(t, 1, len(resp.Subscriptions))
+	assert.Equal(t, deletedAt, *resp.Subscriptions[0].DeletedAt)
+}
+
+func TestTrashInvalidQuery(t *testing.T) {
+	handler, mockedSubscriptionStorage := setupTest(t)
+
+	mockedSubscriptionStorage.EXPECT().
+		List(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	for _, query := range []string{"?limit=ten", "?limit=1001", "?offset=-1", "?status=paused"} {
+		r := httptest.NewRequest(http.MethodGet, "/subscriptions/trash"+query, nil)
+		w := serve("GET /subscriptions/trash", handler.Trash, r, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Actions of a SubscriptionEvent.
+const (
+	EventCreate  = "create"
+	EventUpdate  = "update"
+	EventDelete  = "delete"
+	EventRestore = "restore"
+)
+
+var EventActions = []string{EventCreate, EventUpdate, EventDelete, EventRestore}
+
+// SubscriptionEvent is an entry of the audit trail: a change of a
+// subscription. Before and After are JSON objects with the columns of the
+// subscription, its current price, price history and pauses; Before is
+// nil for EventCreate.
+type SubscriptionEvent struct {
+	ID             int64
+	SubscriptionID int
+	Action         string
+	Before         json.RawMessage
+	After          json.RawMessage
+	// Actor and RequestID are the ones of the request that made the
+	// change, if it had them.
+	Actor     sql.NullString
+	RequestID sql.NullString
+	CreatedAt time.Time
+}
+
+// EventFilter selects the events of the audit trail. Zero fields don't
+// filter.
+type EventFilter struct {
+	SubscriptionID int
+	// UserID selects the events of the subscriptions of the user, before
+	// or after the change.
+	UserID    string
+	Actor     string
+	RequestID string
+	Action    string
+	// Changed selects the events that changed a field of the
+	// subscription, like "price" or "end_date".
+	Changed string
+	// From and To bound the time of the event, both inclusive.
+	From time.Time
+	To   time.Time
+}
@@ -0,0 +1,13 @@
+package models
+
+import "net/http"
+
+// IdempotentResponse is the response to the first request with an
+// Idempotency-Key, which is sent again when the request is retried.
+type IdempotentResponse struct {
+	Status int
+	// Header holds the headers that are replayed with the body, e.g.
+	// ETag.
+	Header http.Header
+	Body   []byte
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Subscription statuses a list can be filtered by.
+const (
+	StatusActive = "active"
+	StatusEnded  = "ended"
+)
+
+// SubscriptionFilter selects the subscriptions of a list. Zero fields don't
+// filter.
+type SubscriptionFilter struct {
+	UserID      string
+	ServiceID   int
+	ServiceName string
+	// ServiceNamePrefix matches the start of the service name, ignoring
+	// case.
+	ServiceNamePrefix string
+	Category          string
+	// Tags selects the subscriptions having all of them.
+	Tags []string
+	// Metadata selects the subscriptions whose metadata has all these
+	// members with string values, MetadataKeys the ones having all these
+	// members with any value.
+	Metadata     map[string]string
+	MetadataKeys []string
+	// PriceMin and PriceMax bound the current price, in the currency of
+	// the subscription.
+	PriceMin *int
+	PriceMax *int
+	// ActiveFrom and ActiveTo select the subscriptions active on at least
+	// one day of the period. Both are set or none.
+	ActiveFrom time.Time
+	ActiveTo   time.Time
+	// StartedAfter and EndedBefore are exclusive.
+	StartedAfter time.Time
+	EndedBefore  time.Time
+	// Status is StatusActive for the subscriptions running today, or
+	// StatusEnded for the ones that ended before today.
+	Status string
+	// Deleted selects the subscriptions in the trash instead of the
+	// others.
+	Deleted bool
+}
+
+// SortKey orders a list by a column.
+type SortKey struct {
+	Column string
+	Desc   bool
+}
+
+// Page selects a page of a list ordered by Sort, then by id, newest first.
+// Either Offset rows are skipped, or, with keyset pagination, the page
+// starts right after the row After, of which only the ID and the sorted
+// columns are used. A Limit of 0 means no limit.
+type Page struct {
+	Limit  int
+	Offset int
+	Sort   []SortKey
+	After  *Subscription
+}
+
+// SearchResult is a subscription found by a search. The higher the Rank,
+// the better it matches.
+type SearchResult struct {
+	Subscription
+	Rank float64
+}
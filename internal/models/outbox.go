@@ -0,0 +1,37 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Types of an OutboxEvent.
+const (
+	SubscriptionCreated = "subscription.created"
+	// SubscriptionUpdated is emitted for every other change, including a
+	// restore from the trash. It carries the whole subscription, so it
+	// can be applied as an upsert.
+	SubscriptionUpdated = "subscription.updated"
+	SubscriptionDeleted = "subscription.deleted"
+	// SubscriptionEnded is emitted along with SubscriptionUpdated when a
+	// change ends the subscription: its end date is set to today or
+	// earlier, and it hadn't ended before. An end date in the future
+	// doesn't emit it, neither then nor when the day comes.
+	SubscriptionEnded = "subscription.ended"
+)
+
+// OutboxEvent is a domain event of a subscription waiting in the outbox to
+// be dispatched. Subscription and Previous are snapshots of the
+// subscription after and before the change, like in SubscriptionEvent;
+// Previous is nil for SubscriptionCreated.
+type OutboxEvent struct {
+	ID             int64
+	Type           string
+	SubscriptionID int
+	Subscription   json.RawMessage
+	Previous       json.RawMessage
+	Actor          sql.NullString
+	RequestID      sql.NullString
+	CreatedAt      time.Time
+}
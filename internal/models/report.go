@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// MonthlyTotal is the spend in one currency for one calendar month.
+// ServiceName is empty unless the totals are split per service.
+type MonthlyTotal struct {
+	Month       time.Time
+	ServiceName string
+	Currency    string
+	Total       int64
+}
+
+// BreakdownItem is the spend of one group in one currency in a cost
+// breakdown. Key holds the value of the column the breakdown is grouped by.
+type BreakdownItem struct {
+	Key      string
+	Currency string
+	Count    int
+	Total    int64
+}
@@ -0,0 +1,18 @@
+package models
+
+import "database/sql"
+
+// Service is an entry of the service catalog. Subscriptions linked to a
+// service carry its name.
+type Service struct {
+	ID       int
+	Name     string
+	Category sql.NullString
+	Homepage sql.NullString
+	// DefaultPrice is the usual price of the service in Currency.
+	DefaultPrice sql.NullInt32
+	Currency     string
+	// Aliases are other spellings of the name. A subscription named after
+	// an alias is linked to the service.
+	Aliases []string
+}
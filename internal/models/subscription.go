@@ -2,16 +2,140 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type BillingPeriod string
+
+const (
+	BillingMonthly   BillingPeriod = "monthly"
+	BillingQuarterly BillingPeriod = "quarterly"
+	BillingYearly    BillingPeriod = "yearly"
+	BillingWeekly    BillingPeriod = "weekly"
+	BillingCustom    BillingPeriod = "custom"
+)
+
 type Subscription struct {
-	ID          int
+	ID int
+	// ServiceID links the subscription to the service catalog. On writes
+	// it wins over ServiceName, which is kept as given and only defaults
+	// to the name of the service; without it the service is looked up by
+	// ServiceName.
+	ServiceID   sql.NullInt32
 	ServiceName string
 	Price       int
+	Currency    string
 	UserID      uuid.UUID
 	StartDate   time.Time
 	EndDate     sql.NullTime
+
+	BillingPeriod BillingPeriod
+	// BillingIntervalMonths is the number of months between two charges,
+	// only used by BillingCustom.
+	BillingIntervalMonths sql.NullInt32
+
+	// TrialEnd is the last day of the free trial, if there is one. Nothing
+	// is charged during the trial and billing starts on the next day.
+	TrialEnd sql.NullTime
+
+	// Category is one of a fixed set like "streaming" or "music", Tags
+	// are free-form labels of the user, lowercase and without duplicates.
+	Category sql.NullString
+	Tags     []string
+
+	// Metadata is a JSON object attached by integrations, like the id of
+	// an App Store transaction. nil is stored as an empty object.
+	Metadata json.RawMessage
+	Notes    sql.NullString
+
+	// Paused reports whether the subscription is paused today. It is read
+	// from the pauses and ignored on writes.
+	Paused bool
+
+	// DeletedAt is the time the subscription was moved to the trash. It is
+	// read from the database and ignored on writes.
+	DeletedAt sql.NullTime
+
+	// Version is incremented on every change of the subscription. On
+	// update it is the expected version, zero means any.
+	Version int
+}
+
+// SubscriptionPatch is a partial update of a subscription. Nil fields are
+// left unchanged; a non-nil nullable field with Valid unset clears it.
+type SubscriptionPatch struct {
+	ServiceID   *sql.NullInt32
+	ServiceName *string
+	Price       *int
+	Currency    *string
+	UserID      *uuid.UUID
+	StartDate   *time.Time
+	EndDate     *sql.NullTime
+
+	BillingPeriod         *BillingPeriod
+	BillingIntervalMonths *sql.NullInt32
+
+	TrialEnd *sql.NullTime
+
+	Category *sql.NullString
+	Tags     *[]string
+
+	// Metadata is a JSON Merge Patch of the metadata: its members are
+	// merged into it, a null member removes one, and null empties it.
+	Metadata json.RawMessage
+	Notes    *sql.NullString
+}
+
+// BillingStart returns the first billing date: the day after the trial,
+// or the start date if there is no trial.
+func (s *Subscription) BillingStart() time.Time {
+	if s.TrialEnd.Valid {
+		return s.TrialEnd.Time.AddDate(0, 0, 1)
+	}
+	return s.StartDate
+}
+
+// InTrial reports whether the day of now falls into the free trial.
+func (s *Subscription) InTrial(now time.Time) bool {
+	if !s.TrialEnd.Valid {
+		return false
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return !today.Before(s.StartDate) && !today.After(s.TrialEnd.Time)
+}
+
+// BillingStep returns the distance between two billing dates. Exactly one
+// of months and days is non-zero.
+func (s *Subscription) BillingStep() (months, days int) {
+	switch s.BillingPeriod {
+	case BillingQuarterly:
+		return 3, 0
+	case BillingYearly:
+		return 12, 0
+	case BillingWeekly:
+		return 0, 7
+	case BillingCustom:
+		if s.BillingIntervalMonths.Valid && s.BillingIntervalMonths.Int32 > 0 {
+			return int(s.BillingIntervalMonths.Int32), 0
+		}
+	}
+	return 1, 0
+}
+
+// PriceChange is one entry of the price history of a subscription: Price is
+// charged from the EffectiveFrom month until the next change.
+type PriceChange struct {
+	Price         int
+	EffectiveFrom time.Time
+}
+
+// Pause is an interval in which a subscription is frozen: billing dates
+// from From to Until, both inclusive, are not charged. A pause without
+// Until lasts until the subscription is resumed.
+type Pause struct {
+	From  time.Time
+	Until sql.NullTime
 }
@@ -0,0 +1,51 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"testovoe/internal/models"
+)
+
+// ChannelSink delivers the events to a consumer in the same process.
+type ChannelSink struct {
+	events chan models.OutboxEvent
+}
+
+// NewChannelSink returns a sink whose channel buffers up to size events.
+// Once it is full, publishing waits for the consumer.
+func NewChannelSink(size int) *ChannelSink {
+	return &ChannelSink{events: make(chan models.OutboxEvent, size)}
+}
+
+// Events returns the channel of the published events.
+func (s *ChannelSink) Events() <-chan models.OutboxEvent {
+	return s.events
+}
+
+func (s *ChannelSink) Publish(ctx context.Context, events []models.OutboxEvent) error {
+	for _, event := range events {
+		select {
+		case s.events <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Consume logs the events of the channel until it is closed or ctx is
+// done. It is the consumer of the sink used when no other one is set up.
+func Consume(ctx context.Context, events <-chan models.OutboxEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			slog.InfoContext(ctx, "subscription event",
+				"id", event.ID, "type", event.Type, "subscription_id", event.SubscriptionID)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package outbox
+
+import (
+	"context"
+	"testovoe/internal/storage"
+	"testovoe/internal/utils"
+	"time"
+)
+
+type Dispatcher struct {
+	store     storage.OutboxStorage
+	sink      Sink
+	interval  time.Duration
+	batchSize int
+}
+
+// NewDispatcher returns a dispatcher publishing the events of the outbox to
+// the sink in batches of up to batchSize, checking for new ones every
+// interval.
+func NewDispatcher(store storage.OutboxStorage, sink Sink, interval time.Duration, batchSize int) *Dispatcher {
+	return &Dispatcher{store: store, sink: sink, interval: interval, batchSize: batchSize}
+}
+
+// Run drains the outbox every interval until ctx is done, starting with
+// the events left over from before a restart. When publishing fails the
+// events stay in the outbox and are published, in order, on the next poll.
+func (d *Dispatcher) Run(ctx context.Context) {
+	utils.Every(ctx, d.interval, "dispatch outbox", func(ctx context.Context) error {
+		_, err := d.DispatchOnce(ctx)
+		return err
+	})
+}
+
+// DispatchOnce publishes the events of the outbox until it is empty and
+// returns how many there were.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		n, err := d.store.Dispatch(ctx, d.batchSize, d.sink.Publish)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < d.batchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"testovoe/internal/models"
+	mock_storage "testovoe/internal/storage/mocks"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"go.uber.org/mock/gomock"
+)
+
+func events(ids ...int64) []models.OutboxEvent {
+	var out []models.OutboxEvent
+	for _, id := range ids {
+		out = append(out, models.OutboxEvent{
+			ID:             id,
+			Type:           models.SubscriptionUpdated,
+			SubscriptionID: 1,
+			Subscription:   json.RawMessage(`{"id":1}`),
+			CreatedAt:      time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC),
+		})
+	}
+	return out
+}
+
+// dispatch makes the mock dispatch the events to the sink.
+func dispatch(events []models.OutboxEvent) func(context.Context, int, func(context.Context, []models.OutboxEvent) error) (int, error) {
+	return func(ctx context.Context, _ int, fn func(context.Context, []models.OutboxEvent) error) (int, error) {
+		if err := fn(ctx, events); err != nil {
+			return 0, err
+		}
+		return len(events), nil
+	}
+}
+
+func TestDispatchOnce(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	ctrl := gomock.NewController(t)
+	store := mock_storage.NewMockOutboxStorage(ctrl)
+	sink := NewChannelSink(10)
+
+	// Full batches are followed by the next one.
+	gomock.InOrder(
+		store.EXPECT().Dispatch(gomock.Any(), 2, gomock.Any()).DoAndReturn(dispatch(events(1, 2))),
+		store.EXPECT().Dispatch(gomock.Any(), 2, gomock.Any()).DoAndReturn(dispatch(events(3))),
+	)
+
+	n, err := NewDispatcher(store, sink, time.Second, 2).DispatchOnce(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, n)
+	for _, id := range []int64{1, 2, 3} {
+		assert.Equal(t, id, (<-sink.Events()).ID)
+	}
+}
+
+func TestDispatchOnceError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_storage.NewMockOutboxStorage(ctrl)
+
+	store.EXPECT().
+		Dispatch(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(0, errors.New("connection refused")).
+		Times(1)
+
+	_, err := NewDispatcher(store, NewChannelSink(1), time.Second, 100).DispatchOnce(context.Background())
+	assert.NotEqual(t, nil, err)
+}
+
+func TestChannelSinkCanceled(t *testing.T) {
+	sink := NewChannelSink(1)
+	assert.Equal(t, nil, sink.Publish(context.Background(), events(1)))
+
+	// The buffer is full, so the event waits for a consumer until the
+	// context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := sink.Publish(ctx, events(2))
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, int64(1), (<-sink.Events()).ID)
+}
+
+func TestConsume(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	sink := NewChannelSink(2)
+	assert.Equal(t, nil, sink.Publish(context.Background(), events(1, 2)))
+	close(sink.events)
+
+	// Consume drains the channel and returns once it is closed.
+	Consume(context.Background(), sink.Events())
+	assert.Equal(t, 0, len(sink.Events()))
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+
+	for _, batch := range [][]models.OutboxEvent{events(1, 2), events(3)} {
+		sink, err := NewFileSink(path)
+		assert.Equal(t, nil, err)
+		batch[0].Actor = sql.NullString{String: "support", Valid: true}
+		assert.Equal(t, nil, sink.Publish(context.Background(), batch))
+		assert.Equal(t, nil, sink.Close())
+	}
+
+	f, err := os.Open(path)
+	assert.Equal(t, nil, err)
+	defer f.Close()
+
+	var messages []Message
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var msg Message
+		assert.Equal(t, nil, json.Unmarshal(scanner.Bytes(), &msg))
+		messages = append(messages, msg)
+	}
+	assert.Equal(t, 3, len(messages))
+	assert.Equal(t, int64(3), messages[2].ID)
+	assert.Equal(t, "support", messages[0].Actor)
+	assert.Equal(t, "", messages[1].Actor)
+	assert.Equal(t, "subscription.updated", messages[1].Type)
+	assert.Equal(t, `{"id":1}`, string(messages[1].Subscription))
+	assert.Equal(t, json.RawMessage(nil), messages[1].Previous)
+}
@@ -0,0 +1,45 @@
+// Package outbox dispatches the domain events of the subscriptions, which
+// the storage writes to the outbox in the transaction of every change, to
+// the other services. A Sink delivers them: a file, an in-process channel,
+// or an adapter of a message broker.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"testovoe/internal/models"
+	"time"
+)
+
+// Sink delivers the dispatched events. Publish gets the events in the
+// order they were written. If it fails, all of them are published again
+// later, so delivery is at least once and consumers should skip the event
+// ids they have seen.
+type Sink interface {
+	Publish(ctx context.Context, events []models.OutboxEvent) error
+}
+
+// Message is the JSON form of an event sent by the sinks.
+type Message struct {
+	ID             int64           `json:"id"`
+	Type           string          `json:"type"`
+	SubscriptionID int             `json:"subscription_id"`
+	OccurredAt     time.Time       `json:"occurred_at"`
+	Actor          string          `json:"actor,omitempty"`
+	RequestID      string          `json:"request_id,omitempty"`
+	Subscription   json.RawMessage `json:"subscription"`
+	Previous       json.RawMessage `json:"previous,omitempty"`
+}
+
+func NewMessage(event models.OutboxEvent) Message {
+	return Message{
+		ID:             event.ID,
+		Type:           event.Type,
+		SubscriptionID: event.SubscriptionID,
+		OccurredAt:     event.CreatedAt,
+		Actor:          event.Actor.String,
+		RequestID:      event.RequestID.String,
+		Subscription:   event.Subscription,
+		Previous:       event.Previous,
+	}
+}
@@ -27,8 +27,8 @@ type ValidationErr struct {
 	Value any    `json:"value,omitempty"`
 }
 
-func ValidationError(w http.ResponseWriter, errs validator.ValidationErrors) error {
-	w.WriteHeader(http.StatusBadRequest)
+// ValidationErrors converts validator errors into the ValidationErr format.
+func ValidationErrors(errs validator.ValidationErrors) []ValidationErr {
 	var out []ValidationErr
 	for _, err := range errs {
 		out = append(out, ValidationErr{
@@ -37,13 +37,24 @@ func ValidationError(w http.ResponseWriter, errs validator.ValidationErrors) err
 			Value: err.Value(),
 		})
 	}
+	return out
+}
+
+func ValidationError(w http.ResponseWriter, errs validator.ValidationErrors) error {
+	w.WriteHeader(http.StatusBadRequest)
 	return utils.WriteJSON(w, http.StatusBadRequest, Response{
 		Status:  http.StatusBadRequest,
 		Message: "validation error",
-		Data:    out,
+		Data:    ValidationErrors(errs),
 	})
 }
 
+// Write sends resp with resp.Status as the status code.
+func Write(w http.ResponseWriter, resp Response) error {
+	w.WriteHeader(resp.Status)
+	return utils.WriteJSON(w, resp.Status, resp)
+}
+
 func ServerError(w http.ResponseWriter, message string) error {
 	w.WriteHeader(http.StatusInternalServerError)
 	return utils.WriteJSON(w, http.StatusInternalServerError, Response{
@@ -68,6 +79,14 @@ func NotFound(w http.ResponseWriter, message string) error {
 	})
 }
 
+func Conflict(w http.ResponseWriter, message string) error {
+	w.WriteHeader(http.StatusConflict)
+	return utils.WriteJSON(w, http.StatusConflict, Response{
+		Status:  http.StatusConflict,
+		Message: message,
+	})
+}
+
 func Created(w http.ResponseWriter, data any) error {
 	w.WriteHeader(http.StatusCreated)
 	return utils.WriteJSON(w, http.StatusCreated, Response{
@@ -77,6 +96,27 @@ func Created(w http.ResponseWriter, data any) error {
 	})
 }
 
+func UnprocessableEntity(w http.ResponseWriter, message string) error {
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	return utils.WriteJSON(w, http.StatusUnprocessableEntity, Response{
+		Status:  http.StatusUnprocessableEntity,
+		Message: message,
+	})
+}
+
+func PreconditionFailed(w http.ResponseWriter, message string) error {
+	w.WriteHeader(http.StatusPreconditionFailed)
+	return utils.WriteJSON(w, http.StatusPreconditionFailed, Response{
+		Status:  http.StatusPreconditionFailed,
+		Message: message,
+	})
+}
+
+func NotModified(w http.ResponseWriter) error {
+	w.WriteHeader(http.StatusNotModified)
+	return nil
+}
+
 func NoContent(w http.ResponseWriter) error {
 	w.WriteHeader(http.StatusNoContent)
 	return nil
@@ -84,3 +84,84 @@ func TestSuccessAndCreatedAndNoContent(t *testing.T) {
     }
 }
 
+
+func TestConflict(t *testing.T) {
+    rr := httptest.NewRecorder()
+    if err := Conflict(rr, "conflict"); err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if rr.Code != http.StatusConflict {
+        t.Fatalf("expected %d, got %d", http.StatusConflict, rr.Code)
+    }
+    var resp Response
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+        t.Fatalf("json: %v", err)
+    }
+    if resp.Status != http.StatusConflict || resp.Message != "conflict" {
+        t.Fatalf("unexpected payload: %+v", resp)
+    }
+}
+
+func TestPreconditionFailed(t *testing.T) {
+    rr := httptest.NewRecorder()
+    if err := PreconditionFailed(rr, "stale"); err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if rr.Code != http.StatusPreconditionFailed {
+        t.Fatalf("expected %d, got %d", http.StatusPreconditionFailed, rr.Code)
+    }
+    var resp Response
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+        t.Fatalf("json: %v", err)
+    }
+    if resp.Status != http.StatusPreconditionFailed || resp.Message != "stale" {
+        t.Fatalf("unexpected payload: %+v", resp)
+    }
+}
+
+func TestNotModified(t *testing.T) {
+    rr := httptest.NewRecorder()
+    if err := NotModified(rr); err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if rr.Code != http.StatusNotModified {
+        t.Fatalf("expected %d, got %d", http.StatusNotModified, rr.Code)
+    }
+    if rr.Body.Len() != 0 {
+        t.Fatalf("expected empty body, got %q", rr.Body.String())
+    }
+}
+
+func TestUnprocessableEntity(t *testing.T) {
+    rr := httptest.NewRecorder()
+    if err := UnprocessableEntity(rr, "reused"); err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if rr.Code != http.StatusUnprocessableEntity {
+        t.Fatalf("expected %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+    }
+    var resp Response
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+        t.Fatalf("json: %v", err)
+    }
+    if resp.Status != http.StatusUnprocessableEntity || resp.Message != "reused" {
+        t.Fatalf("unexpected payload: %+v", resp)
+    }
+}
+
+func TestWrite(t *testing.T) {
+    rr := httptest.NewRecorder()
+    if err := Write(rr, Response{Status: http.StatusFailedDependency, Message: "not applied"}); err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if rr.Code != http.StatusFailedDependency {
+        t.Fatalf("expected %d, got %d", http.StatusFailedDependency, rr.Code)
+    }
+    var resp Response
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+        t.Fatalf("json: %v", err)
+    }
+    if resp.Status != http.StatusFailedDependency || resp.Message != "not applied" {
+        t.Fatalf("unexpected payload: %+v", resp)
+    }
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"testovoe/internal/models"
+
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// EventFields are the fields of a subscription the audit trail can be
+// filtered by changes of.
+var EventFields = []string{
+	"service_id",
+	"service_name",
+	"price",
+	"currency",
+	"user_id",
+	"start_date",
+	"end_date",
+	"billing_period",
+	"billing_interval_months",
+	"trial_end",
+	"category",
+	"tags",
+	"metadata",
+	"notes",
+	"pauses",
+}
+
+//go:generate mockgen -source=audit.go -destination=mocks/audit.go
+type AuditStorage interface {
+	// Events returns the events matching the filter, newest first, and
+	// whether more of them follow. ErrInvalidField is returned for a
+	// filter.Changed not in EventFields.
+	Events(ctx context.Context, filter models.EventFilter, limit, offset int) ([]models.SubscriptionEvent, bool, error)
+}
+
+// PostgresAuditStorage reads the audit trail. It is written by
+// PostgresSubscriptionStorage, in the transaction of every change.
+type PostgresAuditStorage struct {
+	db *sql.DB
+}
+
+func NewPostgresAuditStorage(db *sql.DB) AuditStorage {
+	return &PostgresAuditStorage{
+		db: db,
+	}
+}
+
+func (s *PostgresAuditStorage) Events(ctx context.Context, filter models.EventFilter, limit, offset int) ([]models.SubscriptionEvent, bool, error) {
+	if limit <= 0 || limit > MaxLimit {
+		limit = MaxLimit
+	}
+	if filter.Changed != "" && !slices.Contains(EventFields, filter.Changed) {
+		return nil, false, ErrInvalidField
+	}
+
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	sb.Select("id", "subscription_id", "action", "before", "after", "actor", "request_id", "created_at").
+		From("subscription_events")
+
+	var conds []string
+	if filter.SubscriptionID != 0 {
+		conds = append(conds, sb.Equal("subscription_id", filter.SubscriptionID))
+	}
+	if filter.UserID != "" {
+		conds = append(conds, sb.Or(
+			sb.Equal("before->>'user_id'", filter.UserID),
+			sb.Equal("after->>'user_id'", filter.UserID),
+		))
+	}
+	if filter.Actor != "" {
+		conds = append(conds, sb.Equal("actor", filter.Actor))
+	}
+	if filter.RequestID != "" {
+		conds = append(conds, sb.Equal("request_id", filter.RequestID))
+	}
+	if filter.Action != "" {
+		conds = append(conds, sb.Equal("action", filter.Action))
+	}
+	if filter.Changed != "" {
+		conds = append(conds, changedCond(filter.Changed))
+	}
+	if !filter.From.IsZero() {
+		conds = append(conds, sb.GreaterEqualThan("created_at", filter.From))
+	}
+	if !filter.To.IsZero() {
+		conds = append(conds, sb.LessEqualThan("created_at", filter.To))
+	}
+	if len(conds) > 0 {
+		sb.Where(sb.And(conds...))
+	}
+
+	sb.OrderBy("id DESC")
+	// One more row tells whether there is a next page.
+	sb.Limit(limit + 1)
+	if offset > 0 {
+		sb.Offset(offset)
+	}
+
+	q, args := sb.Build()
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var out []models.SubscriptionEvent
+	for rows.Next() {
+		var event models.SubscriptionEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.SubscriptionID,
+			&event.Action,
+			rawJSON{&event.Before},
+			rawJSON{&event.After},
+			&event.Actor,
+			&event.RequestID,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, false, err
+		}
+		out = append(out, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	if len(out) > limit {
+		return out[:limit], true, nil
+	}
+	return out, false, nil
+}
+
+// changedCond selects the events whose snapshots differ in field, one of
+// EventFields. A created subscription has no snapshot before, so its
+// creation changes every field.
+func changedCond(field string) string {
+	keys := []string{field}
+	if field == "price" {
+		// A change of a future price only shows in the history.
+		keys = []string{"price", "current_price", "prices"}
+	}
+	conds := make([]string, 0, len(keys))
+	for _, key := range keys {
+		conds = append(conds, fmt.Sprintf("before->'%[1]s' IS DISTINCT FROM after->'%[1]s'", key))
+	}
+	return "(" + strings.Join(conds, " OR ") + ")"
+}
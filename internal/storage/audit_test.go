@@ -0,0 +1,124 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"testovoe/internal/audit"
+	"testovoe/internal/models"
+	"testovoe/internal/storage"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAuditTrail(t *testing.T) {
+	db := newTestDB(t)
+	subs := storage.NewPostgresSubscriptionStorage(db)
+	events := storage.NewPostgresAuditStorage(db)
+	ctx := audit.WithRequestID(audit.WithActor(context.Background(), "support"), "req-1")
+
+	sub := models.Subscription{
+		ServiceName:   "Netflix",
+		Price:         100,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartDate:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		BillingPeriod: models.BillingMonthly,
+	}
+	id, err := subs.Create(ctx, &sub)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := subs.ChangePrice(ctx, id, models.PriceChange{Price: 200, EffectiveFrom: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatalf("change price: %v", err)
+	}
+	endDate := time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if _, err := subs.Patch(context.Background(), id, 0, models.SubscriptionPatch{EndDate: &sql.NullTime{Time: endDate, Valid: true}}); err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	if err := subs.Delete(ctx, id, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	// A failed change isn't recorded.
+	if err := subs.Delete(ctx, id, 0); err == nil {
+		t.Fatal("delete twice succeeded")
+	}
+	if _, err := subs.Restore(ctx, id); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+
+	list := func(filter models.EventFilter) []models.SubscriptionEvent {
+		t.Helper()
+		filter.SubscriptionID = id
+		got, _, err := events.Events(context.Background(), filter, 0, 0)
+		if err != nil {
+			t.Fatalf("events: %v", err)
+		}
+		return got
+	}
+
+	history := list(models.EventFilter{})
+	var actions []string
+	for _, event := range history {
+		actions = append(actions, event.Action)
+	}
+	want := []string{"restore", "delete", "update", "update", "create"}
+	if len(actions) != len(want) {
+		t.Fatalf("actions %v, want %v", actions, want)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Fatalf("actions %v, want %v", actions, want)
+		}
+	}
+
+	created := history[4]
+	if created.Before != nil || created.Actor.String != "support" || created.RequestID.String != "req-1" {
+		t.Errorf("create event: before %s, actor %v, request id %v", created.Before, created.Actor, created.RequestID)
+	}
+	var after struct {
+		Price  int `json:"price"`
+		Prices []struct {
+			Price int `json:"price"`
+		} `json:"prices"`
+	}
+	if err := json.Unmarshal(history[3].After, &after); err != nil {
+		t.Fatalf("unmarshal snapshot: %v", err)
+	}
+	if len(after.Prices) != 2 || after.Prices[1].Price != 200 {
+		t.Errorf("price change snapshot: %s", history[3].After)
+	}
+	if patched := history[2]; patched.Actor.Valid || patched.RequestID.Valid {
+		t.Errorf("patch without an actor: actor %v, request id %v", patched.Actor, patched.RequestID)
+	}
+
+	for _, tc := range []struct {
+		filter models.EventFilter
+		want   int
+	}{
+		{models.EventFilter{Changed: "price"}, 2},
+		{models.EventFilter{Changed: "end_date"}, 2},
+		{models.EventFilter{Action: models.EventDelete}, 1},
+		{models.EventFilter{Actor: "support"}, 4},
+		{models.EventFilter{UserID: sub.UserID.String()}, 5},
+		{models.EventFilter{UserID: uuid.NewString()}, 0},
+		{models.EventFilter{From: time.Now().Add(time.Hour)}, 0},
+	} {
+		if got := list(tc.filter); len(got) != tc.want {
+			t.Errorf("%+v: %d events, want %d", tc.filter, len(got), tc.want)
+		}
+	}
+
+	// The history outlives the purge.
+	if err := subs.Delete(ctx, id, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := subs.Purge(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if got := list(models.EventFilter{}); len(got) != 6 {
+		t.Errorf("purged subscription: %d events, want 6", len(got))
+	}
+}
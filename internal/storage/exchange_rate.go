@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testovoe/internal/currency"
+)
+
+//go:generate mockgen -source=exchange_rate.go -destination=mocks/exchange_rate.go
+type ExchangeRateStorage interface {
+	Rates(ctx context.Context) (currency.Rates, error)
+}
+
+// PostgresExchangeRateStorage reads rates from the exchange_rates table,
+// so it can be used as a currency.RateProvider.
+type PostgresExchangeRateStorage struct {
+	db *sql.DB
+}
+
+func NewPostgresExchangeRateStorage(db *sql.DB) ExchangeRateStorage {
+	return &PostgresExchangeRateStorage{
+		db: db,
+	}
+}
+
+func (s *PostgresExchangeRateStorage) Rates(ctx context.Context) (currency.Rates, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT currency, rate FROM exchange_rates`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := currency.Rates{}
+	for rows.Next() {
+		var code string
+		var rate float64
+		if err := rows.Scan(&code, &rate); err != nil {
+			return nil, err
+		}
+		rates[code] = rate
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testovoe/internal/models"
+	"time"
+)
+
+//go:generate mockgen -source=idempotency.go -destination=mocks/idempotency.go
+type IdempotencyStorage interface {
+	// Reserve claims the key of the client for a request with the given
+	// hash. It returns nil if the request should be handled, or the
+	// response to replay if a request with the same key was already
+	// completed. Keys older than ttl are forgotten. The same key of
+	// another client is a different key.
+	Reserve(ctx context.Context, client, key, requestHash string, ttl time.Duration) (*models.IdempotentResponse, error)
+	// Complete stores the response to a reserved key.
+	Complete(ctx context.Context, client, key string, resp models.IdempotentResponse) error
+	// Release frees a reserved key, so that the request can be retried.
+	Release(ctx context.Context, client, key string) error
+}
+
+type PostgresIdempotencyStorage struct {
+	db *sql.DB
+}
+
+func NewPostgresIdempotencyStorage(db *sql.DB) IdempotencyStorage {
+	return &PostgresIdempotencyStorage{
+		db: db,
+	}
+}
+
+func (s *PostgresIdempotencyStorage) Reserve(ctx context.Context, client, key, requestHash string, ttl time.Duration) (*models.IdempotentResponse, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < $1`, time.Now().Add(-ttl)); err != nil {
+		return nil, err
+	}
+
+	// A concurrent insert of the same key blocks here until the other
+	// transaction ends, so only one request can claim it.
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (client, key, request_hash) VALUES ($1, $2, $3) ON CONFLICT (client, key) DO NOTHING`,
+		client, key, requestHash,
+	)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 1 {
+		return nil, tx.Commit()
+	}
+
+	var (
+		storedHash string
+		status     sql.NullInt32
+		header     []byte
+		body       []byte
+	)
+	err = tx.QueryRowContext(ctx,
+		`SELECT request_hash, response_status, response_headers, response_body FROM idempotency_keys WHERE client = $1 AND key = $2`,
+		client, key,
+	).Scan(&storedHash, &status, &header, &body)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Released by the first request in the meantime.
+			return nil, ErrIdempotencyKeyInProgress
+		}
+		return nil, err
+	}
+
+	switch {
+	case storedHash != requestHash:
+		return nil, ErrIdempotencyKeyReused
+	case !status.Valid:
+		return nil, ErrIdempotencyKeyInProgress
+	}
+
+	resp := &models.IdempotentResponse{Status: int(status.Int32), Body: body}
+	if header != nil {
+		if err := json.Unmarshal(header, &resp.Header); err != nil {
+			return nil, err
+		}
+	}
+	return resp, tx.Commit()
+}
+
+func (s *PostgresIdempotencyStorage) Complete(ctx context.Context, client, key string, resp models.IdempotentResponse) error {
+	var header json.RawMessage
+	if len(resp.Header) > 0 {
+		var err error
+		if header, err = json.Marshal(resp.Header); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE idempotency_keys SET response_status = $3, response_headers = $4, response_body = $5 WHERE client = $1 AND key = $2`,
+		client, key, resp.Status, jsonText(header), resp.Body,
+	)
+	return err
+}
+
+func (s *PostgresIdempotencyStorage) Release(ctx context.Context, client, key string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE client = $1 AND key = $2 AND response_status IS NULL`,
+		client, key,
+	)
+	return err
+}
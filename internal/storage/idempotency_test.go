@@ -0,0 +1,73 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"testovoe/internal/models"
+	"testovoe/internal/storage"
+	"time"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresIdempotencyStorage(db)
+	ctx := context.Background()
+
+	stored, err := store.Reserve(ctx, "actor:a", "key-1", "hash-1", time.Hour)
+	if err != nil || stored != nil {
+		t.Fatalf("expected a new key, got %v, %v", stored, err)
+	}
+
+	_, err = store.Reserve(ctx, "actor:a", "key-1", "hash-1", time.Hour)
+	if !errors.Is(err, storage.ErrIdempotencyKeyInProgress) {
+		t.Fatalf("expected ErrIdempotencyKeyInProgress, got %v", err)
+	}
+
+	// Another client can use the same key.
+	stored, err = store.Reserve(ctx, "actor:b", "key-1", "hash-2", time.Hour)
+	if err != nil || stored != nil {
+		t.Fatalf("expected a new key of another client, got %v, %v", stored, err)
+	}
+
+	resp := models.IdempotentResponse{
+		Status: 201,
+		Header: http.Header{"Etag": {`"1"`}},
+		Body:   []byte(`{"id":1}`),
+	}
+	if err := store.Complete(ctx, "actor:a", "key-1", resp); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+
+	stored, err = store.Reserve(ctx, "actor:a", "key-1", "hash-1", time.Hour)
+	if err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	if stored == nil || stored.Status != 201 || string(stored.Body) != `{"id":1}` || stored.Header.Get("ETag") != `"1"` {
+		t.Fatalf("unexpected stored response: %+v", stored)
+	}
+
+	_, err = store.Reserve(ctx, "actor:a", "key-1", "hash-2", time.Hour)
+	if !errors.Is(err, storage.ErrIdempotencyKeyReused) {
+		t.Fatalf("expected ErrIdempotencyKeyReused, got %v", err)
+	}
+
+	// A completed key is kept by Release, but forgotten after the TTL.
+	if err := store.Release(ctx, "actor:a", "key-1"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	stored, err = store.Reserve(ctx, "actor:a", "key-1", "hash-2", time.Millisecond)
+	if err != nil || stored != nil {
+		t.Fatalf("expected the key to expire, got %v, %v", stored, err)
+	}
+
+	if err := store.Release(ctx, "actor:a", "key-1"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	stored, err = store.Reserve(ctx, "actor:a", "key-1", "hash-3", time.Hour)
+	if err != nil || stored != nil {
+		t.Fatalf("expected a released key, got %v, %v", stored, err)
+	}
+}
@@ -0,0 +1,58 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: audit.go
+//
+// Generated by this command:
+//
+//	mockgen -source=audit.go -destination=mocks/audit.go
+//
+
+// Package mock_storage is a generated GoMock package.
+package mock_storage
+
+import (
+	context "context"
+	reflect "reflect"
+	models "testovoe/internal/models"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAuditStorage is a mock of AuditStorage interface.
+type MockAuditStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockAuditStorageMockRecorder is the mock recorder for MockAuditStorage.
+type MockAuditStorageMockRecorder struct {
+	mock *MockAuditStorage
+}
+
+// NewMockAuditStorage creates a new mock instance.
+func NewMockAuditStorage(ctrl *gomock.Controller) *MockAuditStorage {
+	mock := &MockAuditStorage{ctrl: ctrl}
+	mock.recorder = &MockAuditStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditStorage) EXPECT() *MockAuditStorageMockRecorder {
+	return m.recorder
+}
+
+// Events mocks base method.
+func (m *MockAuditStorage) Events(ctx context.Context, filter models.EventFilter, limit, offset int) ([]models.SubscriptionEvent, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Events", ctx, filter, limit, offset)
+	ret0, _ := ret[0].([]models.SubscriptionEvent)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Events indicates an expected call of Events.
+func (mr *MockAuditStorageMockRecorder) Events(ctx, filter, limit, offset any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Events", reflect.TypeOf((*MockAuditStorage)(nil).Events), ctx, filter, limit, offset)
+}
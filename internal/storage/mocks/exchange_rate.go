@@ -0,0 +1,57 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: exchange_rate.go
+//
+// Generated by this command:
+//
+//	mockgen -source=exchange_rate.go -destination=mocks/exchange_rate.go
+//
+
+// Package mock_storage is a generated GoMock package.
+package mock_storage
+
+import (
+	context "context"
+	reflect "reflect"
+	currency "testovoe/internal/currency"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockExchangeRateStorage is a mock of ExchangeRateStorage interface.
+type MockExchangeRateStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockExchangeRateStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockExchangeRateStorageMockRecorder is the mock recorder for MockExchangeRateStorage.
+type MockExchangeRateStorageMockRecorder struct {
+	mock *MockExchangeRateStorage
+}
+
+// NewMockExchangeRateStorage creates a new mock instance.
+func NewMockExchangeRateStorage(ctrl *gomock.Controller) *MockExchangeRateStorage {
+	mock := &MockExchangeRateStorage{ctrl: ctrl}
+	mock.recorder = &MockExchangeRateStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockExchangeRateStorage) EXPECT() *MockExchangeRateStorageMockRecorder {
+	return m.recorder
+}
+
+// Rates mocks base method.
+func (m *MockExchangeRateStorage) Rates(ctx context.Context) (currency.Rates, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rates", ctx)
+	ret0, _ := ret[0].(currency.Rates)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Rates indicates an expected call of Rates.
+func (mr *MockExchangeRateStorageMockRecorder) Rates(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rates", reflect.TypeOf((*MockExchangeRateStorage)(nil).Rates), ctx)
+}
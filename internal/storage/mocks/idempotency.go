@@ -0,0 +1,86 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: idempotency.go
+//
+// Generated by this command:
+//
+//	mockgen -source=idempotency.go -destination=mocks/idempotency.go
+//
+
+// Package mock_storage is a generated GoMock package.
+package mock_storage
+
+import (
+	context "context"
+	reflect "reflect"
+	models "testovoe/internal/models"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockIdempotencyStorage is a mock of IdempotencyStorage interface.
+type MockIdempotencyStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockIdempotencyStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockIdempotencyStorageMockRecorder is the mock recorder for MockIdempotencyStorage.
+type MockIdempotencyStorageMockRecorder struct {
+	mock *MockIdempotencyStorage
+}
+
+// NewMockIdempotencyStorage creates a new mock instance.
+func NewMockIdempotencyStorage(ctrl *gomock.Controller) *MockIdempotencyStorage {
+	mock := &MockIdempotencyStorage{ctrl: ctrl}
+	mock.recorder = &MockIdempotencyStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIdempotencyStorage) EXPECT() *MockIdempotencyStorageMockRecorder {
+	return m.recorder
+}
+
+// Complete mocks base method.
+func (m *MockIdempotencyStorage) Complete(ctx context.Context, client, key string, resp models.IdempotentResponse) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Complete", ctx, client, key, resp)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Complete indicates an expected call of Complete.
+func (mr *MockIdempotencyStorageMockRecorder) Complete(ctx, client, key, resp any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Complete", reflect.TypeOf((*MockIdempotencyStorage)(nil).Complete), ctx, client, key, resp)
+}
+
+// Release mocks base method.
+func (m *MockIdempotencyStorage) Release(ctx context.Context, client, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", ctx, client, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockIdempotencyStorageMockRecorder) Release(ctx, client, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockIdempotencyStorage)(nil).Release), ctx, client, key)
+}
+
+// Reserve mocks base method.
+func (m *MockIdempotencyStorage) Reserve(ctx context.Context, client, key, requestHash string, ttl time.Duration) (*models.IdempotentResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reserve", ctx, client, key, requestHash, ttl)
+	ret0, _ := ret[0].(*models.IdempotentResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reserve indicates an expected call of Reserve.
+func (mr *MockIdempotencyStorageMockRecorder) Reserve(ctx, client, key, requestHash, ttl any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reserve", reflect.TypeOf((*MockIdempotencyStorage)(nil).Reserve), ctx, client, key, requestHash, ttl)
+}
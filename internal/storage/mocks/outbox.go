@@ -0,0 +1,57 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: outbox.go
+//
+// Generated by this command:
+//
+//	mockgen -source=outbox.go -destination=mocks/outbox.go
+//
+
+// Package mock_storage is a generated GoMock package.
+package mock_storage
+
+import (
+	context "context"
+	reflect "reflect"
+	models "testovoe/internal/models"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockOutboxStorage is a mock of OutboxStorage interface.
+type MockOutboxStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockOutboxStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockOutboxStorageMockRecorder is the mock recorder for MockOutboxStorage.
+type MockOutboxStorageMockRecorder struct {
+	mock *MockOutboxStorage
+}
+
+// NewMockOutboxStorage creates a new mock instance.
+func NewMockOutboxStorage(ctrl *gomock.Controller) *MockOutboxStorage {
+	mock := &MockOutboxStorage{ctrl: ctrl}
+	mock.recorder = &MockOutboxStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOutboxStorage) EXPECT() *MockOutboxStorageMockRecorder {
+	return m.recorder
+}
+
+// Dispatch mocks base method.
+func (m *MockOutboxStorage) Dispatch(ctx context.Context, limit int, fn func(context.Context, []models.OutboxEvent) error) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Dispatch", ctx, limit, fn)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Dispatch indicates an expected call of Dispatch.
+func (mr *MockOutboxStorageMockRecorder) Dispatch(ctx, limit, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Dispatch", reflect.TypeOf((*MockOutboxStorage)(nil).Dispatch), ctx, limit, fn)
+}
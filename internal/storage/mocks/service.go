@@ -0,0 +1,115 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: service.go
+//
+// Generated by this command:
+//
+//	mockgen -source=service.go -destination=mocks/service.go
+//
+
+// Package mock_storage is a generated GoMock package.
+package mock_storage
+
+import (
+	context "context"
+	reflect "reflect"
+	models "testovoe/internal/models"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockServiceStorage is a mock of ServiceStorage interface.
+type MockServiceStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockServiceStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockServiceStorageMockRecorder is the mock recorder for MockServiceStorage.
+type MockServiceStorageMockRecorder struct {
+	mock *MockServiceStorage
+}
+
+// NewMockServiceStorage creates a new mock instance.
+func NewMockServiceStorage(ctrl *gomock.Controller) *MockServiceStorage {
+	mock := &MockServiceStorage{ctrl: ctrl}
+	mock.recorder = &MockServiceStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockServiceStorage) EXPECT() *MockServiceStorageMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockServiceStorage) Create(ctx context.Context, svc *models.Service) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, svc)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockServiceStorageMockRecorder) Create(ctx, svc any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockServiceStorage)(nil).Create), ctx, svc)
+}
+
+// Delete mocks base method.
+func (m *MockServiceStorage) Delete(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockServiceStorageMockRecorder) Delete(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockServiceStorage)(nil).Delete), ctx, id)
+}
+
+// Get mocks base method.
+func (m *MockServiceStorage) Get(ctx context.Context, id int) (*models.Service, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, id)
+	ret0, _ := ret[0].(*models.Service)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockServiceStorageMockRecorder) Get(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockServiceStorage)(nil).Get), ctx, id)
+}
+
+// List mocks base method.
+func (m *MockServiceStorage) List(ctx context.Context) ([]models.Service, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx)
+	ret0, _ := ret[0].([]models.Service)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockServiceStorageMockRecorder) List(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockServiceStorage)(nil).List), ctx)
+}
+
+// Update mocks base method.
+func (m *MockServiceStorage) Update(ctx context.Context, id int, svc *models.Service) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, id, svc)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockServiceStorageMockRecorder) Update(ctx, id, svc any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockServiceStorage)(nil).Update), ctx, id, svc)
+}
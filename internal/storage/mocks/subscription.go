@@ -13,6 +13,7 @@ import (
 	context "context"
 	reflect "reflect"
 	models "testovoe/internal/models"
+	storage "testovoe/internal/storage"
 	time "time"
 
 	uuid "github.com/google/uuid"
@@ -43,6 +44,35 @@ func (m *MockSubscriptionStorage) EXPECT() *MockSubscriptionStorageMockRecorder
 	return m.recorder
 }
 
+// Breakdown mocks base method.
+func (m *MockSubscriptionStorage) Breakdown(ctx context.Context, periodStart, periodEnd time.Time, userID uuid.UUID, serviceName, groupBy string, prorate bool) ([]models.BreakdownItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Breakdown", ctx, periodStart, periodEnd, userID, serviceName, groupBy, prorate)
+	ret0, _ := ret[0].([]models.BreakdownItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Breakdown indicates an expected call of Breakdown.
+func (mr *MockSubscriptionStorageMockRecorder) Breakdown(ctx, periodStart, periodEnd, userID, serviceName, groupBy, prorate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Breakdown", reflect.TypeOf((*MockSubscriptionStorage)(nil).Breakdown), ctx, periodStart, periodEnd, userID, serviceName, groupBy, prorate)
+}
+
+// ChangePrice mocks base method.
+func (m *MockSubscriptionStorage) ChangePrice(ctx context.Context, id int, change models.PriceChange) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePrice", ctx, id, change)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangePrice indicates an expected call of ChangePrice.
+func (mr *MockSubscriptionStorageMockRecorder) ChangePrice(ctx, id, change any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePrice", reflect.TypeOf((*MockSubscriptionStorage)(nil).ChangePrice), ctx, id, change)
+}
+
 // Create mocks base method.
 func (m *MockSubscriptionStorage) Create(ctx context.Context, sub *models.Subscription) (int, error) {
 	m.ctrl.T.Helper()
@@ -59,17 +89,31 @@ func (mr *MockSubscriptionStorageMockRecorder) Create(ctx, sub any) *gomock.Call
 }
 
 // Delete mocks base method.
-func (m *MockSubscriptionStorage) Delete(ctx context.Context, id int) error {
+func (m *MockSubscriptionStorage) Delete(ctx context.Context, id, version int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", ctx, id)
+	ret := m.ctrl.Call(m, "Delete", ctx, id, version)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockSubscriptionStorageMockRecorder) Delete(ctx, id any) *gomock.Call {
+func (mr *MockSubscriptionStorageMockRecorder) Delete(ctx, id, version any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSubscriptionStorage)(nil).Delete), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSubscriptionStorage)(nil).Delete), ctx, id, version)
+}
+
+// Export mocks base method.
+func (m *MockSubscriptionStorage) Export(ctx context.Context, filter models.SubscriptionFilter, fn func(*models.Subscription) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", ctx, filter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockSubscriptionStorageMockRecorder) Export(ctx, filter, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockSubscriptionStorage)(nil).Export), ctx, filter, fn)
 }
 
 // Get mocks base method.
@@ -87,42 +131,193 @@ func (mr *MockSubscriptionStorageMockRecorder) Get(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockSubscriptionStorage)(nil).Get), ctx, id)
 }
 
+// InTx mocks base method.
+func (m *MockSubscriptionStorage) InTx(ctx context.Context, fn func(storage.SubscriptionStorage) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InTx indicates an expected call of InTx.
+func (mr *MockSubscriptionStorageMockRecorder) InTx(ctx, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InTx", reflect.TypeOf((*MockSubscriptionStorage)(nil).InTx), ctx, fn)
+}
+
 // List mocks base method.
-func (m *MockSubscriptionStorage) List(ctx context.Context, userID, serviceName string, limit, offset int) ([]models.Subscription, error) {
+func (m *MockSubscriptionStorage) List(ctx context.Context, filter models.SubscriptionFilter, page models.Page) ([]models.Subscription, bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "List", ctx, userID, serviceName, limit, offset)
+	ret := m.ctrl.Call(m, "List", ctx, filter, page)
 	ret0, _ := ret[0].([]models.Subscription)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// List indicates an expected call of List.
+func (mr *MockSubscriptionStorageMockRecorder) List(ctx, filter, page any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSubscriptionStorage)(nil).List), ctx, filter, page)
+}
+
+// Patch mocks base method.
+func (m *MockSubscriptionStorage) Patch(ctx context.Context, id, version int, patch models.SubscriptionPatch) (*models.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Patch", ctx, id, version, patch)
+	ret0, _ := ret[0].(*models.Subscription)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// List indicates an expected call of List.
-func (mr *MockSubscriptionStorageMockRecorder) List(ctx, userID, serviceName, limit, offset any) *gomock.Call {
+// Patch indicates an expected call of Patch.
+func (mr *MockSubscriptionStorageMockRecorder) Patch(ctx, id, version, patch any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSubscriptionStorage)(nil).List), ctx, userID, serviceName, limit, offset)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Patch", reflect.TypeOf((*MockSubscriptionStorage)(nil).Patch), ctx, id, version, patch)
 }
 
-// TotalForPeriod mocks base method.
-func (m *MockSubscriptionStorage) TotalForPeriod(ctx context.Context, periodStart, periodEnd time.Time, userID uuid.UUID, serviceName string) (int64, error) {
+// Pause mocks base method.
+func (m *MockSubscriptionStorage) Pause(ctx context.Context, id int, pause models.Pause) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pause", ctx, id, pause)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Pause indicates an expected call of Pause.
+func (mr *MockSubscriptionStorageMockRecorder) Pause(ctx, id, pause any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pause", reflect.TypeOf((*MockSubscriptionStorage)(nil).Pause), ctx, id, pause)
+}
+
+// Pauses mocks base method.
+func (m *MockSubscriptionStorage) Pauses(ctx context.Context, id int) ([]models.Pause, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pauses", ctx, id)
+	ret0, _ := ret[0].([]models.Pause)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Pauses indicates an expected call of Pauses.
+func (mr *MockSubscriptionStorageMockRecorder) Pauses(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pauses", reflect.TypeOf((*MockSubscriptionStorage)(nil).Pauses), ctx, id)
+}
+
+// PriceHistory mocks base method.
+func (m *MockSubscriptionStorage) PriceHistory(ctx context.Context, id int) ([]models.PriceChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PriceHistory", ctx, id)
+	ret0, _ := ret[0].([]models.PriceChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PriceHistory indicates an expected call of PriceHistory.
+func (mr *MockSubscriptionStorageMockRecorder) PriceHistory(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PriceHistory", reflect.TypeOf((*MockSubscriptionStorage)(nil).PriceHistory), ctx, id)
+}
+
+// Purge mocks base method.
+func (m *MockSubscriptionStorage) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "TotalForPeriod", ctx, periodStart, periodEnd, userID, serviceName)
+	ret := m.ctrl.Call(m, "Purge", ctx, deletedBefore)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
+// Purge indicates an expected call of Purge.
+func (mr *MockSubscriptionStorageMockRecorder) Purge(ctx, deletedBefore any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockSubscriptionStorage)(nil).Purge), ctx, deletedBefore)
+}
+
+// Restore mocks base method.
+func (m *MockSubscriptionStorage) Restore(ctx context.Context, id int) (*models.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, id)
+	ret0, _ := ret[0].(*models.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockSubscriptionStorageMockRecorder) Restore(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockSubscriptionStorage)(nil).Restore), ctx, id)
+}
+
+// Resume mocks base method.
+func (m *MockSubscriptionStorage) Resume(ctx context.Context, id int, on time.Time) (*models.Pause, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Resume", ctx, id, on)
+	ret0, _ := ret[0].(*models.Pause)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Resume indicates an expected call of Resume.
+func (mr *MockSubscriptionStorageMockRecorder) Resume(ctx, id, on any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resume", reflect.TypeOf((*MockSubscriptionStorage)(nil).Resume), ctx, id, on)
+}
+
+// Search mocks base method.
+func (m *MockSubscriptionStorage) Search(ctx context.Context, query string, filter models.SubscriptionFilter, limit, offset int) ([]models.SearchResult, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", ctx, query, filter, limit, offset)
+	ret0, _ := ret[0].([]models.SearchResult)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockSubscriptionStorageMockRecorder) Search(ctx, query, filter, limit, offset any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockSubscriptionStorage)(nil).Search), ctx, query, filter, limit, offset)
+}
+
+// TimeSeries mocks base method.
+func (m *MockSubscriptionStorage) TimeSeries(ctx context.Context, periodStart, periodEnd time.Time, userID uuid.UUID, serviceName string, groupByService, prorate bool) ([]models.MonthlyTotal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TimeSeries", ctx, periodStart, periodEnd, userID, serviceName, groupByService, prorate)
+	ret0, _ := ret[0].([]models.MonthlyTotal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TimeSeries indicates an expected call of TimeSeries.
+func (mr *MockSubscriptionStorageMockRecorder) TimeSeries(ctx, periodStart, periodEnd, userID, serviceName, groupByService, prorate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TimeSeries", reflect.TypeOf((*MockSubscriptionStorage)(nil).TimeSeries), ctx, periodStart, periodEnd, userID, serviceName, groupByService, prorate)
+}
+
+// TotalForPeriod mocks base method.
+func (m *MockSubscriptionStorage) TotalForPeriod(ctx context.Context, periodStart, periodEnd time.Time, userID uuid.UUID, serviceName string, prorate bool) (map[string]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TotalForPeriod", ctx, periodStart, periodEnd, userID, serviceName, prorate)
+	ret0, _ := ret[0].(map[string]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // TotalForPeriod indicates an expected call of TotalForPeriod.
-func (mr *MockSubscriptionStorageMockRecorder) TotalForPeriod(ctx, periodStart, periodEnd, userID, serviceName any) *gomock.Call {
+func (mr *MockSubscriptionStorageMockRecorder) TotalForPeriod(ctx, periodStart, periodEnd, userID, serviceName, prorate any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TotalForPeriod", reflect.TypeOf((*MockSubscriptionStorage)(nil).TotalForPeriod), ctx, periodStart, periodEnd, userID, serviceName)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TotalForPeriod", reflect.TypeOf((*MockSubscriptionStorage)(nil).TotalForPeriod), ctx, periodStart, periodEnd, userID, serviceName, prorate)
 }
 
 // Update mocks base method.
-func (m *MockSubscriptionStorage) Update(ctx context.Context, id int, sub *models.Subscription) error {
+func (m *MockSubscriptionStorage) Update(ctx context.Context, id int, sub *models.Subscription) (*models.Subscription, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Update", ctx, id, sub)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(*models.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Update indicates an expected call of Update.
@@ -130,3 +325,45 @@ func (mr *MockSubscriptionStorageMockRecorder) Update(ctx, id, sub any) *gomock.
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockSubscriptionStorage)(nil).Update), ctx, id, sub)
 }
+
+// MockrowScanner is a mock of rowScanner interface.
+type MockrowScanner struct {
+	ctrl     *gomock.Controller
+	recorder *MockrowScannerMockRecorder
+	isgomock struct{}
+}
+
+// MockrowScannerMockRecorder is the mock recorder for MockrowScanner.
+type MockrowScannerMockRecorder struct {
+	mock *MockrowScanner
+}
+
+// NewMockrowScanner creates a new mock instance.
+func NewMockrowScanner(ctrl *gomock.Controller) *MockrowScanner {
+	mock := &MockrowScanner{ctrl: ctrl}
+	mock.recorder = &MockrowScannerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockrowScanner) EXPECT() *MockrowScannerMockRecorder {
+	return m.recorder
+}
+
+// Scan mocks base method.
+func (m *MockrowScanner) Scan(dest ...any) error {
+	m.ctrl.T.Helper()
+	varargs := []any{}
+	for _, a := range dest {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Scan", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Scan indicates an expected call of Scan.
+func (mr *MockrowScannerMockRecorder) Scan(dest ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockrowScanner)(nil).Scan), dest...)
+}
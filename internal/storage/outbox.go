@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testovoe/internal/models"
+
+	"github.com/lib/pq"
+)
+
+//go:generate mockgen -source=outbox.go -destination=mocks/outbox.go
+type OutboxStorage interface {
+	// Dispatch passes up to limit events of the outbox, oldest first, to
+	// fn and removes them if it returns nil. Otherwise they are kept and
+	// the error is returned. Events being dispatched by another call are
+	// skipped, so that several dispatchers can run at once. It returns the
+	// number of dispatched events.
+	Dispatch(ctx context.Context, limit int, fn func(context.Context, []models.OutboxEvent) error) (int, error)
+}
+
+// PostgresOutboxStorage reads the outbox. It is written by
+// PostgresSubscriptionStorage, in the transaction of every change.
+type PostgresOutboxStorage struct {
+	db *sql.DB
+}
+
+func NewPostgresOutboxStorage(db *sql.DB) OutboxStorage {
+	return &PostgresOutboxStorage{
+		db: db,
+	}
+}
+
+func (s *PostgresOutboxStorage) Dispatch(ctx context.Context, limit int, fn func(context.Context, []models.OutboxEvent) error) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// The rows stay locked until they are removed, or released on an
+	// error.
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, type, subscription_id, subscription, previous, actor, request_id, created_at
+		FROM outbox_events ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED`,
+		limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var (
+		events []models.OutboxEvent
+		ids    []int64
+	)
+	for rows.Next() {
+		var event models.OutboxEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.Type,
+			&event.SubscriptionID,
+			rawJSON{&event.Subscription},
+			rawJSON{&event.Previous},
+			&event.Actor,
+			&event.RequestID,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return 0, err
+		}
+		events = append(events, event)
+		ids = append(ids, event.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	if err := fn(ctx, events); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM outbox_events WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
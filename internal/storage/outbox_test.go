@@ -0,0 +1,152 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"testovoe/internal/models"
+	"testovoe/internal/storage"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestOutbox(t *testing.T) {
+	db := newTestDB(t)
+	subs := storage.NewPostgresSubscriptionStorage(db)
+	outbox := storage.NewPostgresOutboxStorage(db)
+	ctx := context.Background()
+
+	dispatched := func(limit int) []string {
+		t.Helper()
+		var types []string
+		_, err := outbox.Dispatch(ctx, limit, func(_ context.Context, events []models.OutboxEvent) error {
+			for _, event := range events {
+				types = append(types, event.Type)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("dispatch: %v", err)
+		}
+		return types
+	}
+
+	sub := models.Subscription{
+		ServiceName:   "Netflix",
+		Price:         100,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartDate:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		BillingPeriod: models.BillingMonthly,
+	}
+	id, err := subs.Create(ctx, &sub)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	endDate := &sql.NullTime{Time: time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC), Valid: true}
+	if _, err := subs.Patch(ctx, id, 0, models.SubscriptionPatch{EndDate: endDate}); err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	// The end date doesn't change, the subscription isn't ended again.
+	if _, err := subs.Patch(ctx, id, 0, models.SubscriptionPatch{EndDate: endDate}); err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	if err := subs.Delete(ctx, id, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	// A change rolled back with its transaction leaves no events.
+	errRollback := errors.New("rollback")
+	err = subs.InTx(ctx, func(tx storage.SubscriptionStorage) error {
+		if _, err := tx.Restore(ctx, id); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("in tx: %v", err)
+	}
+
+	// Failed publishing keeps the events.
+	_, err = outbox.Dispatch(ctx, 10, func(context.Context, []models.OutboxEvent) error {
+		return errors.New("broker is down")
+	})
+	if err == nil {
+		t.Fatal("dispatch with a failing sink succeeded")
+	}
+
+	want := []string{
+		models.SubscriptionCreated,
+		models.SubscriptionUpdated,
+		models.SubscriptionEnded,
+		models.SubscriptionUpdated,
+	}
+	if got := dispatched(4); !slices.Equal(got, want) {
+		t.Errorf("events %v, want %v", got, want)
+	}
+	if got := dispatched(10); !slices.Equal(got, []string{models.SubscriptionDeleted}) {
+		t.Errorf("events %v, want %v", got, []string{models.SubscriptionDeleted})
+	}
+	if got := dispatched(10); len(got) != 0 {
+		t.Errorf("dispatched events are dispatched again: %v", got)
+	}
+}
+
+func TestOutboxFutureEndDate(t *testing.T) {
+	db := newTestDB(t)
+	subs := storage.NewPostgresSubscriptionStorage(db)
+	outbox := storage.NewPostgresOutboxStorage(db)
+	ctx := context.Background()
+
+	id, err := subs.Create(ctx, &models.Subscription{
+		ServiceName:   "Netflix",
+		Price:         100,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartDate:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		BillingPeriod: models.BillingMonthly,
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	now := time.Now()
+	for _, end := range []time.Time{
+		// Scheduled and rescheduled, the subscription is still live.
+		now.AddDate(1, 0, 0),
+		now.AddDate(2, 0, 0),
+		// Ended today, then the end is moved back.
+		now,
+		now.AddDate(0, 0, -1),
+	} {
+		endDate := &sql.NullTime{Time: time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC), Valid: true}
+		if _, err := subs.Patch(ctx, id, 0, models.SubscriptionPatch{EndDate: endDate}); err != nil {
+			t.Fatalf("patch: %v", err)
+		}
+	}
+
+	var types []string
+	_, err = outbox.Dispatch(ctx, 10, func(_ context.Context, events []models.OutboxEvent) error {
+		for _, event := range events {
+			types = append(types, event.Type)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	want := []string{
+		models.SubscriptionCreated,
+		models.SubscriptionUpdated,
+		models.SubscriptionUpdated,
+		models.SubscriptionUpdated,
+		models.SubscriptionEnded,
+		models.SubscriptionUpdated,
+	}
+	if !slices.Equal(types, want) {
+		t.Errorf("events %v, want %v", types, want)
+	}
+}
@@ -0,0 +1,332 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testovoe/internal/models"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/lib/pq"
+)
+
+//go:generate mockgen -source=service.go -destination=mocks/service.go
+type ServiceStorage interface {
+	Create(ctx context.Context, svc *models.Service) (int, error)
+	Get(ctx context.Context, id int) (*models.Service, error)
+	Update(ctx context.Context, id int, svc *models.Service) error
+	Delete(ctx context.Context, id int) error
+	List(ctx context.Context) ([]models.Service, error)
+}
+
+type PostgresServiceStorage struct {
+	db *sql.DB
+}
+
+func NewPostgresServiceStorage(db *sql.DB) ServiceStorage {
+	return &PostgresServiceStorage{
+		db: db,
+	}
+}
+
+var serviceColumns = []string{
+	"id",
+	"name",
+	"category",
+	"homepage",
+	"default_price",
+	"currency",
+	"ARRAY(SELECT alias FROM service_aliases WHERE service_id = services.id ORDER BY alias)",
+}
+
+func scanService(row rowScanner, svc *models.Service) error {
+	return row.Scan(
+		&svc.ID,
+		&svc.Name,
+		&svc.Category,
+		&svc.Homepage,
+		&svc.DefaultPrice,
+		&svc.Currency,
+		pq.Array(&svc.Aliases),
+	)
+}
+
+// Create adds a service to the catalog and links the subscriptions named
+// after it or one of its aliases. ErrServiceExists is returned if the
+// name or an alias is taken.
+func (s *PostgresServiceStorage) Create(ctx context.Context, svc *models.Service) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query, args := sqlbuilder.PostgreSQL.NewInsertBuilder().InsertInto("services").
+		Cols("name", "category", "homepage", "default_price", "currency").
+		Values(svc.Name, svc.Category, svc.Homepage, svc.DefaultPrice, svc.Currency).
+		Returning("id").Build()
+
+	var id int
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&id); err != nil {
+		return 0, serviceError(err)
+	}
+	if err := setAliases(ctx, tx, id, svc.Aliases); err != nil {
+		return 0, err
+	}
+	if err := linkSubscriptions(ctx, tx, id); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *PostgresServiceStorage) Get(ctx context.Context, id int) (*models.Service, error) {
+	query, args := sqlbuilder.PostgreSQL.NewSelectBuilder().Select(serviceColumns...).
+		From("services").
+		Where(sqlbuilder.NewCond().Equal("id", id)).
+		Build()
+
+	var svc models.Service
+	if err := scanService(s.db.QueryRowContext(ctx, query, args...), &svc); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &svc, nil
+}
+
+// Update replaces a service and its aliases. The unlinked subscriptions
+// named after its new name or aliases are linked, the linked ones keep
+// their names.
+func (s *PostgresServiceStorage) Update(ctx context.Context, id int, svc *models.Service) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder().Update("services")
+	ub.Set(
+		ub.Assign("name", svc.Name),
+		ub.Assign("category", svc.Category),
+		ub.Assign("homepage", svc.Homepage),
+		ub.Assign("default_price", svc.DefaultPrice),
+		ub.Assign("currency", svc.Currency),
+	).Where(ub.Equal("id", id))
+	q, args := ub.Build()
+
+	res, err := tx.ExecContext(ctx, q, args...)
+	if err != nil {
+		return serviceError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM service_aliases WHERE service_id = $1", id); err != nil {
+		return err
+	}
+	if err := setAliases(ctx, tx, id, svc.Aliases); err != nil {
+		return err
+	}
+	if err := linkSubscriptions(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a service from the catalog. Its subscriptions are
+// unlinked and keep their names.
+func (s *PostgresServiceStorage) Delete(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT true FROM services WHERE id = $1 FOR UPDATE", id).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	if err := unlinkSubscriptions(ctx, tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM services WHERE id = $1", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresServiceStorage) List(ctx context.Context) ([]models.Service, error) {
+	query, args := sqlbuilder.PostgreSQL.NewSelectBuilder().Select(serviceColumns...).
+		From("services").
+		OrderBy("name").
+		Build()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Service
+	for rows.Next() {
+		var svc models.Service
+		if err := scanService(rows, &svc); err != nil {
+			return nil, err
+		}
+		out = append(out, svc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func setAliases(ctx context.Context, tx dbtx, id int, aliases []string) error {
+	if len(aliases) == 0 {
+		return nil
+	}
+
+	ib := sqlbuilder.PostgreSQL.NewInsertBuilder().InsertInto("service_aliases").Cols("service_id", "alias")
+	for _, alias := range aliases {
+		ib.Values(id, alias)
+	}
+	q, args := ib.Build()
+	if _, err := tx.ExecContext(ctx, q, args...); err != nil {
+		return serviceError(err)
+	}
+	return nil
+}
+
+// linkableSubscriptionsSQL selects the subscriptions linkSubscriptions
+// links: the unlinked ones named after the service or an alias, not in the
+// trash.
+const linkableSubscriptionsSQL = `
+	SELECT subscriptions.id FROM subscriptions
+	JOIN services ON services.id = $1
+	WHERE subscriptions.service_id IS NULL AND subscriptions.deleted_at IS NULL
+	  AND service_key(subscriptions.service_name) IN (
+		SELECT service_key(services.name)
+		UNION
+		SELECT service_key(alias) FROM service_aliases WHERE service_id = services.id
+	  )
+	ORDER BY subscriptions.id
+	FOR UPDATE OF subscriptions`
+
+// linkedSubscriptionsSQL selects the subscriptions unlinkSubscriptions
+// unlinks: the ones of the service not in the trash.
+const linkedSubscriptionsSQL = `
+	SELECT id FROM subscriptions
+	WHERE service_id = $1 AND deleted_at IS NULL
+	ORDER BY id
+	FOR UPDATE`
+
+// linkSubscriptions links the unlinked subscriptions named after the
+// service or one of its aliases to it. They keep their names.
+func linkSubscriptions(ctx context.Context, tx dbtx, id int) error {
+	return setSubscriptionsService(ctx, tx, linkableSubscriptionsSQL, id, sql.NullInt32{Int32: int32(id), Valid: true})
+}
+
+// unlinkSubscriptions unlinks the subscriptions of the service from it.
+// The ones in the trash are left to the foreign key.
+func unlinkSubscriptions(ctx context.Context, tx dbtx, id int) error {
+	return setSubscriptionsService(ctx, tx, linkedSubscriptionsSQL, id, sql.NullInt32{})
+}
+
+// setSubscriptionsService sets the service of the subscriptions the query
+// selects for the service id. Every change is recorded in the audit trail
+// and the outbox.
+func setSubscriptionsService(ctx context.Context, tx dbtx, query string, id int, serviceID sql.NullInt32) error {
+	rows, err := tx.QueryContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var subID int
+		if err := rows.Scan(&subID); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, subID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, subID := range ids {
+		before, err := snapshot(ctx, tx, subID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE subscriptions SET service_id = $1, version = version + 1 WHERE id = $2`, serviceID, subID)
+		if err != nil {
+			return err
+		}
+		if err := recordEvent(ctx, tx, subID, models.EventUpdate, before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findServiceSQL selects the service a name refers to: the one with this
+// name, or else the one with this alias.
+const findServiceSQL = `
+	SELECT id FROM (
+		SELECT id, 0 AS priority FROM services WHERE service_key(name) = service_key($1)
+		UNION ALL
+		SELECT services.id, 1 FROM service_aliases
+		JOIN services ON services.id = service_aliases.service_id
+		WHERE service_key(alias) = service_key($1)
+	) found
+	ORDER BY priority
+	LIMIT 1`
+
+// resolveService links a subscription to the catalog. With a service id
+// the service must exist, ErrServiceNotFound is returned otherwise, and a
+// subscription without a name takes its name. Without one, the service
+// named name is linked if there is one. A given name is kept as is.
+func resolveService(ctx context.Context, tx dbtx, id *sql.NullInt32, name *string) error {
+	if id.Valid {
+		var serviceName string
+		err := tx.QueryRowContext(ctx, "SELECT name FROM services WHERE id = $1", id.Int32).Scan(&serviceName)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrServiceNotFound
+		}
+		if err == nil && *name == "" {
+			*name = serviceName
+		}
+		return err
+	}
+
+	err := tx.QueryRowContext(ctx, findServiceSQL, *name).Scan(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		*id = sql.NullInt32{}
+		return nil
+	}
+	return err
+}
+
+// serviceError maps a unique violation of a name or an alias to
+// ErrServiceExists.
+func serviceError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrServiceExists
+	}
+	return err
+}
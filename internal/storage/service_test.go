@@ -0,0 +1,156 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"testovoe/internal/models"
+	"testovoe/internal/storage"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestServiceCatalog(t *testing.T) {
+	db := newTestDB(t)
+	subs := storage.NewPostgresSubscriptionStorage(db)
+	services := storage.NewPostgresServiceStorage(db)
+	ctx := context.Background()
+
+	create := func(sub models.Subscription) int {
+		t.Helper()
+		sub.Price = 100
+		sub.Currency = "RUB"
+		sub.UserID = uuid.New()
+		sub.StartDate = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+		sub.BillingPeriod = models.BillingMonthly
+		id, err := subs.Create(ctx, &sub)
+		if err != nil {
+			t.Fatalf("create subscription %q: %v", sub.ServiceName, err)
+		}
+		return id
+	}
+	get := func(id int) *models.Subscription {
+		t.Helper()
+		sub, err := subs.Get(ctx, id)
+		if err != nil {
+			t.Fatalf("get subscription: %v", err)
+		}
+		return sub
+	}
+
+	plus := create(models.Subscription{ServiceName: "yandex  plus"})
+	cyrillic := create(models.Subscription{ServiceName: "Яндекс Плюс"})
+	netflix := create(models.Subscription{ServiceName: "Netflix"})
+	trashed := create(models.Subscription{ServiceName: "Yandex Plus"})
+	if err := subs.Delete(ctx, trashed, 0); err != nil {
+		t.Fatalf("delete subscription: %v", err)
+	}
+
+	// Creating the service links the subscriptions named after it or an
+	// alias, they keep their names. The ones in the trash are left alone.
+	serviceID, err := services.Create(ctx, &models.Service{
+		Name:     "Yandex Plus",
+		Currency: "RUB",
+		Aliases:  []string{"Яндекс Плюс"},
+	})
+	if err != nil {
+		t.Fatalf("create service: %v", err)
+	}
+	for id, name := range map[int]string{plus: "yandex  plus", cyrillic: "Яндекс Плюс"} {
+		sub := get(id)
+		if !sub.ServiceID.Valid || int(sub.ServiceID.Int32) != serviceID || sub.ServiceName != name {
+			t.Errorf("subscription %d: service %v %q, want %d %q", id, sub.ServiceID, sub.ServiceName, serviceID, name)
+		}
+		if sub.Version != 2 {
+			t.Errorf("subscription %d: version %d, want 2", id, sub.Version)
+		}
+	}
+	if sub := get(netflix); sub.ServiceID.Valid || sub.Version != 1 {
+		t.Errorf("netflix: service %v, version %d", sub.ServiceID, sub.Version)
+	}
+	if _, err := subs.Restore(ctx, trashed); err != nil {
+		t.Fatalf("restore subscription: %v", err)
+	}
+	if sub := get(trashed); sub.ServiceID.Valid {
+		t.Errorf("trashed subscription is linked to %d", sub.ServiceID.Int32)
+	}
+
+	// New subscriptions are linked by name, alias or id. Only the ones
+	// without a name take the name of the service.
+	for _, sub := range []models.Subscription{
+		{ServiceName: "YANDEX PLUS"},
+		{ServiceName: "яндекс плюс"},
+		{ServiceID: sql.NullInt32{Int32: int32(serviceID), Valid: true}, ServiceName: "Plus"},
+		{ServiceID: sql.NullInt32{Int32: int32(serviceID), Valid: true}},
+	} {
+		want := sub.ServiceName
+		if want == "" {
+			want = "Yandex Plus"
+		}
+		if got := get(create(sub)); got.ServiceName != want || int(got.ServiceID.Int32) != serviceID {
+			t.Errorf("subscription %q: service %v %q", sub.ServiceName, got.ServiceID, got.ServiceName)
+		}
+	}
+
+	_, err = subs.Create(ctx, &models.Subscription{
+		ServiceID:     sql.NullInt32{Int32: int32(serviceID + 1), Valid: true},
+		Price:         100,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartDate:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		BillingPeriod: models.BillingMonthly,
+	})
+	if !errors.Is(err, storage.ErrServiceNotFound) {
+		t.Errorf("create with an unknown service: %v, want ErrServiceNotFound", err)
+	}
+
+	// Names and aliases are unique regardless of case and spaces.
+	for _, svc := range []models.Service{
+		{Name: " yandex plus", Currency: "RUB"},
+		{Name: "Plus", Currency: "RUB", Aliases: []string{"ЯНДЕКС ПЛЮС"}},
+	} {
+		if _, err := services.Create(ctx, &svc); !errors.Is(err, storage.ErrServiceExists) {
+			t.Errorf("create %q: %v, want ErrServiceExists", svc.Name, err)
+		}
+	}
+
+	// Renaming the service leaves its subscriptions alone.
+	if err := services.Update(ctx, serviceID, &models.Service{Name: "Яндекс Плюс", Currency: "RUB"}); err != nil {
+		t.Fatalf("update service: %v", err)
+	}
+	if sub := get(plus); sub.ServiceName != "yandex  plus" || sub.Version != 2 {
+		t.Errorf("renamed service: subscription %q version %d", sub.ServiceName, sub.Version)
+	}
+	linked, _, err := subs.List(ctx, models.SubscriptionFilter{ServiceID: serviceID}, models.Page{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(linked) != 6 {
+		t.Errorf("service_id filter: %d subscriptions, want 6", len(linked))
+	}
+
+	// Deleting the service unlinks them, they keep their names.
+	if err := services.Delete(ctx, serviceID); err != nil {
+		t.Fatalf("delete service: %v", err)
+	}
+	if sub := get(plus); sub.ServiceID.Valid || sub.ServiceName != "yandex  plus" || sub.Version != 3 {
+		t.Errorf("deleted service: subscription %v %q version %d", sub.ServiceID, sub.ServiceName, sub.Version)
+	}
+	if _, err := services.Get(ctx, serviceID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("get deleted service: %v, want ErrNotFound", err)
+	}
+	if err := services.Delete(ctx, serviceID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("delete deleted service: %v, want ErrNotFound", err)
+	}
+
+	// Linking and unlinking are recorded like any other change.
+	history, _, err := storage.NewPostgresAuditStorage(db).Events(ctx, models.EventFilter{SubscriptionID: plus, Changed: "service_id"}, 0, 0)
+	if err != nil {
+		t.Fatalf("events: %v", err)
+	}
+	if len(history) != 2 {
+		t.Errorf("service_id changes of a linked subscription: %d events, want 2", len(history))
+	}
+}
@@ -6,13 +6,39 @@ import (
 )
 
 var (
-	ErrNotFound = errors.New("not found")
+	ErrNotFound            = errors.New("not found")
+	ErrInvalidGroupBy      = errors.New("invalid group by")
+	ErrInvalidSort         = errors.New("invalid sort")
+	ErrPauseOverlaps       = errors.New("pause overlaps another pause")
+	ErrNotPaused           = errors.New("subscription is not paused")
+	ErrInvalidPatch        = errors.New("patched subscription violates a constraint")
+	ErrInvalidSubscription = errors.New("subscription violates a constraint")
+	ErrStaleVersion        = errors.New("subscription version is stale")
+	ErrInvalidField        = errors.New("invalid field")
+
+	ErrServiceNotFound = errors.New("service not found")
+	ErrServiceExists   = errors.New("service name or alias already exists")
+
+	ErrIdempotencyKeyReused     = errors.New("idempotency key is reused with another request")
+	ErrIdempotencyKeyInProgress = errors.New("request with the idempotency key is in progress")
 )
 
 type Storage struct {
 	Subscription SubscriptionStorage
+	ExchangeRate ExchangeRateStorage
+	Idempotency  IdempotencyStorage
+	Service      ServiceStorage
+	Audit        AuditStorage
+	Outbox       OutboxStorage
 }
 
 func NewPostgresStorage(db *sql.DB) *Storage {
-	return &Storage{Subscription: NewPostgresSubscriptionStorage(db)}
+	return &Storage{
+		Subscription: NewPostgresSubscriptionStorage(db),
+		ExchangeRate: NewPostgresExchangeRateStorage(db),
+		Idempotency:  NewPostgresIdempotencyStorage(db),
+		Service:      NewPostgresServiceStorage(db),
+		Audit:        NewPostgresAuditStorage(db),
+		Outbox:       NewPostgresOutboxStorage(db),
+	}
 }
@@ -0,0 +1,67 @@
+package storage_test
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// newTestDB connects to the database from TEST_POSTGRES_DSN, creates a
+// throwaway schema and applies every up migration to it. Tests that need
+// Postgres are skipped when the variable is not set.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+	})
+
+	// Extensions are installed in public, it has to stay on the path.
+	db, err := sql.Open("postgres", dsn+" search_path="+schema+",public")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrations, err := filepath.Glob("../../migrations/*.up.sql")
+	if err != nil {
+		t.Fatalf("glob migrations: %v", err)
+	}
+	sort.Strings(migrations)
+	for _, m := range migrations {
+		query, err := os.ReadFile(m)
+		if err != nil {
+			t.Fatalf("read migration %s: %v", m, err)
+		}
+		if strings.TrimSpace(string(query)) == "" {
+			continue
+		}
+		if _, err := db.Exec(string(query)); err != nil {
+			t.Fatalf("apply migration %s: %v", m, err)
+		}
+	}
+
+	return db
+}
@@ -1,15 +1,23 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testovoe/internal/audit"
 	"testovoe/internal/models"
 	"testovoe/internal/utils"
+	"testovoe/internal/validators"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/huandu/go-sqlbuilder"
+	"github.com/lib/pq"
 )
 
 const (
@@ -17,23 +25,67 @@ const (
 	MaxLimit      = 1000
 )
 
+// Columns a cost breakdown can be grouped by.
+const (
+	GroupByServiceName = "service_name"
+	GroupByUserID      = "user_id"
+	GroupByCategory    = "category"
+)
+
+var BreakdownGroups = []string{GroupByServiceName, GroupByUserID, GroupByCategory}
+
+// SortColumns are the columns a list can be sorted by. They are all NOT
+// NULL, which keeps keyset pagination simple. The price is the one in
+// effect today, see sortExpr.
+var SortColumns = []string{"id", "price", "start_date", "service_name"}
+
 //go:generate mockgen -source=subscription.go -destination=mocks/subscription.go
 type SubscriptionStorage interface {
 	Create(ctx context.Context, sub *models.Subscription) (int, error)
 	Get(ctx context.Context, id int) (*models.Subscription, error)
-	Update(ctx context.Context, id int, sub *models.Subscription) error
-	Delete(ctx context.Context, id int) error
-	List(ctx context.Context, userID, serviceName string, limit, offset int) ([]models.Subscription, error)
+	Update(ctx context.Context, id int, sub *models.Subscription) (*models.Subscription, error)
+	Patch(ctx context.Context, id, version int, patch models.SubscriptionPatch) (*models.Subscription, error)
+	Delete(ctx context.Context, id, version int) error
+	Restore(ctx context.Context, id int) (*models.Subscription, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	List(ctx context.Context, filter models.SubscriptionFilter, page models.Page) ([]models.Subscription, bool, error)
+	Export(ctx context.Context, filter models.SubscriptionFilter, fn func(*models.Subscription) error) error
+	Search(ctx context.Context, query string, filter models.SubscriptionFilter, limit, offset int) ([]models.SearchResult, bool, error)
+	ChangePrice(ctx context.Context, id int, change models.PriceChange) error
+	PriceHistory(ctx context.Context, id int) ([]models.PriceChange, error)
+	Pause(ctx context.Context, id int, pause models.Pause) error
+	Resume(ctx context.Context, id int, on time.Time) (*models.Pause, error)
+	Pauses(ctx context.Context, id int) ([]models.Pause, error)
 	TotalForPeriod(
 		ctx context.Context,
 		periodStart, periodEnd time.Time,
 		userID uuid.UUID,
 		serviceName string,
-	) (int64, error)
+		prorate bool,
+	) (map[string]int64, error)
+	TimeSeries(
+		ctx context.Context,
+		periodStart, periodEnd time.Time,
+		userID uuid.UUID,
+		serviceName string,
+		groupByService bool,
+		prorate bool,
+	) ([]models.MonthlyTotal, error)
+	Breakdown(
+		ctx context.Context,
+		periodStart, periodEnd time.Time,
+		userID uuid.UUID,
+		serviceName string,
+		groupBy string,
+		prorate bool,
+	) ([]models.BreakdownItem, error)
+	InTx(ctx context.Context, fn func(SubscriptionStorage) error) error
 }
 
 type PostgresSubscriptionStorage struct {
 	db *sql.DB
+	// scope is the transaction of InTx, nil outside of it.
+	scope *txScope
 }
 
 func NewPostgresSubscriptionStorage(db *sql.DB) SubscriptionStorage {
@@ -42,36 +94,118 @@ func NewPostgresSubscriptionStorage(db *sql.DB) SubscriptionStorage {
 	}
 }
 
+// conn returns the transaction of InTx, or the database outside of it.
+func (s *PostgresSubscriptionStorage) conn() dbtx {
+	if s.scope != nil {
+		return s.scope.tx
+	}
+	return s.db
+}
+
+func (s *PostgresSubscriptionStorage) begin(ctx context.Context) (*tx, error) {
+	return begin(ctx, s.db, s.scope)
+}
+
+// InTx calls fn with a storage whose methods run in one transaction, which
+// is committed if fn returns nil and rolled back otherwise. Nested calls
+// use savepoints, so an inner InTx can fail without failing the outer one.
+func (s *PostgresSubscriptionStorage) InTx(ctx context.Context, fn func(SubscriptionStorage) error) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	scope := s.scope
+	if scope == nil {
+		scope = &txScope{tx: tx.Tx}
+	}
+	if err := fn(&PostgresSubscriptionStorage{db: s.db, scope: scope}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Create inserts the subscription and starts its price history with
+// sub.Price effective from the start month. The subscription is linked to
+// the service catalog as described at models.Subscription.ServiceID;
+// ErrServiceNotFound is returned for an unknown ServiceID, the same as by
+// Update and Patch. A subscription the table constraints reject fails with
+// ErrInvalidSubscription.
 func (s *PostgresSubscriptionStorage) Create(ctx context.Context, sub *models.Subscription) (int, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := resolveService(ctx, tx, &sub.ServiceID, &sub.ServiceName); err != nil {
+		return 0, err
+	}
+
 	var id int
 	// query := `INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date) VALUES ($1, $2, $3, $4, $5) RETURNING id`
 	query, args := sqlbuilder.PostgreSQL.NewInsertBuilder().InsertInto("subscriptions").
-		Cols("service_name", "price", "user_id", "start_date", "end_date").
+		Cols("service_id", "service_name", "price", "currency", "user_id", "start_date", "end_date", "billing_period", "billing_interval_months", "trial_end", "category", "tags", "metadata", "notes").
 		Values(
+			sub.ServiceID,
 			sub.ServiceName,
 			sub.Price,
+			sub.Currency,
 			sub.UserID,
 			sub.StartDate,
 			sub.EndDate,
+			sub.BillingPeriod,
+			sub.BillingIntervalMonths,
+			sub.TrialEnd,
+			sub.Category,
+			tagsArray(sub.Tags),
+			metadataValue(sub.Metadata),
+			sub.Notes,
 		).Returning("id").Build()
 
-	err := s.db.QueryRowContext(ctx, query, args...).Scan(&id)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&id)
 	if err != nil {
+		return 0, createError(err)
+	}
+
+	if err := setPrice(ctx, tx, id, utils.StartOfMonth(sub.StartDate), sub.Price); err != nil {
+		return 0, createError(err)
+	}
+
+	if err := recordEvent(ctx, tx, id, models.EventCreate, nil); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
 		return 0, err
 	}
 
 	return id, nil
 }
 
+// createError maps a check violation or a value out of the range of its
+// column to ErrInvalidSubscription.
+func createError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && (pqErr.Code == "23514" || pqErr.Code == "22003") {
+		return ErrInvalidSubscription
+	}
+	return err
+}
+
+// Get returns the subscription, or ErrNotFound if it doesn't exist or is in
+// the trash.
 func (s *PostgresSubscriptionStorage) Get(ctx context.Context, id int) (*models.Subscription, error) {
-	query, args := sqlbuilder.PostgreSQL.NewSelectBuilder().Select("id", "service_name", "price", "user_id", "start_date", "end_date").
+	cond := sqlbuilder.NewCond()
+	query, args := sqlbuilder.PostgreSQL.NewSelectBuilder().Select(subscriptionColumns...).
 		From("subscriptions").
-		Where(sqlbuilder.NewCond().Equal("id", id)).
+		Where(cond.Equal("id", id), cond.IsNull("deleted_at")).
 		Build()
-	row := s.db.QueryRowContext(ctx, query, args...)
+	row := s.conn().QueryRowContext(ctx, query, args...)
 
 	var sub models.Subscription
-	if err := row.Scan(&sub.ID, &sub.ServiceName, &sub.Price, &sub.UserID, &sub.StartDate, &sub.EndDate); err != nil {
+	if err := scanSubscription(row, &sub); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
 		}
@@ -81,36 +215,278 @@ func (s *PostgresSubscriptionStorage) Get(ctx context.Context, id int) (*models.
 	return &sub, nil
 }
 
-func (s *PostgresSubscriptionStorage) Update(ctx context.Context, id int, sub *models.Subscription) error {
+// Update overwrites the subscription. A new price is added to the price
+// history effective from the start of the current month, or from the start
+// month if the subscription hasn't started yet, so the charges of the
+// current month made before the change are repriced too. Earlier months
+// keep their prices; ChangePrice sets a price from another month.
+//
+// If sub.Version is set, the row is only updated if it still has this
+// version, otherwise ErrStaleVersion is returned. On success the updated
+// subscription is returned as stored, and sub.Version is the new version.
+func (s *PostgresSubscriptionStorage) Update(ctx context.Context, id int, sub *models.Subscription) (*models.Subscription, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var currentPrice int
+	err = tx.QueryRowContext(ctx, "SELECT "+currentPriceSQL+" FROM subscriptions WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).Scan(&currentPrice)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	before, err := snapshot(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := resolveService(ctx, tx, &sub.ServiceID, &sub.ServiceName); err != nil {
+		return nil, err
+	}
+
 	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder().Update("subscriptions")
 	ub.Set(
+		ub.Assign("service_id", sub.ServiceID),
 		ub.Assign("service_name", sub.ServiceName),
 		ub.Assign("price", sub.Price),
+		ub.Assign("currency", sub.Currency),
 		ub.Assign("user_id", sub.UserID),
 		ub.Assign("start_date", sub.StartDate),
 		ub.Assign("end_date", sub.EndDate),
+		ub.Assign("billing_period", sub.BillingPeriod),
+		ub.Assign("billing_interval_months", sub.BillingIntervalMonths),
+		ub.Assign("trial_end", sub.TrialEnd),
+		ub.Assign("category", sub.Category),
+		ub.Assign("tags", tagsArray(sub.Tags)),
+		ub.Assign("metadata", metadataValue(sub.Metadata)),
+		ub.Assign("notes", sub.Notes),
+		ub.Incr("version"),
 	).Where(ub.Equal("id", id))
+	if sub.Version != 0 {
+		ub.Where(ub.Equal("version", sub.Version))
+	}
+	q, args := ub.Returning("version").Build()
+
+	if err := tx.QueryRowContext(ctx, q, args...).Scan(&sub.Version); err != nil {
+		// The row is locked above, so it exists and only the version
+		// can be different.
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStaleVersion
+		}
+		return nil, err
+	}
+
+	if sub.Price != currentPrice {
+		effectiveFrom := utils.StartOfMonth(time.Now())
+		if start := utils.StartOfMonth(sub.StartDate); start.After(effectiveFrom) {
+			effectiveFrom = start
+		}
+		if err := setPrice(ctx, tx, id, effectiveFrom, sub.Price); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := recordEvent(ctx, tx, id, models.EventUpdate, before); err != nil {
+		return nil, err
+	}
+
+	updated, err := selectSubscription(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Patch updates only the columns set in the patch and returns the updated
+// subscription. A new price goes to the price history the same way as in
+// Update. A non-zero version is checked the same way as in Update.
+func (s *PostgresSubscriptionStorage) Patch(ctx context.Context, id, version int, patch models.SubscriptionPatch) (*models.Subscription, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		currentPrice    int
+		startDate       time.Time
+		currentVersion  int
+		currentMetadata json.RawMessage
+	)
+	err = tx.QueryRowContext(ctx, "SELECT "+currentPriceSQL+", start_date, version, metadata FROM subscriptions WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).
+		Scan(&currentPrice, &startDate, &currentVersion, rawJSON{&currentMetadata})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	// The row is locked, so the version can't change until the commit.
+	if version != 0 && version != currentVersion {
+		return nil, ErrStaleVersion
+	}
+	before, err := snapshot(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder().Update("subscriptions")
+	assignments := []string{ub.Incr("version")}
+	switch {
+	case patch.ServiceID != nil && !patch.ServiceID.Valid && patch.ServiceName == nil:
+		// Unlinked from the catalog, the name is kept.
+		assignments = append(assignments, ub.Assign("service_id", nil))
+	case patch.ServiceID != nil || patch.ServiceName != nil:
+		var serviceID sql.NullInt32
+		var serviceName string
+		if patch.ServiceID != nil {
+			serviceID = *patch.ServiceID
+		}
+		if patch.ServiceName != nil {
+			serviceName = *patch.ServiceName
+		}
+		if err := resolveService(ctx, tx, &serviceID, &serviceName); err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, ub.Assign("service_id", serviceID))
+		if patch.ServiceName != nil {
+			assignments = append(assignments, ub.Assign("service_name", serviceName))
+		}
+	}
+	if patch.Price != nil {
+		assignments = append(assignments, ub.Assign("price", *patch.Price))
+	}
+	if patch.Currency != nil {
+		assignments = append(assignments, ub.Assign("currency", *patch.Currency))
+	}
+	if patch.UserID != nil {
+		assignments = append(assignments, ub.Assign("user_id", *patch.UserID))
+	}
+	if patch.StartDate != nil {
+		assignments = append(assignments, ub.Assign("start_date", *patch.StartDate))
+		startDate = *patch.StartDate
+	}
+	if patch.EndDate != nil {
+		assignments = append(assignments, ub.Assign("end_date", *patch.EndDate))
+	}
+	if patch.BillingPeriod != nil {
+		assignments = append(assignments, ub.Assign("billing_period", *patch.BillingPeriod))
+	}
+	if patch.BillingIntervalMonths != nil {
+		assignments = append(assignments, ub.Assign("billing_interval_months", *patch.BillingIntervalMonths))
+	}
+	if patch.TrialEnd != nil {
+		assignments = append(assignments, ub.Assign("trial_end", *patch.TrialEnd))
+	}
+	if patch.Category != nil {
+		assignments = append(assignments, ub.Assign("category", *patch.Category))
+	}
+	if patch.Tags != nil {
+		assignments = append(assignments, ub.Assign("tags", tagsArray(*patch.Tags)))
+	}
+	if patch.Metadata != nil {
+		metadata, err := utils.MergePatch(currentMetadata, patch.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		// Merged into the current metadata, the patch may outgrow the
+		// limits.
+		if string(metadata) != "null" && validators.CheckMetadata(metadata) != nil {
+			return nil, ErrInvalidPatch
+		}
+		assignments = append(assignments, ub.Assign("metadata", metadataValue(metadata)))
+	}
+	if patch.Notes != nil {
+		assignments = append(assignments, ub.Assign("notes", *patch.Notes))
+	}
+
+	ub.Set(assignments...).Where(ub.Equal("id", id))
 	q, args := ub.Build()
+	if _, err := tx.ExecContext(ctx, q, args...); err != nil {
+		// The patch may leave the row inconsistent, e.g. a custom
+		// billing period without an interval or a trial ending before
+		// the new start date.
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23514" {
+			return nil, ErrInvalidPatch
+		}
+		return nil, err
+	}
+
+	if patch.Price != nil && *patch.Price != currentPrice {
+		effectiveFrom := utils.StartOfMonth(time.Now())
+		if start := utils.StartOfMonth(startDate); start.After(effectiveFrom) {
+			effectiveFrom = start
+		}
+		if err := setPrice(ctx, tx, id, effectiveFrom, *patch.Price); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := recordEvent(ctx, tx, id, models.EventUpdate, before); err != nil {
+		return nil, err
+	}
 
-	res, err := s.db.ExecContext(ctx, q, args...)
+	sub, err := selectSubscription(ctx, tx, id)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	n, err := res.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// selectSubscription returns the subscription as changed by the
+// transaction, for Update and Patch to return.
+func selectSubscription(ctx context.Context, tx dbtx, id int) (*models.Subscription, error) {
+	query, args := sqlbuilder.PostgreSQL.NewSelectBuilder().Select(subscriptionColumns...).
+		From("subscriptions").
+		Where(sqlbuilder.NewCond().Equal("id", id)).
+		Build()
+
+	var sub models.Subscription
+	if err := scanSubscription(tx.QueryRowContext(ctx, query, args...), &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// Delete moves the subscription to the trash, where it is hidden from
+// everything but Restore and the Deleted filter until Purge removes it.
+// If version is not zero, the subscription is only deleted if it still has
+// this version, otherwise ErrStaleVersion is returned.
+func (s *PostgresSubscriptionStorage) Delete(ctx context.Context, id, version int) error {
+	tx, err := s.begin(ctx)
 	if err != nil {
 		return err
 	}
-	if n == 0 {
-		return ErrNotFound
+	defer tx.Rollback()
+
+	before, err := snapshot(ctx, tx, id)
+	if err != nil {
+		return err
 	}
 
-	return nil
-}
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+	ub.Update("subscriptions").
+		Set("deleted_at = now()", ub.Incr("version")).
+		Where(ub.Equal("id", id), ub.IsNull("deleted_at"))
+	if version != 0 {
+		ub.Where(ub.Equal("version", version))
+	}
+	query, args := ub.Build()
 
-func (s *PostgresSubscriptionStorage) Delete(ctx context.Context, id int) error {
-	query := `DELETE FROM subscriptions WHERE id = $1`
-	res, err := s.db.ExecContext(ctx, query, id)
+	res, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
 		return err
 	}
@@ -120,110 +496,1122 @@ func (s *PostgresSubscriptionStorage) Delete(ctx context.Context, id int) error
 		return err
 	}
 	if n == 0 {
+		if version == 0 {
+			return ErrNotFound
+		}
+		// Tell a stale version from a missing row.
+		var exists bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM subscriptions WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrStaleVersion
+		}
 		return ErrNotFound
 	}
 
-	return nil
+	if err := recordEvent(ctx, tx, id, models.EventDelete, before); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Restore takes the subscription out of the trash and returns it. It fails
+// with ErrNotFound if the subscription isn't in the trash.
+func (s *PostgresSubscriptionStorage) Restore(ctx context.Context, id int) (*models.Subscription, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshot(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+	ub.Update("subscriptions").
+		Set(ub.Assign("deleted_at", nil), ub.Incr("version")).
+		Where(ub.Equal("id", id), ub.IsNotNull("deleted_at")).
+		Returning(subscriptionColumns...)
+	query, args := ub.Build()
+
+	var sub models.Subscription
+	if err := scanSubscription(tx.QueryRowContext(ctx, query, args...), &sub); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if err := recordEvent(ctx, tx, id, models.EventRestore, before); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// Purge removes the subscriptions deleted before deletedBefore for good,
+// along with their prices and pauses, and returns how many there were.
+func (s *PostgresSubscriptionStorage) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	db := sqlbuilder.PostgreSQL.NewDeleteBuilder()
+	db.DeleteFrom("subscriptions").Where(db.LessThan("deleted_at", deletedBefore))
+	query, args := db.Build()
+
+	res, err := s.conn().ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
 }
 
-func (s *PostgresSubscriptionStorage) List(ctx context.Context, userID, serviceName string, limit, offset int) ([]models.Subscription, error) {
+// List returns a page of the subscriptions matching the filter and whether
+// more of them follow the page. ErrInvalidSort is returned for a column
+// not in SortColumns.
+func (s *PostgresSubscriptionStorage) List(ctx context.Context, filter models.SubscriptionFilter, page models.Page) ([]models.Subscription, bool, error) {
+	limit := page.Limit
 	if limit > MaxLimit {
 		limit = MaxLimit
 	}
 
-	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
-	sb.Select("id", "service_name", "price", "user_id", "start_date", "end_date").From("subscriptions")
-
-	var conds []string
-	if userID != "" {
-		conds = append(conds, sb.Equal("user_id", userID))
+	// The id breaks the ties, so that the order is total.
+	keys := page.Sort
+	if !slices.ContainsFunc(keys, func(key models.SortKey) bool { return key.Column == "id" }) {
+		keys = append(slices.Clone(keys), models.SortKey{Column: "id", Desc: true})
 	}
-	if serviceName != "" {
-		conds = append(conds, sb.Equal("service_name", serviceName))
+	for _, key := range keys {
+		if !slices.Contains(SortColumns, key.Column) {
+			return nil, false, ErrInvalidSort
+		}
+	}
+
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	sb.Select(subscriptionColumns...).From("subscriptions")
+	conds := listConds(sb, filter)
+	// Unlike an offset, a cursor doesn't skip or repeat rows when
+	// subscriptions are added or deleted between pages.
+	if page.After != nil {
+		conds = append(conds, keysetCond(sb, keys, page.After))
 	}
 	if len(conds) > 0 {
 		sb.Where(sb.And(conds...))
 	}
 
-	sb.OrderBy("id").Desc()
+	var orderBy []string
+	for _, key := range keys {
+		if key.Desc {
+			orderBy = append(orderBy, sortExpr(key.Column)+" DESC")
+		} else {
+			orderBy = append(orderBy, sortExpr(key.Column)+" ASC")
+		}
+	}
+	sb.OrderBy(orderBy...)
+	// One more row tells whether there is a next page.
 	if limit > 0 {
-		sb.Limit(limit)
+		sb.Limit(limit + 1)
 	}
-	if offset > 0 {
-		sb.Offset(offset)
+	if page.Offset > 0 {
+		sb.Offset(page.Offset)
 	}
 
 	q, args := sb.Build()
 
 	var out []models.Subscription
-	rows, err := s.db.QueryContext(ctx, q, args...)
+	rows, err := s.conn().QueryContext(ctx, q, args...)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var sub models.Subscription
-		if err := rows.Scan(&sub.ID, &sub.ServiceName, &sub.Price, &sub.UserID, &sub.StartDate, &sub.EndDate); err != nil {
-			return nil, err
+		if err := scanSubscription(rows, &sub); err != nil {
+			return nil, false, err
 		}
 		out = append(out, sub)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	return out, nil
-}
-
-func (s *PostgresSubscriptionStorage) TotalForPeriod(
-	ctx context.Context,
-	periodStart, periodEnd time.Time,
-	userID uuid.UUID, // Изменить тип
-	serviceName string,
-) (int64, error) {
-	peEnd := periodEnd.AddDate(0, 1, 0).Add(-time.Nanosecond)
-
-	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
-	sb.Select("start_date", "end_date", "price").
-		From("subscriptions").
-		Where(sb.LessEqualThan("start_date", peEnd)).
-		Where(sb.Or(sb.IsNull("end_date"), sb.GreaterEqualThan("end_date", periodStart))).
-		Desc()
 
-	if userID != uuid.Nil {
-		sb.Where(sb.Equal("user_id", userID.String()))
+	if limit > 0 && len(out) > limit {
+		return out[:limit], true, nil
 	}
+	return out, false, nil
+}
 
-	if serviceName != "" {
-		sb.Where(sb.Equal("service_name", serviceName))
+// Export calls fn for every subscription matching the filter, in id
+// order. There is no limit: the rows are read one at a time, so the
+// result is never held in memory. An error returned by fn stops the export
+// and is returned as is.
+func (s *PostgresSubscriptionStorage) Export(ctx context.Context, filter models.SubscriptionFilter, fn func(*models.Subscription) error) error {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	sb.Select(subscriptionColumns...).From("subscriptions")
+	if conds := listConds(sb, filter); len(conds) > 0 {
+		sb.Where(sb.And(conds...))
 	}
+	sb.OrderBy("id")
 
-	sqlStr, args := sb.Build()
+	q, args := sb.Build()
 
-	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	rows, err := s.conn().QueryContext(ctx, q, args...)
 	if err != nil {
-		return 0, err
+		return err
 	}
 	defer rows.Close()
 
-	var total int64
 	for rows.Next() {
 		var sub models.Subscription
-		if err := rows.Scan(&sub.ID, &sub.ServiceName, &sub.Price, &sub.UserID, &sub.StartDate, &sub.EndDate); err != nil {
-			return 0, err
+		if err := scanSubscription(rows, &sub); err != nil {
+			return err
 		}
-
-		subEnd := time.Date(MaxFutureDate, 1, 1, 0, 0, 0, 0, time.UTC)
-		if sub.EndDate.Valid {
-			subEnd = sub.EndDate.Time
+		if err := fn(&sub); err != nil {
+			return err
 		}
+	}
+	return rows.Err()
+}
+
+// Search returns the subscriptions matching the filter whose service name
+// is similar to query, best matches first, and whether more of them follow.
+// A name matches if its trigrams are similar to the query's, as a whole or
+// to a part of it, or if it matches the query as Russian or English text.
+// Cyrillic letters are compared as Latin ones, see the search_name SQL
+// function.
+func (s *PostgresSubscriptionStorage) Search(
+	ctx context.Context,
+	query string,
+	filter models.SubscriptionFilter,
+	limit, offset int,
+) ([]models.SearchResult, bool, error) {
+	if limit <= 0 || limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	name := "search_name(service_name)"
+	q := "search_name(" + sb.Var(query) + ")"
+	tsq := fmt.Sprintf("(websearch_to_tsquery('russian', %[1]s) || websearch_to_tsquery('english', %[1]s))", sb.Var(query))
+	rank := fmt.Sprintf("GREATEST(similarity(%[1]s, %[2]s), word_similarity(%[2]s, %[1]s), ts_rank(service_name_tsv, %[3]s))", name, q, tsq)
+
+	sb.Select(append(slices.Clone(subscriptionColumns), rank+" AS rank")...).From("subscriptions")
+	conds := listConds(sb, filter)
+	conds = append(conds, sb.Or(
+		name+" % "+q,
+		q+" <% "+name,
+		"service_name_tsv @@ "+tsq,
+	))
+	sb.Where(sb.And(conds...))
+	sb.OrderBy("rank DESC", "id DESC")
+	// One more row tells whether there is a next page.
+	sb.Limit(limit + 1)
+	if offset > 0 {
+		sb.Offset(offset)
+	}
+
+	sqlQuery, args := sb.Build()
+
+	rows, err := s.conn().QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
 
-		months := utils.MonthsOverlap(sub.StartDate, subEnd, periodStart, periodEnd)
-		total += int64(months) * int64(sub.Price)
+	var out []models.SearchResult
+	for rows.Next() {
+		var result models.SearchResult
+		if err := scanSubscription(extraScanner{rows, []any{&result.Rank}}, &result.Subscription); err != nil {
+			return nil, false, err
+		}
+		out = append(out, result)
 	}
 	if err := rows.Err(); err != nil {
-		return 0, err
+		return nil, false, err
+	}
+
+	if len(out) > limit {
+		return out[:limit], true, nil
 	}
+	return out, false, nil
+}
 
-	return total, nil
+// listConds returns the conditions of filter. Unless filter.Deleted is
+// set, the subscriptions in the trash are left out.
+func listConds(sb *sqlbuilder.SelectBuilder, filter models.SubscriptionFilter) []string {
+	conds := []string{sb.IsNull("deleted_at")}
+	if filter.Deleted {
+		conds = []string{sb.IsNotNull("deleted_at")}
+	}
+	if filter.UserID != "" {
+		conds = append(conds, sb.Equal("user_id", filter.UserID))
+	}
+	if filter.ServiceID != 0 {
+		conds = append(conds, sb.Equal("service_id", filter.ServiceID))
+	}
+	if filter.ServiceName != "" {
+		conds = append(conds, sb.Equal("service_name", filter.ServiceName))
+	}
+	if filter.ServiceNamePrefix != "" {
+		// Matches the index on lower(service_name).
+		conds = append(conds, sb.Like("lower(service_name)", escapeLike(strings.ToLower(filter.ServiceNamePrefix))+"%"))
+	}
+	if filter.Category != "" {
+		conds = append(conds, sb.Equal("category", filter.Category))
+	}
+	if len(filter.Tags) > 0 {
+		// Matches the GIN index on tags.
+		conds = append(conds, "tags @> "+sb.Var(pq.StringArray(filter.Tags)))
+	}
+	// Both match the GIN index on metadata.
+	if len(filter.Metadata) > 0 {
+		members, _ := json.Marshal(filter.Metadata)
+		conds = append(conds, "metadata @> "+sb.Var(string(members))+"::jsonb")
+	}
+	if len(filter.MetadataKeys) > 0 {
+		conds = append(conds, "metadata ?& "+sb.Var(pq.StringArray(filter.MetadataKeys)))
+	}
+	if filter.PriceMin != nil {
+		conds = append(conds, sb.GreaterEqualThan(currentPriceSQL, *filter.PriceMin))
+	}
+	if filter.PriceMax != nil {
+		conds = append(conds, sb.LessEqualThan(currentPriceSQL, *filter.PriceMax))
+	}
+	if !filter.ActiveFrom.IsZero() {
+		conds = append(conds,
+			sb.LessEqualThan("start_date", filter.ActiveTo),
+			sb.Or(sb.IsNull("end_date"), sb.GreaterEqualThan("end_date", filter.ActiveFrom)),
+		)
+	}
+	if !filter.StartedAfter.IsZero() {
+		conds = append(conds, sb.GreaterThan("start_date", filter.StartedAfter))
+	}
+	if !filter.EndedBefore.IsZero() {
+		conds = append(conds, sb.LessThan("end_date", filter.EndedBefore))
+	}
+	switch filter.Status {
+	case models.StatusActive:
+		conds = append(conds, "start_date <= CURRENT_DATE", "(end_date IS NULL OR end_date >= CURRENT_DATE)")
+	case models.StatusEnded:
+		conds = append(conds, "end_date < CURRENT_DATE")
+	}
+	return conds
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// keysetCond selects the rows that come after the row after in the order
+// of keys: the rows greater on the first key, or equal on it and greater
+// on the second key, and so on, "greater" meaning lower for DESC keys.
+func keysetCond(sb *sqlbuilder.SelectBuilder, keys []models.SortKey, after *models.Subscription) string {
+	var ors, equal []string
+	for _, key := range keys {
+		expr, value := sortExpr(key.Column), sortValue(after, key.Column)
+		cmp := sb.GreaterThan(expr, value)
+		if key.Desc {
+			cmp = sb.LessThan(expr, value)
+		}
+		ors = append(ors, sb.And(append(slices.Clone(equal), cmp)...))
+		equal = append(equal, sb.Equal(expr, value))
+	}
+	return sb.Or(ors...)
+}
+
+// sortExpr returns the expression a list is sorted by for one of
+// SortColumns. The price is the current one, the same as in the list and
+// its cursors, rather than the price column, which ChangePrice doesn't
+// update.
+func sortExpr(column string) string {
+	if column == "price" {
+		return currentPriceSQL
+	}
+	return column
+}
+
+// sortValue returns the value of one of SortColumns.
+func sortValue(sub *models.Subscription, column string) any {
+	switch column {
+	case "price":
+		return sub.Price
+	case "start_date":
+		return sub.StartDate
+	case "service_name":
+		return sub.ServiceName
+	default:
+		return sub.ID
+	}
+}
+
+// ChangePrice adds a price to the history of a subscription. A change for
+// a month that already has one replaces it.
+func (s *PostgresSubscriptionStorage) ChangePrice(ctx context.Context, id int, change models.PriceChange) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshot(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if err := bumpVersion(ctx, tx, id); err != nil {
+		return err
+	}
+
+	if err := setPrice(ctx, tx, id, utils.StartOfMonth(change.EffectiveFrom), change.Price); err != nil {
+		return err
+	}
+
+	if err := recordEvent(ctx, tx, id, models.EventUpdate, before); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PriceHistory returns every price of a subscription, oldest first.
+func (s *PostgresSubscriptionStorage) PriceHistory(ctx context.Context, id int) ([]models.PriceChange, error) {
+	var exists bool
+	err := s.conn().QueryRowContext(ctx, `SELECT true FROM subscriptions WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&exists)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := s.conn().QueryContext(ctx,
+		`SELECT price, effective_from FROM subscription_prices WHERE subscription_id = $1 ORDER BY effective_from`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.PriceChange
+	for rows.Next() {
+		var change models.PriceChange
+		if err := rows.Scan(&change.Price, &change.EffectiveFrom); err != nil {
+			return nil, err
+		}
+		out = append(out, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Pause freezes the subscription for the days of pause. It fails with
+// ErrPauseOverlaps if any of them is already paused.
+func (s *PostgresSubscriptionStorage) Pause(ctx context.Context, id int, pause models.Pause) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the subscription serializes pauses, so two overlapping ones
+	// can't both pass the check below.
+	before, err := snapshot(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if err := bumpVersion(ctx, tx, id); err != nil {
+		return err
+	}
+
+	var overlaps bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS (
+			SELECT 1 FROM subscription_pauses
+			WHERE subscription_id = $1
+				AND (paused_until IS NULL OR paused_until >= $2)
+				AND ($3::date IS NULL OR paused_from <= $3)
+		)`,
+		id, pause.From, pause.Until,
+	).Scan(&overlaps)
+	if err != nil {
+		return err
+	}
+	if overlaps {
+		return ErrPauseOverlaps
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO subscription_pauses (subscription_id, paused_from, paused_until) VALUES ($1, $2, $3)`,
+		id, pause.From, pause.Until,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := recordEvent(ctx, tx, id, models.EventUpdate, before); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Resume ends the pause the day on falls into, so that on is charged
+// again, and returns the shortened pause. A pause resumed on its first day
+// is dropped altogether and nil is returned. It fails with ErrNotPaused if
+// on isn't paused.
+func (s *PostgresSubscriptionStorage) Resume(ctx context.Context, id int, on time.Time) (*models.Pause, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshot(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := bumpVersion(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	pause := models.Pause{}
+	err = tx.QueryRowContext(ctx,
+		`SELECT paused_from FROM subscription_pauses
+		WHERE subscription_id = $1 AND paused_from <= $2 AND (paused_until IS NULL OR paused_until >= $2)`,
+		id, on,
+	).Scan(&pause.From)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotPaused
+		}
+		return nil, err
+	}
+
+	if !pause.From.Before(on) {
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM subscription_pauses WHERE subscription_id = $1 AND paused_from = $2`,
+			id, pause.From,
+		)
+	} else {
+		pause.Until = sql.NullTime{Time: on.AddDate(0, 0, -1), Valid: true}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE subscription_pauses SET paused_until = $3 WHERE subscription_id = $1 AND paused_from = $2`,
+			id, pause.From, pause.Until,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordEvent(ctx, tx, id, models.EventUpdate, before); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if !pause.Until.Valid {
+		return nil, nil
+	}
+	return &pause, nil
+}
+
+// Pauses returns every pause of a subscription, oldest first.
+func (s *PostgresSubscriptionStorage) Pauses(ctx context.Context, id int) ([]models.Pause, error) {
+	var exists bool
+	err := s.conn().QueryRowContext(ctx, `SELECT true FROM subscriptions WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&exists)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := s.conn().QueryContext(ctx,
+		`SELECT paused_from, paused_until FROM subscription_pauses WHERE subscription_id = $1 ORDER BY paused_from`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Pause
+	for rows.Next() {
+		var pause models.Pause
+		if err := rows.Scan(&pause.From, &pause.Until); err != nil {
+			return nil, err
+		}
+		out = append(out, pause)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// snapshotSQL selects the subscription $1 as recorded by the audit trail: a
+// JSON object with its columns, its current price, price history and
+// pauses. The row is locked, so that it doesn't change before the event is
+// recorded.
+const snapshotSQL = `SELECT (to_jsonb(subscriptions) - 'service_name_tsv') || jsonb_build_object(
+	'current_price', ` + currentPriceSQL + `,
+	'prices', COALESCE((
+		SELECT jsonb_agg(jsonb_build_object('price', p.price, 'effective_from', p.effective_from) ORDER BY p.effective_from)
+		FROM subscription_prices p WHERE p.subscription_id = subscriptions.id
+	), '[]'),
+	'pauses', COALESCE((
+		SELECT jsonb_agg(jsonb_build_object('from', p.paused_from, 'until', p.paused_until) ORDER BY p.paused_from)
+		FROM subscription_pauses p WHERE p.subscription_id = subscriptions.id
+	), '[]')
+) FROM subscriptions WHERE id = $1 FOR UPDATE`
+
+// snapshot returns the subscription as recorded by the audit trail, or nil
+// if it doesn't exist.
+func snapshot(ctx context.Context, tx dbtx, id int) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := tx.QueryRowContext(ctx, snapshotSQL, id).Scan(rawJSON{&out})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return out, err
+}
+
+// recordEvent adds a change of the subscription to the audit trail: from
+// before, taken with snapshot, to its current state. The actor and the
+// request id are taken from ctx. The domain events of the change are put
+// into the outbox, in the same transaction as the change.
+func recordEvent(ctx context.Context, tx dbtx, id int, action string, before json.RawMessage) error {
+	after, err := snapshot(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	actor, requestID := nullString(audit.Actor(ctx)), nullString(audit.RequestID(ctx))
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO subscription_events (subscription_id, action, before, after, actor, request_id) VALUES ($1, $2, $3, $4, $5, $6)`,
+		id, action, jsonText(before), jsonText(after), actor, requestID,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, typ := range domainEvents(action, before, after) {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO outbox_events (type, subscription_id, subscription, previous, actor, request_id) VALUES ($1, $2, $3, $4, $5, $6)`,
+			typ, id, jsonText(after), jsonText(before), actor, requestID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// domainEvents returns the types of the outbox events of a change recorded
+// by the audit trail with the action.
+func domainEvents(action string, before, after json.RawMessage) []string {
+	switch action {
+	case models.EventCreate:
+		return []string{models.SubscriptionCreated}
+	case models.EventDelete:
+		return []string{models.SubscriptionDeleted}
+	}
+
+	events := []string{models.SubscriptionUpdated}
+	var b, a struct {
+		EndDate *string `json:"end_date"`
+	}
+	_ = json.Unmarshal(before, &b)
+	_ = json.Unmarshal(after, &a)
+	today := time.Now().Format(time.DateOnly)
+	if endedBy(a.EndDate, today) && !endedBy(b.EndDate, today) {
+		events = append(events, models.SubscriptionEnded)
+	}
+	return events
+}
+
+// endedBy tells whether a subscription with the end date of a snapshot has
+// ended by the day, both formatted as time.DateOnly.
+func endedBy(endDate *string, day string) bool {
+	return endDate != nil && len(*endDate) >= len(day) && (*endDate)[:len(day)] <= day
+}
+
+// bumpVersion locks the subscription and increments its version. It is used
+// by changes that don't update the subscription row itself, like a price
+// change or a pause, but still change how the subscription looks.
+func bumpVersion(ctx context.Context, tx dbtx, id int) error {
+	res, err := tx.ExecContext(ctx, `UPDATE subscriptions SET version = version + 1 WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// setPrice records price as effective from the given month.
+func setPrice(ctx context.Context, tx dbtx, id int, effectiveFrom time.Time, price int) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO subscription_prices (subscription_id, effective_from, price) VALUES ($1, $2, $3)
+		ON CONFLICT (subscription_id, effective_from) DO UPDATE SET price = EXCLUDED.price`,
+		id, effectiveFrom, price,
+	)
+	return err
+}
+
+// TotalForPeriod returns the cost of all matching subscriptions over
+// [periodStart, periodEnd], both days inclusive, keyed by currency.
+// Every charge is made at the price in effect on its billing date, and
+// nothing is charged during a trial or a pause. If
+// prorate is set, the last charge of a subscription that ends before its
+// billing cycle does is reduced to the share of the cycle's days it was
+// active. The charges and the sums are computed by Postgres in a single
+// aggregate query.
+func (s *PostgresSubscriptionStorage) TotalForPeriod(
+	ctx context.Context,
+	periodStart, periodEnd time.Time,
+	userID uuid.UUID,
+	serviceName string,
+	prorate bool,
+) (map[string]int64, error) {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	from, to := periodBoundsSQL(sb, periodStart, periodEnd)
+	amount := fmt.Sprintf("%s * %s", priceSQL, chargesSQL(from, to))
+	if prorate {
+		amount += " + " + prorationSQL(from, to)
+	}
+
+	sb.Select("currency", fmt.Sprintf("SUM(%s)::bigint", amount)).
+		From(priceSegmentsJoin).
+		Where(overlapConds(sb, periodStart, periodEnd, userID, serviceName)...).
+		GroupBy("currency")
+
+	sqlStr, args := sb.Build()
+
+	rows, err := s.conn().QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := map[string]int64{}
+	for rows.Next() {
+		var code string
+		var total int64
+		if err := rows.Scan(&code, &total); err != nil {
+			return nil, err
+		}
+		totals[code] = total
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+// TimeSeries returns the spend for every calendar month in
+// [periodStart, periodEnd] per currency, ordered by month. Both days are
+// inclusive; the first and the last month only count the days in the period. Months without
+// any spend are still present as a single row with a zero total and no
+// currency. If groupByService is set, every month is also split into one
+// row per service that was charged in it. prorate is the same as in
+// TotalForPeriod, the reduced charge counts in the month of its billing
+// date.
+func (s *PostgresSubscriptionStorage) TimeSeries(
+	ctx context.Context,
+	periodStart, periodEnd time.Time,
+	userID uuid.UUID,
+	serviceName string,
+	groupByService bool,
+	prorate bool,
+) ([]models.MonthlyTotal, error) {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	from, to := periodBoundsSQL(sb, periodStart, periodEnd)
+	monthFrom, monthTo := "m.month::date, "+from, "(m.month + interval '1 month')::date, "+to
+	charges := chargesSQL(monthFrom, monthTo)
+	amount := fmt.Sprintf("%s * %s", priceSQL, charges)
+	if prorate {
+		amount += " + " + prorationSQL(monthFrom, monthTo)
+	}
+
+	cols := []string{"m.month", "s.currency", fmt.Sprintf("COALESCE(SUM(%s), 0)::bigint", amount)}
+	if groupByService {
+		cols = append(cols, "s.service_name")
+	}
+	sb.Select(cols...).
+		From(fmt.Sprintf(
+			"generate_series(%s::date, %s::date, interval '1 month') AS m(month)",
+			sb.Var(utils.StartOfMonth(periodStart)), sb.Var(periodEnd),
+		))
+
+	// Filters go into the join condition so that months without
+	// matching subscriptions are kept by the left join.
+	on := []string{
+		"s.start_date < m.month + interval '1 month'",
+		"(s.end_date IS NULL OR s.end_date >= m.month)",
+		"(s.end_date IS NULL OR s.end_date >= s.start_date)",
+		charges + " > 0",
+		"s.deleted_at IS NULL",
+	}
+	if userID != uuid.Nil {
+		on = append(on, sb.Equal("s.user_id", userID.String()))
+	}
+	if serviceName != "" {
+		on = append(on, sb.Equal("s.service_name", serviceName))
+	}
+	sb.JoinWithOption(sqlbuilder.LeftJoin, "("+priceSegmentsJoin+")", on...)
+
+	if groupByService {
+		sb.GroupBy("m.month", "s.currency", "s.service_name")
+		sb.OrderBy("m.month", "s.service_name", "s.currency")
+	} else {
+		sb.GroupBy("m.month", "s.currency")
+		sb.OrderBy("m.month", "s.currency")
+	}
+
+	q, args := sb.Build()
+
+	rows, err := s.conn().QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.MonthlyTotal
+	for rows.Next() {
+		var item models.MonthlyTotal
+		var code sql.NullString
+		if groupByService {
+			var name sql.NullString
+			if err := rows.Scan(&item.Month, &code, &item.Total, &name); err != nil {
+				return nil, err
+			}
+			item.ServiceName = name.String
+		} else {
+			if err := rows.Scan(&item.Month, &code, &item.Total); err != nil {
+				return nil, err
+			}
+		}
+		item.Currency = code.String
+		item.Month = utils.StartOfMonth(item.Month)
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Breakdown returns the cost of matching subscriptions over
+// [periodStart, periodEnd], both days inclusive, grouped by one of the
+// GroupBy* columns and by
+// currency. Rows are ordered by group; ranking groups by cost is up to the
+// caller, since it needs the amounts in one currency. prorate is the same
+// as in TotalForPeriod.
+func (s *PostgresSubscriptionStorage) Breakdown(
+	ctx context.Context,
+	periodStart, periodEnd time.Time,
+	userID uuid.UUID,
+	serviceName string,
+	groupBy string,
+	prorate bool,
+) ([]models.BreakdownItem, error) {
+	if !slices.Contains(BreakdownGroups, groupBy) {
+		return nil, ErrInvalidGroupBy
+	}
+
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	from, to := periodBoundsSQL(sb, periodStart, periodEnd)
+	amount := fmt.Sprintf("%s * %s", priceSQL, chargesSQL(from, to))
+	if prorate {
+		amount += " + " + prorationSQL(from, to)
+	}
+	sb.Select(
+		// Subscriptions without a category make a group with an empty
+		// key.
+		"COALESCE("+groupBy+"::text, '')",
+		"currency",
+		"COUNT(DISTINCT s.id)",
+		fmt.Sprintf("COALESCE(SUM(%s), 0)::bigint", amount),
+	).
+		From(priceSegmentsJoin).
+		Where(overlapConds(sb, periodStart, periodEnd, userID, serviceName)...).
+		GroupBy(groupBy, "currency").
+		OrderBy(groupBy, "currency")
+
+	q, args := sb.Build()
+
+	rows, err := s.conn().QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.BreakdownItem
+	for rows.Next() {
+		var item models.BreakdownItem
+		if err := rows.Scan(&item.Key, &item.Currency, &item.Count, &item.Total); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// overlapConds returns the WHERE conditions selecting subscriptions that are
+// charged at least once in [periodStart, periodEnd], narrowed down by the
+// optional user and service filters. The subscriptions in the trash are
+// left out.
+func overlapConds(
+	sb *sqlbuilder.SelectBuilder,
+	periodStart, periodEnd time.Time,
+	userID uuid.UUID,
+	serviceName string,
+) []string {
+	conds := []string{
+		sb.LessEqualThan("start_date", periodEnd),
+		sb.Or(sb.IsNull("end_date"), sb.GreaterEqualThan("end_date", periodStart)),
+		sb.Or(sb.IsNull("end_date"), "end_date >= start_date"),
+		sb.IsNull("deleted_at"),
+	}
+	if userID != uuid.Nil {
+		conds = append(conds, sb.Equal("user_id", userID.String()))
+	}
+	if serviceName != "" {
+		conds = append(conds, sb.Equal("service_name", serviceName))
+	}
+	return conds
+}
+
+// priceSegmentsJoin joins every subscription s with the segments sp of its
+// price history. A subscription without any history gets a single segment
+// with NULL bounds, so priceSQL falls back to its current price.
+const priceSegmentsJoin = "subscriptions s LEFT JOIN subscription_price_segments sp ON sp.subscription_id = s.id"
+
+// priceSQL is the price of a row of priceSegmentsJoin.
+const priceSQL = "COALESCE(sp.price, s.price)::bigint"
+
+// periodBoundsSQL returns the bounds of chargesSQL for the rows of
+// priceSegmentsJoin over the days from periodStart to periodEnd.
+func periodBoundsSQL(sb *sqlbuilder.SelectBuilder, periodStart, periodEnd time.Time) (from, to string) {
+	from = fmt.Sprintf("%s::date, sp.valid_from", sb.Var(periodStart))
+	to = fmt.Sprintf("(%s::date + 1), sp.valid_to", sb.Var(periodEnd))
+	return from, to
+}
+
+// chargesSQL returns an SQL expression with the number of billing dates of
+// a subscription row that fall into its active range and into every one of
+// the given bounds, less those that fall into its pauses. from and to are
+// comma-separated lists of SQL date expressions, inclusive and exclusive
+// respectively; NULL bounds are ignored. It mirrors utils.ChargesBetween;
+// the billing dates are counted by the billing_dates_before function
+// rather than generated one by one.
+func chargesSQL(from, to string) string {
+	lo, hi := activeRangeSQL(from, to)
+	countSQL := func(lo, hi string) string {
+		return fmt.Sprintf("GREATEST(0, %s - %s)", billingDatesBeforeSQL(hi), billingDatesBeforeSQL(lo))
+	}
+
+	// Pauses don't overlap, so the billing dates in them can be
+	// counted one pause at a time.
+	paused := fmt.Sprintf(
+		"COALESCE((SELECT SUM(%s) FROM subscription_pauses p WHERE p.subscription_id = s.id), 0)",
+		countSQL(
+			fmt.Sprintf("GREATEST(%s, p.paused_from)", lo),
+			fmt.Sprintf("LEAST(%s, p.paused_until + 1)", hi),
+		),
+	)
+	return fmt.Sprintf("(%s - %s)", countSQL(lo, hi), paused)
+}
+
+// prorationSQL returns an SQL expression with the amount to add to the
+// charges of a subscription row over the given bounds (see chargesSQL) to
+// prorate them. It is negative if the last billing date of an ended
+// subscription is in the bounds, isn't paused and its cycle is cut short
+// by the end date: that charge is reduced to the share of the cycle's days
+// up to the end date, rounded to the nearest unit.
+func prorationSQL(from, to string) string {
+	lo, hi := activeRangeSQL(from, to)
+
+	charges := billingDatesBeforeSQL("end_date + 1")
+	last := billingDateSQL(charges + " - 1")
+	next := billingDateSQL(charges)
+
+	return fmt.Sprintf(
+		`CASE WHEN end_date IS NOT NULL AND %[1]s >= %[3]s AND %[1]s < %[4]s AND end_date + 1 < %[2]s
+			AND NOT EXISTS (%[6]s)
+		THEN ROUND(%[5]s * (end_date + 1 - %[1]s)::numeric / (%[2]s - %[1]s))::bigint - %[5]s
+		ELSE 0 END`,
+		last, next, lo, hi, priceSQL, pausedOnSQL("s.id", last),
+	)
+}
+
+// pausedOnSQL returns an SQL query selecting the pause of the
+// subscription with the given id the day d falls into.
+func pausedOnSQL(id, d string) string {
+	return fmt.Sprintf(
+		"SELECT 1 FROM subscription_pauses p WHERE p.subscription_id = %[1]s AND p.paused_from <= %[2]s AND (p.paused_until IS NULL OR p.paused_until >= %[2]s)",
+		id, d,
+	)
+}
+
+// activeRangeSQL returns the SQL expressions of [lo, hi), the part of the
+// bounds (see chargesSQL) a subscription row is billed in.
+func activeRangeSQL(from, to string) (lo, hi string) {
+	lo = fmt.Sprintf("GREATEST(%s, %s)", billingStartSQL, from)
+	hi = fmt.Sprintf("LEAST(end_date + 1, %s)", to)
+	return lo, hi
+}
+
+// billingStartSQL is the first billing date of a subscription row, see
+// models.Subscription.BillingStart. Billing dates during a trial are
+// skipped rather than charged.
+const billingStartSQL = "COALESCE(trial_end + 1, start_date)"
+
+// billingDatesBeforeSQL returns the number of billing dates of a
+// subscription row before the day d.
+func billingDatesBeforeSQL(d string) string {
+	return fmt.Sprintf("billing_dates_before(%s, billing_period, billing_interval_months, %s)", billingStartSQL, d)
+}
+
+// billingDateSQL returns the k-th billing date of a subscription row.
+func billingDateSQL(k string) string {
+	return fmt.Sprintf("billing_date(%s, billing_period, billing_interval_months, %s)", billingStartSQL, k)
+}
+
+// currentPriceSQL selects the price in effect today. A subscription that
+// hasn't started yet has no such price and gets its base price instead.
+const currentPriceSQL = `COALESCE((
+	SELECT p.price FROM subscription_prices p
+	WHERE p.subscription_id = subscriptions.id AND p.effective_from <= CURRENT_DATE
+	ORDER BY p.effective_from DESC LIMIT 1
+), subscriptions.price)`
+
+var subscriptionColumns = []string{
+	"id",
+	"service_id",
+	"service_name",
+	currentPriceSQL,
+	"currency",
+	"user_id",
+	"start_date",
+	"end_date",
+	"billing_period",
+	"billing_interval_months",
+	"trial_end",
+	"category",
+	"tags",
+	"metadata",
+	"notes",
+	"EXISTS (" + pausedOnSQL("subscriptions.id", "CURRENT_DATE") + ")",
+	"deleted_at",
+	"version",
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// extraScanner scans the columns selected after subscriptionColumns into
+// extra.
+type extraScanner struct {
+	rowScanner
+	extra []any
+}
+
+func (e extraScanner) Scan(dest ...any) error {
+	return e.rowScanner.Scan(append(dest, e.extra...)...)
+}
+
+// scanSubscription scans a row selected with subscriptionColumns.
+func scanSubscription(row rowScanner, sub *models.Subscription) error {
+	return row.Scan(
+		&sub.ID,
+		&sub.ServiceID,
+		&sub.ServiceName,
+		&sub.Price,
+		&sub.Currency,
+		&sub.UserID,
+		&sub.StartDate,
+		&sub.EndDate,
+		&sub.BillingPeriod,
+		&sub.BillingIntervalMonths,
+		&sub.TrialEnd,
+		&sub.Category,
+		pq.Array(&sub.Tags),
+		rawJSON{&sub.Metadata},
+		&sub.Notes,
+		&sub.Paused,
+		&sub.DeletedAt,
+		&sub.Version,
+	)
+}
+
+// tagsArray returns tags as a value of the tags column, which is never
+// NULL.
+func tagsArray(tags []string) pq.StringArray {
+	if tags == nil {
+		return pq.StringArray{}
+	}
+	return pq.StringArray(tags)
+}
+
+// metadataValue returns metadata as a value of the metadata column, which
+// is never NULL. It is passed as text, pq would send bytes as bytea.
+func metadataValue(metadata json.RawMessage) string {
+	if metadata == nil || string(metadata) == "null" {
+		return "{}"
+	}
+	return string(metadata)
+}
+
+// jsonText returns a nullable JSON column value as text, see
+// metadataValue.
+func jsonText(raw json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(raw), Valid: raw != nil}
+}
+
+// nullString returns s as a nullable column value, NULL if it is empty.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// rawJSON scans a JSON column into a copy of its bytes.
+type rawJSON struct {
+	dst *json.RawMessage
+}
+
+func (r rawJSON) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*r.dst = nil
+	case []byte:
+		*r.dst = bytes.Clone(v)
+	case string:
+		*r.dst = json.RawMessage(v)
+	default:
+		return fmt.Errorf("unsupported JSON column type %T", src)
+	}
+	return nil
 }
@@ -0,0 +1,1425 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"maps"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+	"testovoe/internal/models"
+	"testovoe/internal/storage"
+	"testovoe/internal/utils"
+	"testovoe/internal/validators"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func randomMonth(rng *rand.Rand) time.Time {
+	return time.Date(2020+rng.Intn(6), time.Month(1+rng.Intn(12)), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// randomDay returns the first day of a random month half of the time and
+// a random day of it otherwise.
+func randomDay(rng *rand.Rand) time.Time {
+	month := randomMonth(rng)
+	if rng.Intn(2) == 0 {
+		return month
+	}
+	return month.AddDate(0, 0, rng.Intn(utils.EndOfMonth(month).Day()))
+}
+
+// randomPeriodEnd returns the last day of a month up to n months after
+// start half of the time and a random day up to n months after it
+// otherwise.
+func randomPeriodEnd(rng *rand.Rand, start time.Time, n int) time.Time {
+	if rng.Intn(2) == 0 {
+		return utils.EndOfMonth(start.AddDate(0, rng.Intn(n), 0))
+	}
+	return start.AddDate(0, 0, rng.Intn(n*30))
+}
+
+// seededSubscription is a subscription together with its price history
+// and its pauses, oldest first.
+type seededSubscription struct {
+	models.Subscription
+	Prices []models.PriceChange
+	Pauses []models.Pause
+}
+
+// expectedTotals computes the per-currency totals in Go by walking the
+// billing dates of every price segment with utils.ChargesBetween and, if
+// prorate is set, by cutting the last charge of ended subscriptions down
+// to the days used. Currencies without any spend are left out.
+func expectedTotals(subs []seededSubscription, periodStart, periodEnd time.Time, userID uuid.UUID, serviceName string, prorate bool) map[string]int64 {
+	totals := map[string]int64{}
+	for _, sub := range subs {
+		if userID != uuid.Nil && sub.UserID != userID {
+			continue
+		}
+		if serviceName != "" && sub.ServiceName != serviceName {
+			continue
+		}
+
+		subEnd := time.Date(storage.MaxFutureDate, 1, 1, 0, 0, 0, 0, time.UTC)
+		if sub.EndDate.Valid {
+			subEnd = sub.EndDate.Time
+		}
+
+		billingStart := sub.BillingStart()
+		stepMonths, stepDays := sub.BillingStep()
+		for i, price := range sub.Prices {
+			// The first price also covers everything before it.
+			segStart := periodStart
+			if i > 0 && price.EffectiveFrom.After(segStart) {
+				segStart = price.EffectiveFrom
+			}
+			segEnd := periodEnd
+			if i+1 < len(sub.Prices) {
+				if last := sub.Prices[i+1].EffectiveFrom.AddDate(0, 0, -1); last.Before(segEnd) {
+					segEnd = last
+				}
+			}
+
+			charges := utils.ChargesBetween(billingStart, subEnd, segStart, segEnd, stepMonths, stepDays)
+			for _, pause := range sub.Pauses {
+				pauseStart, pauseEnd := pause.From, subEnd
+				if pause.Until.Valid {
+					pauseEnd = pause.Until.Time
+				}
+				if pauseStart.Before(segStart) {
+					pauseStart = segStart
+				}
+				if pauseEnd.After(segEnd) {
+					pauseEnd = segEnd
+				}
+				charges -= utils.ChargesBetween(billingStart, subEnd, pauseStart, pauseEnd, stepMonths, stepDays)
+			}
+			if charges > 0 {
+				totals[sub.Currency] += int64(charges) * int64(price.Price)
+			}
+		}
+
+		if !prorate || !sub.EndDate.Valid || subEnd.Before(billingStart) {
+			continue
+		}
+		n := utils.ChargesBetween(billingStart, subEnd, billingStart, subEnd, stepMonths, stepDays)
+		last := utils.BillingDate(billingStart, n-1, stepMonths, stepDays)
+		next := utils.BillingDate(billingStart, n, stepMonths, stepDays)
+		dayAfterEnd := subEnd.AddDate(0, 0, 1)
+		if last.Before(periodStart) || last.After(periodEnd) || !dayAfterEnd.Before(next) || sub.pausedOn(last) {
+			continue
+		}
+		price := int64(sub.priceAt(last))
+		used := dayAfterEnd.Sub(last).Hours() / 24
+		cycle := next.Sub(last).Hours() / 24
+		totals[sub.Currency] += int64(math.Round(float64(price)*used/cycle)) - price
+	}
+	return totals
+}
+
+// pausedOn reports whether the day d falls into a pause.
+func (s seededSubscription) pausedOn(d time.Time) bool {
+	for _, pause := range s.Pauses {
+		if !d.Before(pause.From) && (!pause.Until.Valid || !d.After(pause.Until.Time)) {
+			return true
+		}
+	}
+	return false
+}
+
+// priceAt returns the price in effect on the day d.
+func (s seededSubscription) priceAt(d time.Time) int {
+	price := s.Prices[0].Price
+	for _, change := range s.Prices[1:] {
+		if change.EffectiveFrom.After(d) {
+			break
+		}
+		price = change.Price
+	}
+	return price
+}
+
+// seedRandomSubscriptions inserts a few hundred random subscriptions
+// spread over a handful of users and services, some of them with trials,
+// price changes and pauses.
+func seedRandomSubscriptions(t *testing.T, store storage.SubscriptionStorage, rng *rand.Rand) ([]seededSubscription, []uuid.UUID, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	users := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	services := []string{"Yandex Plus", "Netflix", "Spotify", "iCloud"}
+	currencies := []string{"RUB", "USD", "EUR"}
+	billingPeriods := []models.BillingPeriod{
+		models.BillingMonthly,
+		models.BillingMonthly,
+		models.BillingQuarterly,
+		models.BillingYearly,
+		models.BillingWeekly,
+		models.BillingCustom,
+	}
+
+	var subs []seededSubscription
+	for range 500 {
+		sub := models.Subscription{
+			ServiceName: services[rng.Intn(len(services))],
+			Price:       1 + rng.Intn(2000),
+			Currency:    currencies[rng.Intn(len(currencies))],
+			UserID:      users[rng.Intn(len(users))],
+			StartDate:   randomDay(rng),
+
+			BillingPeriod: billingPeriods[rng.Intn(len(billingPeriods))],
+		}
+		if sub.BillingPeriod == models.BillingCustom {
+			sub.BillingIntervalMonths = sql.NullInt32{Int32: int32(1 + rng.Intn(18)), Valid: true}
+		}
+		switch rng.Intn(6) {
+		case 0:
+			sub.TrialEnd = sql.NullTime{Time: utils.AddMonths(sub.StartDate, 1+rng.Intn(3)).AddDate(0, 0, -1), Valid: true}
+		case 1:
+			sub.TrialEnd = sql.NullTime{Time: sub.StartDate.AddDate(0, 0, rng.Intn(90)), Valid: true}
+		}
+		switch rng.Intn(3) {
+		case 0:
+			// open-ended subscription
+		case 1:
+			sub.EndDate = sql.NullTime{Time: randomPeriodEnd(rng, sub.StartDate, 36), Valid: true}
+		case 2:
+			// inverted range, must never be charged
+			sub.EndDate = sql.NullTime{Time: sub.StartDate.AddDate(0, 0, -1-rng.Intn(365)), Valid: true}
+		}
+
+		id, err := store.Create(ctx, &sub)
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		sub.ID = id
+
+		startMonth := utils.StartOfMonth(sub.StartDate)
+		prices := map[time.Time]int{startMonth: sub.Price}
+		for range rng.Intn(4) {
+			change := models.PriceChange{
+				Price:         1 + rng.Intn(2000),
+				EffectiveFrom: startMonth.AddDate(0, rng.Intn(48)-6, 0),
+			}
+			if err := store.ChangePrice(ctx, id, change); err != nil {
+				t.Fatalf("change price: %v", err)
+			}
+			prices[change.EffectiveFrom] = change.Price
+		}
+
+		seeded := seededSubscription{Subscription: sub}
+
+		// Pauses follow each other with gaps, only the last one may be open.
+		pauseStart := sub.StartDate.AddDate(0, 0, rng.Intn(120)-30)
+		for i, n := 0, rng.Intn(3); i < n; i++ {
+			pause := models.Pause{From: pauseStart}
+			if i < n-1 || rng.Intn(2) == 0 {
+				pause.Until = sql.NullTime{Time: pauseStart.AddDate(0, 0, rng.Intn(200)), Valid: true}
+				pauseStart = pause.Until.Time.AddDate(0, 0, 1+rng.Intn(200))
+			}
+			if err := store.Pause(ctx, id, pause); err != nil {
+				t.Fatalf("pause: %v", err)
+			}
+			seeded.Pauses = append(seeded.Pauses, pause)
+		}
+
+		for _, month := range slices.SortedFunc(maps.Keys(prices), time.Time.Compare) {
+			seeded.Prices = append(seeded.Prices, models.PriceChange{Price: prices[month], EffectiveFrom: month})
+		}
+		subs = append(subs, seeded)
+	}
+	return subs, users, services
+}
+
+func TestTotalForPeriodMatchesChargesInPeriod(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	seed := time.Now().UnixNano()
+	t.Logf("seed: %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+
+	subs, users, services := seedRandomSubscriptions(t, store, rng)
+
+	for range 200 {
+		periodStart := randomDay(rng)
+		periodEnd := randomPeriodEnd(rng, periodStart, 48)
+		prorate := rng.Intn(2) == 0
+
+		userID := uuid.Nil
+		if rng.Intn(2) == 0 {
+			userID = users[rng.Intn(len(users))]
+		}
+		serviceName := ""
+		if rng.Intn(2) == 0 {
+			serviceName = services[rng.Intn(len(services))]
+		}
+
+		got, err := store.TotalForPeriod(ctx, periodStart, periodEnd, userID, serviceName, prorate)
+		if err != nil {
+			t.Fatalf("total for period: %v", err)
+		}
+
+		want := expectedTotals(subs, periodStart, periodEnd, userID, serviceName, prorate)
+		if !maps.Equal(got, want) {
+			t.Fatalf(
+				"period %s..%s user %s service %q prorate %v: expected %v, got %v",
+				periodStart.Format(time.DateOnly), periodEnd.Format(time.DateOnly), userID, serviceName, prorate, want, got,
+			)
+		}
+	}
+}
+
+func TestTotalForPeriodEmpty(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+
+	got, err := store.TotalForPeriod(
+		context.Background(),
+		time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC),
+		uuid.Nil,
+		"",
+		false,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("expected no totals, got %v", got)
+	}
+}
+
+func TestTimeSeriesMatchesChargesInPeriod(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	seed := time.Now().UnixNano()
+	t.Logf("seed: %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+
+	subs, users, _ := seedRandomSubscriptions(t, store, rng)
+
+	for range 20 {
+		periodStart := randomDay(rng)
+		periodEnd := randomPeriodEnd(rng, periodStart, 24)
+		userID := users[rng.Intn(len(users))]
+
+		series, err := store.TimeSeries(ctx, periodStart, periodEnd, userID, "", true, false)
+		if err != nil {
+			t.Fatalf("time series: %v", err)
+		}
+
+		perMonth := map[time.Time]map[string]int64{}
+		for _, item := range series {
+			if perMonth[item.Month] == nil {
+				perMonth[item.Month] = map[string]int64{}
+			}
+			if item.Currency != "" {
+				perMonth[item.Month][item.Currency] += item.Total
+			}
+		}
+
+		for month := utils.StartOfMonth(periodStart); !month.After(periodEnd); month = month.AddDate(0, 1, 0) {
+			got, ok := perMonth[month]
+			if !ok {
+				t.Fatalf("month %s has no bucket", month.Format("01-2006"))
+			}
+			from, to := month, utils.EndOfMonth(month)
+			if from.Before(periodStart) {
+				from = periodStart
+			}
+			if to.After(periodEnd) {
+				to = periodEnd
+			}
+			want := expectedTotals(subs, from, to, userID, "", false)
+			if !maps.Equal(got, want) {
+				t.Fatalf("month %s user %s: expected %v, got %v", month.Format("01-2006"), userID, want, got)
+			}
+		}
+	}
+}
+
+// breakdownTotals turns breakdown rows into per-group, per-currency totals.
+// Groups without any spend get an empty map so they compare equal to
+// expectedTotals.
+func breakdownTotals(items []models.BreakdownItem) map[string]map[string]int64 {
+	out := map[string]map[string]int64{}
+	for _, item := range items {
+		if out[item.Key] == nil {
+			out[item.Key] = map[string]int64{}
+		}
+		out[item.Key][item.Currency] += item.Total
+	}
+	return out
+}
+
+func TestBreakdownMatchesChargesInPeriod(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	seed := time.Now().UnixNano()
+	t.Logf("seed: %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+
+	subs, users, services := seedRandomSubscriptions(t, store, rng)
+
+	for range 20 {
+		periodStart := randomDay(rng)
+		periodEnd := randomPeriodEnd(rng, periodStart, 24)
+
+		byService, err := store.Breakdown(ctx, periodStart, periodEnd, uuid.Nil, "", storage.GroupByServiceName, false)
+		if err != nil {
+			t.Fatalf("breakdown: %v", err)
+		}
+		got := breakdownTotals(byService)
+		for _, service := range services {
+			if want := expectedTotals(subs, periodStart, periodEnd, uuid.Nil, service, false); !maps.Equal(got[service], want) {
+				t.Fatalf("service %q: expected %v, got %v", service, want, got[service])
+			}
+		}
+
+		byUser, err := store.Breakdown(ctx, periodStart, periodEnd, uuid.Nil, "", storage.GroupByUserID, false)
+		if err != nil {
+			t.Fatalf("breakdown: %v", err)
+		}
+		got = breakdownTotals(byUser)
+		for _, user := range users {
+			if want := expectedTotals(subs, periodStart, periodEnd, user, "", false); !maps.Equal(got[user.String()], want) {
+				t.Fatalf("user %s: expected %v, got %v", user, want, got[user.String()])
+			}
+		}
+	}
+}
+
+func TestBreakdownInvalidGroupBy(t *testing.T) {
+	store := storage.NewPostgresSubscriptionStorage(nil)
+
+	_, err := store.Breakdown(context.Background(), time.Now(), time.Now(), uuid.Nil, "", "price; DROP TABLE subscriptions", false)
+	if !errors.Is(err, storage.ErrInvalidGroupBy) {
+		t.Fatalf("expected ErrInvalidGroupBy, got %v", err)
+	}
+}
+
+func TestPriceChangeKeepsPastTotals(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	thisMonth := utils.StartOfMonth(time.Now())
+	sub := models.Subscription{
+		ServiceName:   "Netflix",
+		Price:         100,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartDate:     thisMonth.AddDate(-1, 0, 0),
+		BillingPeriod: models.BillingMonthly,
+	}
+	id, err := store.Create(ctx, &sub)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	lastMonth := utils.EndOfMonth(thisMonth.AddDate(0, -1, 0))
+	before, err := store.TotalForPeriod(ctx, sub.StartDate, lastMonth, sub.UserID, "", false)
+	if err != nil {
+		t.Fatalf("total for period: %v", err)
+	}
+
+	sub.Price = 300
+	if _, err := store.Update(ctx, id, &sub); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	after, err := store.TotalForPeriod(ctx, sub.StartDate, lastMonth, sub.UserID, "", false)
+	if err != nil {
+		t.Fatalf("total for period: %v", err)
+	}
+	if !maps.Equal(before, after) {
+		t.Fatalf("past total changed from %v to %v", before, after)
+	}
+
+	got, err := store.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Price != 300 {
+		t.Fatalf("expected current price 300, got %d", got.Price)
+	}
+
+	history, err := store.PriceHistory(ctx, id)
+	if err != nil {
+		t.Fatalf("price history: %v", err)
+	}
+	if len(history) != 2 || history[0].Price != 100 || history[1].Price != 300 {
+		t.Fatalf("unexpected price history: %+v", history)
+	}
+}
+
+func TestPriceHistoryNotFound(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+
+	_, err := store.PriceHistory(context.Background(), 999)
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestTotalForPeriodProratesMidCycleEnd(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	// Billed on Jan 15 and Feb 15, cancelled 16 days into the 28 day
+	// cycle from Feb 15 to Mar 15.
+	sub := models.Subscription{
+		ServiceName:   "Netflix",
+		Price:         3000,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartDate:     time.Date(2025, time.January, 15, 0, 0, 0, 0, time.UTC),
+		EndDate:       sql.NullTime{Time: time.Date(2025, time.March, 2, 0, 0, 0, 0, time.UTC), Valid: true},
+		BillingPeriod: models.BillingMonthly,
+	}
+	if _, err := store.Create(ctx, &sub); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	periodStart := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+	full, err := store.TotalForPeriod(ctx, periodStart, periodEnd, sub.UserID, "", false)
+	if err != nil {
+		t.Fatalf("total for period: %v", err)
+	}
+	if full["RUB"] != 6000 {
+		t.Fatalf("expected 6000 without proration, got %v", full)
+	}
+
+	prorated, err := store.TotalForPeriod(ctx, periodStart, periodEnd, sub.UserID, "", true)
+	if err != nil {
+		t.Fatalf("total for period: %v", err)
+	}
+	if prorated["RUB"] != 4714 {
+		t.Fatalf("expected 4714 with proration, got %v", prorated)
+	}
+
+	// The reports prorate the same way, the reduced charge counts in the
+	// month of its billing date.
+	series, err := store.TimeSeries(ctx, periodStart, periodEnd, sub.UserID, "", false, true)
+	if err != nil {
+		t.Fatalf("time series: %v", err)
+	}
+	byMonth := map[time.Month]int64{}
+	for _, item := range series {
+		byMonth[item.Month.Month()] += item.Total
+	}
+	if byMonth[time.January] != 3000 || byMonth[time.February] != 1714 || byMonth[time.March] != 0 {
+		t.Errorf("expected 3000 in January and 1714 in February, got %v", byMonth)
+	}
+
+	items, err := store.Breakdown(ctx, periodStart, periodEnd, sub.UserID, "", storage.GroupByServiceName, true)
+	if err != nil {
+		t.Fatalf("breakdown: %v", err)
+	}
+	if len(items) != 1 || items[0].Total != 4714 {
+		t.Errorf("expected one group of 4714, got %v", items)
+	}
+}
+
+func TestTotalForPeriodSkipsTrial(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	// Signed up on Jan 15 with a one month trial, so the first charge is
+	// on Feb 15.
+	sub := models.Subscription{
+		ServiceName:   "Netflix",
+		Price:         500,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartDate:     time.Date(2025, time.January, 15, 0, 0, 0, 0, time.UTC),
+		BillingPeriod: models.BillingMonthly,
+		TrialEnd:      sql.NullTime{Time: time.Date(2025, time.February, 14, 0, 0, 0, 0, time.UTC), Valid: true},
+	}
+	id, err := store.Create(ctx, &sub)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	got, err := store.TotalForPeriod(
+		ctx,
+		time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC),
+		sub.UserID,
+		"",
+		false,
+	)
+	if err != nil {
+		t.Fatalf("total for period: %v", err)
+	}
+	if got["RUB"] != 1000 {
+		t.Fatalf("expected 1000, got %v", got)
+	}
+
+	stored, err := store.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if !stored.StartDate.Equal(sub.StartDate) || !stored.TrialEnd.Valid || !stored.TrialEnd.Time.Equal(sub.TrialEnd.Time) {
+		t.Fatalf("unexpected dates: start %v, trial end %v", stored.StartDate, stored.TrialEnd)
+	}
+}
+
+func TestPauseAndResume(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	sub := models.Subscription{
+		ServiceName:   "Netflix",
+		Price:         100,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartDate:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		BillingPeriod: models.BillingMonthly,
+	}
+	id, err := store.Create(ctx, &sub)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// Paused from March on, resumed on June 1: March, April and May are free.
+	if err := store.Pause(ctx, id, models.Pause{From: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	err = store.Pause(ctx, id, models.Pause{From: time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)})
+	if !errors.Is(err, storage.ErrPauseOverlaps) {
+		t.Fatalf("expected ErrPauseOverlaps, got %v", err)
+	}
+
+	pause, err := store.Resume(ctx, id, time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if !pause.Until.Valid || !pause.Until.Time.Equal(time.Date(2025, time.May, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected pause: %+v", pause)
+	}
+
+	_, err = store.Resume(ctx, id, time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, storage.ErrNotPaused) {
+		t.Fatalf("expected ErrNotPaused, got %v", err)
+	}
+
+	got, err := store.TotalForPeriod(
+		ctx,
+		time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC),
+		sub.UserID,
+		"",
+		false,
+	)
+	if err != nil {
+		t.Fatalf("total for period: %v", err)
+	}
+	if got["RUB"] != 900 {
+		t.Fatalf("expected 900, got %v", got)
+	}
+
+	pauses, err := store.Pauses(ctx, id)
+	if err != nil {
+		t.Fatalf("pauses: %v", err)
+	}
+	if len(pauses) != 1 {
+		t.Fatalf("expected one pause, got %+v", pauses)
+	}
+}
+
+func TestPatchWritesOnlyChangedColumns(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	sub := models.Subscription{
+		ServiceName:   "Netflix",
+		Price:         100,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartDate:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:       sql.NullTime{Time: time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC), Valid: true},
+		BillingPeriod: models.BillingMonthly,
+	}
+	id, err := store.Create(ctx, &sub)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	price := 200
+	got, err := store.Patch(ctx, id, 0, models.SubscriptionPatch{
+		Price:   &price,
+		EndDate: &sql.NullTime{},
+	})
+	if err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	if got.Price != 200 || got.EndDate.Valid || got.ServiceName != "Netflix" || !got.StartDate.Equal(sub.StartDate) {
+		t.Fatalf("unexpected subscription: %+v", got)
+	}
+
+	history, err := store.PriceHistory(ctx, id)
+	if err != nil {
+		t.Fatalf("price history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected the old and the new price, got %+v", history)
+	}
+
+	period := models.BillingCustom
+	_, err = store.Patch(ctx, id, 0, models.SubscriptionPatch{BillingPeriod: &period})
+	if !errors.Is(err, storage.ErrInvalidPatch) {
+		t.Fatalf("expected ErrInvalidPatch, got %v", err)
+	}
+
+	_, err = store.Patch(ctx, 999999, 0, models.SubscriptionPatch{Price: &price})
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestUpdateAndDeleteCheckVersion(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	sub := models.Subscription{
+		ServiceName:   "Netflix",
+		Price:         100,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartDate:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		BillingPeriod: models.BillingMonthly,
+	}
+	id, err := store.Create(ctx, &sub)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	got, err := store.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Version != 1 {
+		t.Fatalf("expected version 1, got %d", got.Version)
+	}
+
+	sub.Version = 1
+	updated, err := store.Update(ctx, id, &sub)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if sub.Version != 2 || updated.Version != 2 {
+		t.Fatalf("expected version 2, got %d and %d", sub.Version, updated.Version)
+	}
+
+	sub.Version = 1
+	if _, err := store.Update(ctx, id, &sub); !errors.Is(err, storage.ErrStaleVersion) {
+		t.Fatalf("expected ErrStaleVersion, got %v", err)
+	}
+
+	// A price change bumps the version as well.
+	if err := store.ChangePrice(ctx, id, models.PriceChange{Price: 200, EffectiveFrom: sub.StartDate}); err != nil {
+		t.Fatalf("change price: %v", err)
+	}
+	if _, err := store.Patch(ctx, id, 2, models.SubscriptionPatch{}); !errors.Is(err, storage.ErrStaleVersion) {
+		t.Fatalf("expected ErrStaleVersion, got %v", err)
+	}
+
+	if err := store.Delete(ctx, id, 2); !errors.Is(err, storage.ErrStaleVersion) {
+		t.Fatalf("expected ErrStaleVersion, got %v", err)
+	}
+	if err := store.Delete(ctx, id, 3); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := store.Delete(ctx, id, 3); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestInTx(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	newSub := func(name string) *models.Subscription {
+		return &models.Subscription{
+			ServiceName:   name,
+			Price:         100,
+			Currency:      "RUB",
+			UserID:        uuid.New(),
+			StartDate:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+			BillingPeriod: models.BillingMonthly,
+		}
+	}
+
+	// A failed transaction leaves nothing behind.
+	var rolledBack int
+	err := store.InTx(ctx, func(tx storage.SubscriptionStorage) error {
+		var err error
+		if rolledBack, err = tx.Create(ctx, newSub("Netflix")); err != nil {
+			return err
+		}
+		return tx.Delete(ctx, 999999, 0)
+	})
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := store.Get(ctx, rolledBack); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the subscription to be rolled back, got %v", err)
+	}
+
+	// A failed nested transaction only rolls back to its savepoint, even
+	// after an SQL error.
+	var kept int
+	err = store.InTx(ctx, func(tx storage.SubscriptionStorage) error {
+		var err error
+		if kept, err = tx.Create(ctx, newSub("Spotify")); err != nil {
+			return err
+		}
+		nestedErr := tx.InTx(ctx, func(nested storage.SubscriptionStorage) error {
+			sub := newSub("Broken")
+			sub.BillingPeriod = "daily"
+			_, err := nested.Create(ctx, sub)
+			return err
+		})
+		if nestedErr == nil {
+			t.Errorf("expected the nested transaction to fail")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("in tx: %v", err)
+	}
+	if _, err := store.Get(ctx, kept); err != nil {
+		t.Fatalf("expected the subscription to be committed, got %v", err)
+	}
+}
+
+func TestExportIsNotLimited(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	serviceName := "Export " + uuid.NewString()
+	for range storage.MaxLimit + 1 {
+		_, err := store.Create(ctx, &models.Subscription{
+			ServiceName:   serviceName,
+			Price:         100,
+			Currency:      "RUB",
+			UserID:        uuid.New(),
+			StartDate:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+			BillingPeriod: models.BillingMonthly,
+		})
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	var ids []int
+	err := store.Export(ctx, models.SubscriptionFilter{ServiceName: serviceName}, func(sub *models.Subscription) error {
+		ids = append(ids, sub.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if len(ids) != storage.MaxLimit+1 {
+		t.Fatalf("expected %d subscriptions, got %d", storage.MaxLimit+1, len(ids))
+	}
+	if !slices.IsSorted(ids) {
+		t.Errorf("expected the subscriptions in id order")
+	}
+
+	// An error of fn stops the export.
+	stop := errors.New("stop")
+	var n int
+	err = store.Export(ctx, models.SubscriptionFilter{ServiceName: serviceName}, func(*models.Subscription) error {
+		n++
+		return stop
+	})
+	if !errors.Is(err, stop) || n != 1 {
+		t.Errorf("expected the export to stop at the first error, got %v after %d", err, n)
+	}
+}
+
+func TestListPages(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	serviceName := "Pages " + uuid.NewString()
+	var want []int
+	for range 5 {
+		id, err := store.Create(ctx, &models.Subscription{
+			ServiceName:   serviceName,
+			Price:         100,
+			Currency:      "RUB",
+			UserID:        uuid.New(),
+			StartDate:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+			BillingPeriod: models.BillingMonthly,
+		})
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		want = append([]int{id}, want...)
+	}
+
+	// Following the cursor while a subscription is deleted neither skips
+	// nor repeats the others.
+	var got []int
+	page := models.Page{Limit: 2}
+	for first := true; ; first = false {
+		subs, hasMore, err := store.List(ctx, models.SubscriptionFilter{ServiceName: serviceName}, page)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		for _, sub := range subs {
+			got = append(got, sub.ID)
+		}
+		if first {
+			if err := store.Delete(ctx, want[0], 0); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+		}
+		if !hasMore {
+			break
+		}
+		page.After = &subs[len(subs)-1]
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// The legacy offset pagination tells whether more rows follow too.
+	subs, hasMore, err := store.List(ctx, models.SubscriptionFilter{ServiceName: serviceName}, models.Page{Limit: 2, Offset: 2})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(subs) != 2 || hasMore {
+		t.Errorf("expected the last 2 subscriptions, got %d, has more: %v", len(subs), hasMore)
+	}
+}
+
+func TestListFiltersAndSort(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	subs := map[string]*models.Subscription{
+		"Netflix":     {Price: 400, StartDate: date(2025, time.January, 1), EndDate: sql.NullTime{Time: date(2025, time.February, 28), Valid: true}},
+		"netflix 4K":  {Price: 900, StartDate: date(2025, time.February, 15)},
+		"Spotify":     {Price: 300, StartDate: date(2025, time.March, 31), EndDate: sql.NullTime{Time: date(2025, time.June, 30), Valid: true}},
+		"Yandex Plus": {Price: 300, StartDate: date(2025, time.April, 1)},
+		"Net_flix":    {Price: 100, StartDate: today.AddDate(0, 1, 0)},
+	}
+	ids := map[int]string{}
+	for name, sub := range subs {
+		sub.ServiceName = name
+		sub.Currency = "RUB"
+		sub.UserID = userID
+		sub.BillingPeriod = models.BillingMonthly
+		id, err := store.Create(ctx, sub)
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		ids[id] = name
+	}
+
+	list := func(filter models.SubscriptionFilter, page models.Page) []string {
+		t.Helper()
+		filter.UserID = userID.String()
+		var names []string
+		for {
+			got, hasMore, err := store.List(ctx, filter, page)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			for _, sub := range got {
+				names = append(names, ids[sub.ID])
+			}
+			if !hasMore {
+				return names
+			}
+			page.After = &got[len(got)-1]
+		}
+	}
+	sorted := func(names ...string) []string {
+		slices.Sort(names)
+		return names
+	}
+
+	tests := []struct {
+		name   string
+		filter models.SubscriptionFilter
+		want   []string
+	}{
+		{"prefix ignores case and wildcards", models.SubscriptionFilter{ServiceNamePrefix: "NETF"}, sorted("Netflix", "netflix 4K")},
+		{"prefix with underscore", models.SubscriptionFilter{ServiceNamePrefix: "net_"}, sorted("Net_flix")},
+		{"price range", models.SubscriptionFilter{PriceMin: utils.Int(300), PriceMax: utils.Int(400)}, sorted("Netflix", "Spotify", "Yandex Plus")},
+		{"active in March", models.SubscriptionFilter{ActiveFrom: date(2025, time.March, 1), ActiveTo: date(2025, time.March, 31)}, sorted("netflix 4K", "Spotify")},
+		{"started after March", models.SubscriptionFilter{StartedAfter: date(2025, time.March, 31)}, sorted("Net_flix", "Yandex Plus")},
+		{"ended before March", models.SubscriptionFilter{EndedBefore: date(2025, time.March, 1)}, sorted("Netflix")},
+		{"active", models.SubscriptionFilter{Status: models.StatusActive}, sorted("netflix 4K", "Yandex Plus")},
+		{"ended", models.SubscriptionFilter{Status: models.StatusEnded}, sorted("Netflix", "Spotify")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sorted(list(tt.filter, models.Page{})...); !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	// Keyset pagination follows the sort, ties included.
+	page := models.Page{Limit: 2, Sort: []models.SortKey{{Column: "price"}, {Column: "start_date", Desc: true}}}
+	want := []string{"Net_flix", "Yandex Plus", "Spotify", "Netflix", "netflix 4K"}
+	if got := list(models.SubscriptionFilter{}, page); !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	_, _, err := store.List(ctx, models.SubscriptionFilter{}, models.Page{Sort: []models.SortKey{{Column: "user_id"}}})
+	if !errors.Is(err, storage.ErrInvalidSort) {
+		t.Errorf("expected ErrInvalidSort, got %v", err)
+	}
+}
+
+func TestListByCurrentPrice(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	ids := map[int]string{}
+	create := func(name string, price int) int {
+		t.Helper()
+		id, err := store.Create(ctx, &models.Subscription{
+			ServiceName:   name,
+			Price:         price,
+			Currency:      "RUB",
+			UserID:        userID,
+			StartDate:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+			BillingPeriod: models.BillingMonthly,
+		})
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		ids[id] = name
+		return id
+	}
+	netflix := create("Netflix", 100)
+	create("Spotify", 300)
+	create("Yandex Plus", 400)
+
+	// The price column keeps 100, the list filters and sorts on 500.
+	change := models.PriceChange{Price: 500, EffectiveFrom: time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)}
+	if err := store.ChangePrice(ctx, netflix, change); err != nil {
+		t.Fatalf("change price: %v", err)
+	}
+
+	list := func(filter models.SubscriptionFilter, page models.Page) []string {
+		t.Helper()
+		filter.UserID = userID.String()
+		var names []string
+		for {
+			got, hasMore, err := store.List(ctx, filter, page)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			for _, sub := range got {
+				names = append(names, ids[sub.ID])
+			}
+			if !hasMore {
+				return names
+			}
+			page.After = &got[len(got)-1]
+		}
+	}
+
+	if got, want := list(models.SubscriptionFilter{PriceMin: utils.Int(450)}, models.Page{}), []string{"Netflix"}; !slices.Equal(got, want) {
+		t.Errorf("price_min: expected %v, got %v", want, got)
+	}
+	if got, want := list(models.SubscriptionFilter{PriceMax: utils.Int(200)}, models.Page{}), []string(nil); !slices.Equal(got, want) {
+		t.Errorf("price_max: expected %v, got %v", want, got)
+	}
+	page := models.Page{Limit: 1, Sort: []models.SortKey{{Column: "price"}}}
+	if got, want := list(models.SubscriptionFilter{}, page), []string{"Spotify", "Yandex Plus", "Netflix"}; !slices.Equal(got, want) {
+		t.Errorf("sort by price: expected %v, got %v", want, got)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	ids := map[int]string{}
+	for _, name := range []string{"Yandex Plus", "yandex plus", "Яндекс Плюс", "Netflix", "Кинопоиск"} {
+		id, err := store.Create(ctx, &models.Subscription{
+			ServiceName:   name,
+			Price:         100,
+			Currency:      "RUB",
+			UserID:        userID,
+			StartDate:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+			BillingPeriod: models.BillingMonthly,
+		})
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		ids[id] = name
+	}
+	// Another user's subscription is filtered out.
+	if _, err := store.Create(ctx, &models.Subscription{
+		ServiceName:   "Yandex Plus",
+		Price:         100,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartDate:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		BillingPeriod: models.BillingMonthly,
+	}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	search := func(query string) []string {
+		t.Helper()
+		results, _, err := store.Search(ctx, query, models.SubscriptionFilter{UserID: userID.String()}, 0, 0)
+		if err != nil {
+			t.Fatalf("search %q: %v", query, err)
+		}
+		var names []string
+		for i, result := range results {
+			if i > 0 && result.Rank > results[i-1].Rank {
+				t.Errorf("search %q: results are not ranked", query)
+			}
+			names = append(names, ids[result.ID])
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	want := []string{"Yandex Plus", "yandex plus", "Яндекс Плюс"}
+	for _, query := range []string{"yandex plus", "Яндекс плюс", "yandx plus"} {
+		if got := search(query); !slices.Equal(got, want) {
+			t.Errorf("search %q: expected %v, got %v", query, want, got)
+		}
+	}
+	if got := search("netf"); !slices.Equal(got, []string{"Netflix"}) {
+		t.Errorf("search netf: expected Netflix, got %v", got)
+	}
+	// The Russian stemmer matches other forms of the word.
+	if got := search("кинопоиска"); !slices.Equal(got, []string{"Кинопоиск"}) {
+		t.Errorf("search кинопоиска: expected Кинопоиск, got %v", got)
+	}
+}
+
+func TestCategoryAndTags(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	ids := map[int]string{}
+	for _, sub := range []models.Subscription{
+		{ServiceName: "Netflix", Category: sql.NullString{String: "streaming", Valid: true}, Tags: []string{"family", "tv"}},
+		{ServiceName: "Kinopoisk", Category: sql.NullString{String: "streaming", Valid: true}, Tags: []string{"tv"}},
+		{ServiceName: "Spotify", Category: sql.NullString{String: "music", Valid: true}},
+		{ServiceName: "iCloud"},
+	} {
+		sub.Price = 100
+		sub.Currency = "RUB"
+		sub.UserID = userID
+		sub.StartDate = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+		sub.BillingPeriod = models.BillingMonthly
+		id, err := store.Create(ctx, &sub)
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		ids[id] = sub.ServiceName
+	}
+
+	list := func(filter models.SubscriptionFilter) []string {
+		t.Helper()
+		filter.UserID = userID.String()
+		subs, _, err := store.List(ctx, filter, models.Page{})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		var names []string
+		for _, sub := range subs {
+			names = append(names, ids[sub.ID])
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	tests := []struct {
+		filter models.SubscriptionFilter
+		want   []string
+	}{
+		{models.SubscriptionFilter{Category: "streaming"}, []string{"Kinopoisk", "Netflix"}},
+		{models.SubscriptionFilter{Tags: []string{"tv"}}, []string{"Kinopoisk", "Netflix"}},
+		{models.SubscriptionFilter{Tags: []string{"tv", "family"}}, []string{"Netflix"}},
+		{models.SubscriptionFilter{Category: "music", Tags: []string{"tv"}}, nil},
+	}
+	for _, tt := range tests {
+		if got := list(tt.filter); !slices.Equal(got, tt.want) {
+			t.Errorf("list %+v: got %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+
+	// Tags are replaced by a patch, and cleared by an empty list.
+	id := slices.Collect(maps.Keys(ids))[0]
+	noTags := []string{}
+	sub, err := store.Patch(ctx, id, 0, models.SubscriptionPatch{Tags: &noTags})
+	if err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	if len(sub.Tags) != 0 {
+		t.Errorf("patched tags: %v", sub.Tags)
+	}
+
+	items, err := store.Breakdown(ctx,
+		time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC),
+		userID, "", storage.GroupByCategory, false)
+	if err != nil {
+		t.Fatalf("breakdown: %v", err)
+	}
+	got := map[string]int64{}
+	for _, item := range items {
+		got[item.Key] += item.Total
+	}
+	want := map[string]int64{"streaming": 200, "music": 100, "": 100}
+	if !maps.Equal(got, want) {
+		t.Errorf("breakdown by category: got %v, want %v", got, want)
+	}
+}
+
+func TestMetadataAndNotes(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	ids := map[int]string{}
+	for name, metadata := range map[string]string{
+		"Apple Music": `{"app_store_id": "1000000123", "family": true}`,
+		"Netflix":     `{"bank_ref": "TX-1"}`,
+		"Spotify":     "",
+	} {
+		sub := models.Subscription{
+			ServiceName:   name,
+			Price:         100,
+			Currency:      "RUB",
+			UserID:        userID,
+			StartDate:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+			BillingPeriod: models.BillingMonthly,
+			Notes:         sql.NullString{String: "note", Valid: true},
+		}
+		if metadata != "" {
+			sub.Metadata = json.RawMessage(metadata)
+		}
+		id, err := store.Create(ctx, &sub)
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		ids[id] = name
+	}
+
+	list := func(filter models.SubscriptionFilter) []string {
+		t.Helper()
+		filter.UserID = userID.String()
+		subs, _, err := store.List(ctx, filter, models.Page{})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		var names []string
+		for _, sub := range subs {
+			names = append(names, ids[sub.ID])
+			if !sub.Notes.Valid || sub.Notes.String != "note" {
+				t.Errorf("notes of %s: %v", ids[sub.ID], sub.Notes)
+			}
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	tests := []struct {
+		filter models.SubscriptionFilter
+		want   []string
+	}{
+		{models.SubscriptionFilter{Metadata: map[string]string{"app_store_id": "1000000123"}}, []string{"Apple Music"}},
+		{models.SubscriptionFilter{Metadata: map[string]string{"app_store_id": "1"}}, nil},
+		{models.SubscriptionFilter{MetadataKeys: []string{"bank_ref"}}, []string{"Netflix"}},
+		{models.SubscriptionFilter{MetadataKeys: []string{"bank_ref", "family"}}, nil},
+	}
+	for _, tt := range tests {
+		if got := list(tt.filter); !slices.Equal(got, tt.want) {
+			t.Errorf("list %+v: got %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+
+	var appleID int
+	for id, name := range ids {
+		if name == "Apple Music" {
+			appleID = id
+		}
+	}
+
+	// A patch merges into the metadata.
+	sub, err := store.Patch(ctx, appleID, 0, models.SubscriptionPatch{
+		Metadata: json.RawMessage(`{"family": null, "bank_ref": "TX-2"}`),
+	})
+	if err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	var metadata map[string]any
+	if err := json.Unmarshal(sub.Metadata, &metadata); err != nil {
+		t.Fatalf("unmarshal metadata %s: %v", sub.Metadata, err)
+	}
+	if want := map[string]any{"app_store_id": "1000000123", "bank_ref": "TX-2"}; !maps.Equal(metadata, want) {
+		t.Errorf("patched metadata: got %v, want %v", metadata, want)
+	}
+
+	// Merged metadata can't outgrow the limits.
+	big := `{"big": "` + strings.Repeat("x", validators.MaxMetadataBytes-20) + `"}`
+	if _, err := store.Patch(ctx, appleID, 0, models.SubscriptionPatch{Metadata: json.RawMessage(big)}); !errors.Is(err, storage.ErrInvalidPatch) {
+		t.Errorf("patch over the limit: %v, want ErrInvalidPatch", err)
+	}
+
+	// null empties it.
+	sub, err = store.Patch(ctx, appleID, 0, models.SubscriptionPatch{Metadata: json.RawMessage(`null`)})
+	if err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	if string(sub.Metadata) != "{}" {
+		t.Errorf("emptied metadata: %s", sub.Metadata)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewPostgresSubscriptionStorage(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC)
+	sub := models.Subscription{
+		ServiceName:   "Netflix",
+		Price:         100,
+		Currency:      "RUB",
+		UserID:        userID,
+		StartDate:     start,
+		BillingPeriod: models.BillingMonthly,
+	}
+	id, err := store.Create(ctx, &sub)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := store.Pause(ctx, id, models.Pause{From: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+
+	total := func() int64 {
+		t.Helper()
+		totals, err := store.TotalForPeriod(ctx, start, end, userID, "", false)
+		if err != nil {
+			t.Fatalf("total: %v", err)
+		}
+		return totals["RUB"]
+	}
+	list := func(deleted bool) int {
+		t.Helper()
+		subs, _, err := store.List(ctx, models.SubscriptionFilter{UserID: userID.String(), Deleted: deleted}, models.Page{})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		return len(subs)
+	}
+
+	if err := store.Delete(ctx, id, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	// The deleted subscription is hidden from everything but the trash.
+	if _, err := store.Get(ctx, id); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("get deleted: %v, want ErrNotFound", err)
+	}
+	if _, err := store.Update(ctx, id, &sub); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("update deleted: %v, want ErrNotFound", err)
+	}
+	if _, err := store.Pauses(ctx, id); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("pauses of deleted: %v, want ErrNotFound", err)
+	}
+	if n := list(false); n != 0 {
+		t.Errorf("list: %d subscriptions, want 0", n)
+	}
+	if got := total(); got != 0 {
+		t.Errorf("total: %d, want 0", got)
+	}
+	if n := list(true); n != 1 {
+		t.Errorf("trash: %d subscriptions, want 1", n)
+	}
+
+	restored, err := store.Restore(ctx, id)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if restored.DeletedAt.Valid || restored.Version != 4 {
+		t.Errorf("restored: deleted at %v, version %d", restored.DeletedAt, restored.Version)
+	}
+	if _, err := store.Restore(ctx, id); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("restore twice: %v, want ErrNotFound", err)
+	}
+	if got := total(); got != 100 {
+		t.Errorf("total after restore: %d, want 100", got)
+	}
+
+	// Only the subscriptions deleted before the cutoff are purged, along
+	// with their pauses.
+	if err := store.Delete(ctx, id, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if n, err := store.Purge(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("purge before delete: %d, %v", n, err)
+	}
+	if n, err := store.Purge(ctx, time.Now().Add(time.Hour)); err != nil || n != 1 {
+		t.Errorf("purge: %d, %v", n, err)
+	}
+	if _, err := store.Restore(ctx, id); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("restore purged: %v, want ErrNotFound", err)
+	}
+	var pauses int
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM subscription_pauses WHERE subscription_id = $1`, id).Scan(&pauses); err != nil {
+		t.Fatalf("count pauses: %v", err)
+	}
+	if pauses != 0 {
+		t.Errorf("purged subscription has %d pauses", pauses)
+	}
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// dbtx is the part of *sql.DB and *sql.Tx used by the storages.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// txScope is the transaction shared by a storage passed to InTx and the
+// transactions it starts.
+type txScope struct {
+	tx         *sql.Tx
+	savepoints int
+}
+
+// tx is a database transaction or, inside a txScope, a savepoint of the
+// scope's transaction, so that storage methods with several statements
+// stay atomic both on their own and inside InTx.
+type tx struct {
+	*sql.Tx
+	ctx       context.Context
+	savepoint string
+	done      bool
+}
+
+// begin starts a transaction, or a savepoint if scope is not nil.
+func begin(ctx context.Context, db *sql.DB, scope *txScope) (*tx, error) {
+	if scope == nil {
+		sqlTx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &tx{Tx: sqlTx, ctx: ctx}, nil
+	}
+
+	scope.savepoints++
+	name := fmt.Sprintf("sp_%d", scope.savepoints)
+	if _, err := scope.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return nil, err
+	}
+	return &tx{Tx: scope.tx, ctx: ctx, savepoint: name}, nil
+}
+
+func (t *tx) Commit() error {
+	if t.savepoint == "" {
+		return t.Tx.Commit()
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	_, err := t.Tx.ExecContext(t.ctx, "RELEASE SAVEPOINT "+t.savepoint)
+	return err
+}
+
+// Rollback undoes the transaction. Like sql.Tx.Rollback, it can be
+// deferred right after begin, since it does nothing after Commit.
+func (t *tx) Rollback() error {
+	if t.savepoint == "" {
+		return t.Tx.Rollback()
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	_, err := t.Tx.ExecContext(t.ctx, "ROLLBACK TO SAVEPOINT "+t.savepoint)
+	return err
+}
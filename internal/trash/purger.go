@@ -0,0 +1,48 @@
+// Package trash purges the deleted subscriptions once they have been in
+// the trash for longer than the retention period.
+package trash
+
+import (
+	"context"
+	"log/slog"
+	"testovoe/internal/storage"
+	"testovoe/internal/utils"
+	"time"
+)
+
+type Purger struct {
+	store     storage.SubscriptionStorage
+	retention time.Duration
+	interval  time.Duration
+	// now is time.Now, replaced in tests.
+	now func() time.Time
+}
+
+// NewPurger returns a purger removing the subscriptions deleted more than
+// retention ago, checking every interval.
+func NewPurger(store storage.SubscriptionStorage, retention, interval time.Duration) *Purger {
+	return &Purger{store: store, retention: retention, interval: interval, now: time.Now}
+}
+
+// Run purges the trash at start-up, so that a restart does not delay it,
+// and then every interval until ctx is done. A failed purge is logged; the
+// subscriptions it missed are purged by the next one.
+func (p *Purger) Run(ctx context.Context) {
+	utils.Every(ctx, p.interval, "purge trash", func(ctx context.Context) error {
+		_, err := p.PurgeOnce(ctx)
+		return err
+	})
+}
+
+// PurgeOnce removes the subscriptions whose retention is over and returns
+// how many there were.
+func (p *Purger) PurgeOnce(ctx context.Context) (int64, error) {
+	n, err := p.store.Purge(ctx, p.now().Add(-p.retention))
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		slog.InfoContext(ctx, "purge trash", "subscriptions", n)
+	}
+	return n, nil
+}
@@ -0,0 +1,70 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	mock_storage "testovoe/internal/storage/mocks"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"go.uber.org/mock/gomock"
+)
+
+func TestPurgeOnce(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	ctrl := gomock.NewController(t)
+	store := mock_storage.NewMockSubscriptionStorage(ctrl)
+
+	now := time.Date(2025, time.March, 31, 12, 0, 0, 0, time.UTC)
+	p := NewPurger(store, 30*24*time.Hour, time.Hour)
+	p.now = func() time.Time { return now }
+
+	store.EXPECT().
+		Purge(gomock.Any(), time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)).
+		Return(int64(2), nil).
+		Times(1)
+
+	n, err := p.PurgeOnce(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(2), n)
+
+	store.EXPECT().
+		Purge(gomock.Any(), gomock.Any()).
+		Return(int64(0), errors.New("connection refused")).
+		Times(1)
+
+	_, err = p.PurgeOnce(context.Background())
+	assert.NotEqual(t, nil, err)
+}
+
+func TestRunStopsWithContext(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	ctrl := gomock.NewController(t)
+	store := mock_storage.NewMockSubscriptionStorage(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// The first purge runs right away, the next one would be an hour
+	// later.
+	store.EXPECT().
+		Purge(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, time.Time) (int64, error) {
+			cancel()
+			return 0, nil
+		}).
+		Times(1)
+
+	done := make(chan struct{})
+	go func() {
+		NewPurger(store, time.Hour, time.Hour).Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after the context was canceled")
+	}
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"time"
+)
+
+const isoDate = "2006-01-02"
+
+// ParseStartDate parses the first day of a range given either as MM-YYYY,
+// which means the first day of that month, or as an ISO YYYY-MM-DD date.
+func ParseStartDate(s string) (time.Time, error) {
+	if t, err := time.Parse(isoDate, s); err == nil {
+		return t, nil
+	}
+	return ParseMonthYear(s)
+}
+
+// ParseEndDate parses the last day of a range given either as MM-YYYY,
+// which means the last day of that month, or as an ISO YYYY-MM-DD date.
+func ParseEndDate(s string) (time.Time, error) {
+	if t, err := time.Parse(isoDate, s); err == nil {
+		return t, nil
+	}
+	t, err := ParseMonthYear(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return EndOfMonth(t), nil
+}
+
+// FormatStartDate is the inverse of ParseStartDate: the first day of a month
+// is formatted as MM-YYYY, any other day as YYYY-MM-DD.
+func FormatStartDate(t time.Time) string {
+	if t.Day() == 1 {
+		return t.Format("01-2006")
+	}
+	return t.Format(isoDate)
+}
+
+// FormatEndDate is the inverse of ParseEndDate: the last day of a month
+// is formatted as MM-YYYY, any other day as YYYY-MM-DD.
+func FormatEndDate(t time.Time) string {
+	if t.Day() == EndOfMonth(t).Day() {
+		return t.Format("01-2006")
+	}
+	return t.Format(isoDate)
+}
+
+// EndOfMonth returns midnight UTC of the last day of the month of t.
+func EndOfMonth(t time.Time) time.Time {
+	return StartOfMonth(t).AddDate(0, 1, -1)
+}
+
+// AddMonths adds n months to t. Unlike time.AddDate, a day that doesn't
+// exist in the target month is clamped to its last day, so January 31 plus
+// one month is the end of February, the same as in Postgres.
+func AddMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	day := min(t.Day(), EndOfMonth(first).Day())
+	return first.AddDate(0, 0, day-1)
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStartDate(t *testing.T) {
+	cases := map[string]time.Time{
+		"03-2025":    time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+		"2025-03-15": time.Date(2025, time.March, 15, 0, 0, 0, 0, time.UTC),
+	}
+	for in, expected := range cases {
+		got, err := ParseStartDate(in)
+		if err != nil {
+			t.Fatalf("input %q: unexpected error: %v", in, err)
+		}
+		if !got.Equal(expected) {
+			t.Fatalf("input %q: expected %v, got %v", in, expected, got)
+		}
+	}
+}
+
+func TestParseEndDate(t *testing.T) {
+	cases := map[string]time.Time{
+		"02-2024":    time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		"2025-03-02": time.Date(2025, time.March, 2, 0, 0, 0, 0, time.UTC),
+	}
+	for in, expected := range cases {
+		got, err := ParseEndDate(in)
+		if err != nil {
+			t.Fatalf("input %q: unexpected error: %v", in, err)
+		}
+		if !got.Equal(expected) {
+			t.Fatalf("input %q: expected %v, got %v", in, expected, got)
+		}
+	}
+}
+
+func TestParseEndDate_InvalidFormat(t *testing.T) {
+	if _, err := ParseEndDate("2025/03/02"); err == nil {
+		t.Fatalf("expected error for invalid format, got nil")
+	}
+}
+
+func TestFormatDates(t *testing.T) {
+	first := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC)
+
+	if got := FormatStartDate(first); got != "03-2025" {
+		t.Fatalf("expected 03-2025, got %s", got)
+	}
+	if got := FormatStartDate(last); got != "2025-03-31" {
+		t.Fatalf("expected 2025-03-31, got %s", got)
+	}
+	if got := FormatEndDate(last); got != "03-2025" {
+		t.Fatalf("expected 03-2025, got %s", got)
+	}
+	if got := FormatEndDate(first); got != "2025-03-01" {
+		t.Fatalf("expected 2025-03-01, got %s", got)
+	}
+}
+
+func TestAddMonths_ClampsToEndOfMonth(t *testing.T) {
+	got := AddMonths(time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC), 1)
+	expected := time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)
+	if !got.Equal(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
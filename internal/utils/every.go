@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Every runs fn right away and then every interval, until ctx is done. An
+// error of fn is logged under msg and does not stop the next runs.
+func Every(ctx context.Context, interval time.Duration, msg string, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(ctx); err != nil {
+			slog.ErrorContext(ctx, msg, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
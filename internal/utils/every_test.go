@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEvery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A failing run does not stop the next ones.
+	runs := 0
+	done := make(chan struct{})
+	go func() {
+		Every(ctx, time.Millisecond, "test", func(context.Context) error {
+			runs++
+			if runs == 3 {
+				cancel()
+			}
+			return errors.New("failed")
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Every did not return after ctx was done")
+	}
+	if runs != 3 {
+		t.Errorf("expected 3 runs, got %d", runs)
+	}
+}
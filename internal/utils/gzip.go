@@ -17,6 +17,11 @@ func (g *gzipResponseWriter) Write(b []byte) (int, error) {
 	return g.writer.Write(b)
 }
 
+// Unwrap returns the wrapped writer for http.ResponseController.
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
 func (g *gzipResponseWriter) WriteHeader(statusCode int) {
 	g.Header().Del("Content-Length")
 	g.ResponseWriter.WriteHeader(statusCode)
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
 )
@@ -13,3 +14,31 @@ func WriteJSON(w http.ResponseWriter, status int, v any) error {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	return json.NewEncoder(w).Encode(v)
 }
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to the document target:
+// the members of an object patch are merged into the target recursively,
+// a null member removing it, and any other patch replaces the target.
+func MergePatch(target, patch json.RawMessage) (json.RawMessage, error) {
+	var patchObj map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &patchObj); err != nil || patchObj == nil {
+		// Not an object, it replaces the target.
+		return patch, nil
+	}
+
+	var targetObj map[string]json.RawMessage
+	if err := json.Unmarshal(target, &targetObj); err != nil || targetObj == nil {
+		targetObj = map[string]json.RawMessage{}
+	}
+	for name, value := range patchObj {
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			delete(targetObj, name)
+			continue
+		}
+		merged, err := MergePatch(targetObj[name], value)
+		if err != nil {
+			return nil, err
+		}
+		targetObj[name] = merged
+	}
+	return json.Marshal(targetObj)
+}
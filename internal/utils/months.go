@@ -27,6 +27,41 @@ func MonthsOverlap(aStart, aEnd, bStart, bEnd time.Time) int {
 	return months
 }
 
+// ChargesInPeriod counts the billing dates of a subscription that fall into
+// the months from bStart to bEnd. Billing dates are aStart plus whole
+// multiples of stepMonths months and stepDays days, up to the end of the aEnd
+// month. Both ranges are month-inclusive, like in MonthsOverlap.
+func ChargesInPeriod(aStart, aEnd, bStart, bEnd time.Time, stepMonths, stepDays int) int {
+	return ChargesBetween(aStart, EndOfMonth(aEnd), StartOfMonth(bStart), EndOfMonth(bEnd), stepMonths, stepDays)
+}
+
+// ChargesBetween counts the billing dates of a subscription active from
+// aStart to aEnd that fall into the days from bStart to bEnd. All four
+// days are inclusive.
+func ChargesBetween(aStart, aEnd, bStart, bEnd time.Time, stepMonths, stepDays int) int {
+	if aEnd.Before(aStart) || bEnd.Before(bStart) || (stepMonths <= 0 && stepDays <= 0) {
+		return 0
+	}
+
+	charges := 0
+	for k := 0; ; k++ {
+		d := BillingDate(aStart, k, stepMonths, stepDays)
+		if d.After(aEnd) || d.After(bEnd) {
+			break
+		}
+		if !d.Before(bStart) {
+			charges++
+		}
+	}
+	return charges
+}
+
+// BillingDate returns the k-th billing date of a subscription starting at
+// start, k = 0 being the start itself. Month steps are added with AddMonths.
+func BillingDate(start time.Time, k, stepMonths, stepDays int) time.Time {
+	return AddMonths(start, k*stepMonths).AddDate(0, 0, k*stepDays)
+}
+
 func ParseMonthYear(s string) (time.Time, error) {
 	var m, y int
 	if _, err := fmt.Sscanf(s, "%02d-%04d", &m, &y); err != nil {
@@ -34,3 +69,8 @@ func ParseMonthYear(s string) (time.Time, error) {
 	}
 	return time.Date(y, time.Month(m), 1, 0, 0, 0, 0, time.UTC), nil
 }
+
+// StartOfMonth truncates t to midnight UTC of the first day of its month.
+func StartOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -61,3 +61,83 @@ func TestMonthsOverlap_TouchingEdges(t *testing.T) {
     }
 }
 
+
+func TestStartOfMonth(t *testing.T) {
+    got := StartOfMonth(time.Date(2024, 2, 29, 13, 45, 0, 0, time.UTC))
+    expected := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+    if !got.Equal(expected) {
+        t.Fatalf("expected %v, got %v", expected, got)
+    }
+}
+
+func TestChargesInPeriod_MonthlyMatchesMonthsOverlap(t *testing.T) {
+    aStart := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+    aEnd := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+    bStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    bEnd := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+    got := ChargesInPeriod(aStart, aEnd, bStart, bEnd, 1, 0)
+    expected := MonthsOverlap(aStart, aEnd, bStart, bEnd)
+    if got != expected {
+        t.Fatalf("expected %d charges, got %d", expected, got)
+    }
+}
+
+func TestChargesInPeriod_Yearly(t *testing.T) {
+    // billed in 03-2023, 03-2024, 03-2025
+    aStart := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+    aEnd := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+    bStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    bEnd := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+    got := ChargesInPeriod(aStart, aEnd, bStart, bEnd, 12, 0)
+    if got != 1 {
+        t.Fatalf("expected 1 charge, got %d", got)
+    }
+}
+
+func TestChargesInPeriod_QuarterlyMissesPeriod(t *testing.T) {
+    // billed in 01-2024, 04-2024, 07-2024
+    aStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    aEnd := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+    bStart := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+    bEnd := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+    got := ChargesInPeriod(aStart, aEnd, bStart, bEnd, 3, 0)
+    if got != 0 {
+        t.Fatalf("expected 0 charges, got %d", got)
+    }
+}
+
+func TestChargesInPeriod_Weekly(t *testing.T) {
+    // billed on Feb 1, 8, 15, 22 and 29 of 2024
+    aStart := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+    aEnd := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+    bStart := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+    bEnd := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+    got := ChargesInPeriod(aStart, aEnd, bStart, bEnd, 0, 7)
+    if got != 5 {
+        t.Fatalf("expected 5 charges, got %d", got)
+    }
+}
+
+func TestChargesBetween_EndsMidCycle(t *testing.T) {
+    // billed on Jan 15 and Feb 15, the subscription ends before Mar 15
+    aStart := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+    aEnd := time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)
+    bStart := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+    bEnd := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+    got := ChargesBetween(aStart, aEnd, bStart, bEnd, 1, 0)
+    if got != 2 {
+        t.Fatalf("expected 2 charges, got %d", got)
+    }
+}
+
+func TestChargesBetween_DayPrecisePeriod(t *testing.T) {
+    // billed on the 15th, only Feb 15 falls into Feb 10..Mar 10
+    aStart := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+    aEnd := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+    bStart := time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC)
+    bEnd := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+    got := ChargesBetween(aStart, aEnd, bStart, bEnd, 1, 0)
+    if got != 1 {
+        t.Fatalf("expected 1 charge, got %d", got)
+    }
+}
@@ -3,3 +3,7 @@ package utils
 func String(s string) *string {
 	return &s
 }
+
+func Int(i int) *int {
+	return &i
+}
@@ -0,0 +1,72 @@
+package validators
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Limits of the metadata of a subscription.
+const (
+	MaxMetadataBytes = 8 << 10
+	// MaxMetadataDepth counts the nested objects and arrays, the metadata
+	// object itself being 1.
+	MaxMetadataDepth = 5
+)
+
+var (
+	ErrMetadataNotObject = errors.New("metadata is not a JSON object")
+	ErrMetadataTooLarge  = errors.New("metadata is too large")
+	ErrMetadataTooDeep   = errors.New("metadata is nested too deep")
+)
+
+// CheckMetadata checks that raw is a JSON object within the limits.
+func CheckMetadata(raw []byte) error {
+	if len(raw) > MaxMetadataBytes {
+		return ErrMetadataTooLarge
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != json.Delim('{') {
+		return ErrMetadataNotObject
+	}
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+			if depth > MaxMetadataDepth {
+				return ErrMetadataTooDeep
+			}
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return ErrMetadataNotObject
+	}
+	return nil
+}
+
+// MetadataValidator accepts a JSON object within the limits of
+// CheckMetadata, or null for no metadata.
+func MetadataValidator(fl validator.FieldLevel) bool {
+	raw := fl.Field().Bytes()
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return true
+	}
+	return CheckMetadata(raw) == nil
+}
@@ -0,0 +1,63 @@
+package validators
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestCheckMetadata(t *testing.T) {
+	cases := []struct {
+		in   string
+		want error
+	}{
+		{`{}`, nil},
+		{`{"app_store_id": "1000000123", "bank": {"ref": ["a", {"b": 1}]}}`, nil},
+		{`{"a": {"b": {"c": {"d": {"e": 1}}}}}`, nil},
+		{`{"a": {"b": {"c": {"d": {"e": {}}}}}}`, ErrMetadataTooDeep},
+		{`{} {}`, ErrMetadataNotObject},
+		{`{"a": [[[[1]]]]}`, nil},
+		{`{"a": [[[[[1]]]]]}`, ErrMetadataTooDeep},
+		{`[]`, ErrMetadataNotObject},
+		{`"id"`, ErrMetadataNotObject},
+		{`{"a": "` + strings.Repeat("x", MaxMetadataBytes) + `"}`, ErrMetadataTooLarge},
+	}
+
+	for _, tc := range cases {
+		if got := CheckMetadata([]byte(tc.in)); !errors.Is(got, tc.want) {
+			t.Errorf("CheckMetadata(%.40s) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+
+	if err := CheckMetadata([]byte(`{"a": `)); err == nil {
+		t.Error("CheckMetadata accepted invalid JSON")
+	}
+}
+
+func TestMetadataValidator(t *testing.T) {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterValidation("metadata", MetadataValidator)
+
+	type payload struct {
+		Metadata json.RawMessage `validate:"omitempty,metadata"`
+	}
+
+	for in, want := range map[string]bool{
+		``:             true,
+		`null`:         true,
+		`{"id": "42"}`: true,
+		`[1, 2]`:       false,
+	} {
+		var metadata json.RawMessage
+		if in != "" {
+			metadata = json.RawMessage(in)
+		}
+		err := v.Struct(payload{Metadata: metadata})
+		if got := err == nil; got != want {
+			t.Errorf("metadata %q: valid = %v, want %v", in, got, want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package validators
 
 import (
 	"regexp"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -14,3 +15,13 @@ func MonthYearValidator(fl validator.FieldLevel) bool {
 	val := fl.Field().String()
 	return monthYearRegex.MatchString(val)
 }
+
+// MonthYearOrDateValidator accepts a MM-YYYY month or an ISO YYYY-MM-DD date.
+func MonthYearOrDateValidator(fl validator.FieldLevel) bool {
+	val := fl.Field().String()
+	if monthYearRegex.MatchString(val) {
+		return true
+	}
+	_, err := time.Parse("2006-01-02", val)
+	return err == nil
+}
@@ -37,3 +37,34 @@ func TestMonthYearValidator(t *testing.T) {
     }
 }
 
+
+type datePayload struct {
+    Date string `validate:"mm_yyyy_or_date"`
+}
+
+func TestMonthYearOrDateValidator(t *testing.T) {
+    v := validator.New(validator.WithRequiredStructEnabled())
+    v.RegisterValidation("mm_yyyy_or_date", MonthYearOrDateValidator)
+
+    cases := []struct {
+        in   string
+        want bool
+    }{
+        {"01-2006", true},
+        {"2006-01-02", true},
+        {"2024-02-29", true},
+        {"2023-02-29", false},
+        {"2006-13-01", false},
+        {"2006-1-2", false},
+        {"13-2006", false},
+        {"", false},
+    }
+
+    for _, tc := range cases {
+        err := v.Struct(datePayload{Date: tc.in})
+        got := err == nil
+        if got != tc.want {
+            t.Fatalf("input %q: expected %v, got %v (err=%v)", tc.in, tc.want, got, err)
+        }
+    }
+}
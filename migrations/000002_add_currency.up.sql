@@ -0,0 +1,8 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+-- rate is the price of one unit of the currency in the base currency,
+-- the base currency itself has rate 1.
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    currency CHAR(3) PRIMARY KEY,
+    rate NUMERIC(20, 10) NOT NULL CHECK (rate > 0)
+);
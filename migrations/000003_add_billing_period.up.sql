@@ -0,0 +1,7 @@
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS billing_period TEXT NOT NULL DEFAULT 'monthly'
+        CHECK (billing_period IN ('monthly', 'quarterly', 'yearly', 'weekly', 'custom')),
+    ADD COLUMN IF NOT EXISTS billing_interval_months INTEGER
+        CHECK (billing_interval_months > 0),
+    ADD CONSTRAINT subscriptions_custom_billing_interval
+        CHECK (billing_period <> 'custom' OR billing_interval_months IS NOT NULL);
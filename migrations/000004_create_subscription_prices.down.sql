@@ -0,0 +1,2 @@
+DROP VIEW IF EXISTS subscription_price_segments;
+DROP TABLE IF EXISTS subscription_prices;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS subscription_prices (
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    effective_from DATE NOT NULL,
+    price INTEGER NOT NULL,
+    PRIMARY KEY (subscription_id, effective_from)
+);
+
+INSERT INTO subscription_prices (subscription_id, effective_from, price)
+SELECT id, date_trunc('month', start_date), price FROM subscriptions
+ON CONFLICT DO NOTHING;
+
+-- Every price is in effect from its month until the next change, the first
+-- one also covers everything before it.
+CREATE OR REPLACE VIEW subscription_price_segments AS
+SELECT
+    subscription_id,
+    price,
+    CASE WHEN row_number() OVER w = 1 THEN NULL ELSE effective_from END AS valid_from,
+    lead(effective_from) OVER w AS valid_to
+FROM subscription_prices
+WINDOW w AS (PARTITION BY subscription_id ORDER BY effective_from);
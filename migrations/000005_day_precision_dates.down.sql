@@ -0,0 +1,7 @@
+DROP FUNCTION IF EXISTS billing_dates_before(DATE, TEXT, INTEGER, DATE);
+DROP FUNCTION IF EXISTS billing_date(DATE, TEXT, INTEGER, INTEGER);
+DROP FUNCTION IF EXISTS billing_step_months(TEXT, INTEGER);
+
+UPDATE subscriptions
+SET end_date = date_trunc('month', end_date)::date
+WHERE end_date IS NOT NULL;
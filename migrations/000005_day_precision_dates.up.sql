@@ -0,0 +1,51 @@
+-- end_date used to hold the first day of the last month, it is the last
+-- day of the subscription now.
+UPDATE subscriptions
+SET end_date = (date_trunc('month', end_date) + interval '1 month - 1 day')::date
+WHERE end_date IS NOT NULL;
+
+-- Months between billing dates, 0 for weekly billing.
+CREATE OR REPLACE FUNCTION billing_step_months(period TEXT, interval_months INTEGER)
+RETURNS INTEGER
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT CASE period
+        WHEN 'quarterly' THEN 3
+        WHEN 'yearly' THEN 12
+        WHEN 'custom' THEN interval_months
+        WHEN 'weekly' THEN 0
+        ELSE 1
+    END
+$$;
+
+-- The k-th billing date of a subscription, k = 0 being its start date.
+-- Adding months clamps the day to the end of a shorter month.
+CREATE OR REPLACE FUNCTION billing_date(start DATE, period TEXT, interval_months INTEGER, k INTEGER)
+RETURNS DATE
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT CASE
+        WHEN period = 'weekly' THEN start + 7 * k
+        ELSE (start + make_interval(months => k * billing_step_months(period, interval_months)))::date
+    END
+$$;
+
+-- The number of billing dates of a subscription before the day d.
+CREATE OR REPLACE FUNCTION billing_dates_before(start DATE, period TEXT, interval_months INTEGER, d DATE)
+RETURNS INTEGER
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT CASE
+        WHEN d <= start THEN 0
+        WHEN period = 'weekly' THEN (d - start + 6) / 7
+        ELSE (
+            -- Billing months before the month of d, plus the billing date
+            -- in the month of d if it is before d.
+            SELECT (m + step - 1) / step
+                + CASE WHEN m % step = 0 AND billing_date(start, period, interval_months, m / step) < d THEN 1 ELSE 0 END
+            FROM (
+                SELECT
+                    ((EXTRACT(YEAR FROM d) - EXTRACT(YEAR FROM start)) * 12
+                        + EXTRACT(MONTH FROM d) - EXTRACT(MONTH FROM start))::int AS m,
+                    billing_step_months(period, interval_months) AS step
+            ) AS months
+        )
+    END
+$$;
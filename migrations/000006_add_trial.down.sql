@@ -0,0 +1,3 @@
+ALTER TABLE subscriptions
+    DROP CONSTRAINT IF EXISTS subscriptions_trial_end_after_start,
+    DROP COLUMN IF EXISTS trial_end;
@@ -0,0 +1,4 @@
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS trial_end DATE,
+    ADD CONSTRAINT subscriptions_trial_end_after_start
+        CHECK (trial_end IS NULL OR trial_end >= start_date);
@@ -0,0 +1,8 @@
+-- A subscription is not charged on billing dates from paused_from to
+-- paused_until, both inclusive. An open pause has no paused_until.
+CREATE TABLE IF NOT EXISTS subscription_pauses (
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    paused_from DATE NOT NULL,
+    paused_until DATE CHECK (paused_until >= paused_from),
+    PRIMARY KEY (subscription_id, paused_from)
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS subscriptions_price_idx;
+DROP INDEX IF EXISTS subscriptions_service_name_lower_idx;
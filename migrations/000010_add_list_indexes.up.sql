@@ -0,0 +1,5 @@
+-- Case-insensitive service_name_prefix filter of the list.
+CREATE INDEX IF NOT EXISTS subscriptions_service_name_lower_idx
+    ON subscriptions (lower(service_name) text_pattern_ops);
+
+CREATE INDEX IF NOT EXISTS subscriptions_price_idx ON subscriptions (price);
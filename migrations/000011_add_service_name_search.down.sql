@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS subscriptions_service_name_tsv_idx;
+
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS service_name_tsv;
+
+DROP INDEX IF EXISTS subscriptions_search_name_trgm_idx;
+
+DROP FUNCTION IF EXISTS search_name(TEXT);
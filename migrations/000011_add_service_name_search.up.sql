@@ -0,0 +1,25 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm WITH SCHEMA public;
+
+-- The name as compared by the fuzzy search: lower case, with Cyrillic
+-- letters replaced by close Latin ones, so that "Яндекс Плюс" is similar
+-- to "Yandex Plus". The hard and soft signs are dropped.
+CREATE OR REPLACE FUNCTION search_name(name TEXT) RETURNS TEXT
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT translate(
+        lower(name),
+        'абвгдеёжзийклмнопрстуфхцчшщыэюяъь',
+        'abvgdeezziiklmnoprstufhccssyeua'
+    )
+$$;
+
+CREATE INDEX IF NOT EXISTS subscriptions_search_name_trgm_idx
+    ON subscriptions USING gin (search_name(service_name) gin_trgm_ops);
+
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS service_name_tsv TSVECTOR
+        GENERATED ALWAYS AS (
+            to_tsvector('russian', service_name) || to_tsvector('english', service_name)
+        ) STORED;
+
+CREATE INDEX IF NOT EXISTS subscriptions_service_name_tsv_idx
+    ON subscriptions USING gin (service_name_tsv);
@@ -0,0 +1,7 @@
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS service_id;
+
+DROP TABLE IF EXISTS service_aliases;
+DROP TABLE IF EXISTS services;
+
+DROP FUNCTION IF EXISTS service_key(TEXT);
@@ -0,0 +1,49 @@
+-- The key service names are matched by: lower case, with the surrounding
+-- spaces trimmed and the inner ones collapsed.
+CREATE OR REPLACE FUNCTION service_key(name TEXT) RETURNS TEXT
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT lower(regexp_replace(btrim(name), '\s+', ' ', 'g'))
+$$;
+
+CREATE TABLE IF NOT EXISTS services (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL CHECK (service_key(name) <> ''),
+    category TEXT,
+    homepage TEXT,
+    default_price INTEGER CHECK (default_price >= 0),
+    currency CHAR(3) NOT NULL DEFAULT 'RUB'
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS services_name_key ON services (service_key(name));
+
+-- Other spellings of the name of a service, e.g. in another language.
+CREATE TABLE IF NOT EXISTS service_aliases (
+    service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    alias TEXT NOT NULL CHECK (service_key(alias) <> '')
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS service_aliases_alias_key ON service_aliases (service_key(alias));
+CREATE INDEX IF NOT EXISTS service_aliases_service_id_idx ON service_aliases (service_id);
+
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS service_id INTEGER REFERENCES services(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS subscriptions_service_id_idx ON subscriptions (service_id);
+
+-- Every spelling of a name gets one catalog entry, named after its most
+-- used spelling.
+INSERT INTO services (name)
+SELECT DISTINCT ON (service_key(service_name)) service_name
+FROM subscriptions
+WHERE service_key(service_name) <> ''
+GROUP BY service_name
+ORDER BY service_key(service_name), count(*) DESC, service_name
+ON CONFLICT DO NOTHING;
+
+-- The subscriptions are only linked to their entry, their names are kept
+-- as they were typed.
+UPDATE subscriptions
+SET service_id = services.id
+FROM services
+WHERE subscriptions.service_id IS NULL
+  AND service_key(subscriptions.service_name) = service_key(services.name);
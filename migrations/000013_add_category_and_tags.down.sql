@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS subscriptions_tags_idx;
+DROP INDEX IF EXISTS subscriptions_category_idx;
+
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS tags,
+    DROP COLUMN IF EXISTS category;
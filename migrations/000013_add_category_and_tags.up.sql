@@ -0,0 +1,10 @@
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS category TEXT
+        CHECK (category IN ('streaming', 'music', 'gaming', 'cloud', 'software', 'news', 'education', 'fitness', 'other')),
+    -- Lowercase, without duplicates.
+    ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS subscriptions_category_idx ON subscriptions (category);
+
+-- tag filter of the list: tags @> ARRAY[...].
+CREATE INDEX IF NOT EXISTS subscriptions_tags_idx ON subscriptions USING GIN (tags);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS subscriptions_metadata_idx;
+
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS notes,
+    DROP COLUMN IF EXISTS metadata;
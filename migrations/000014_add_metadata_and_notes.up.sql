@@ -0,0 +1,9 @@
+ALTER TABLE subscriptions
+    -- External ids and other data of integrations, always an object.
+    ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}'
+        CHECK (jsonb_typeof(metadata) = 'object'),
+    ADD COLUMN IF NOT EXISTS notes TEXT;
+
+-- metadata filter of the list: metadata @> '{"key": "value"}' and
+-- metadata ?& ARRAY['key'].
+CREATE INDEX IF NOT EXISTS subscriptions_metadata_idx ON subscriptions USING GIN (metadata);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS subscriptions_deleted_at_idx;
+
+DELETE FROM subscriptions WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS deleted_at;
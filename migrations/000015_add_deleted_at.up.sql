@@ -0,0 +1,6 @@
+-- A deleted subscription is kept in the trash until it is restored or
+-- purged after the retention period.
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- The trash listing and the purge only look at the deleted rows.
+CREATE INDEX IF NOT EXISTS subscriptions_deleted_at_idx ON subscriptions (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,18 @@
+-- The audit trail of the subscriptions: every change with the
+-- subscription, its prices and pauses as JSON before and after it. There
+-- is no foreign key, the history outlives a purged subscription.
+CREATE TABLE IF NOT EXISTS subscription_events (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('create', 'update', 'delete', 'restore')),
+    before JSONB,
+    after JSONB,
+    actor TEXT,
+    request_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS subscription_events_subscription_id_idx ON subscription_events (subscription_id, id);
+CREATE INDEX IF NOT EXISTS subscription_events_created_at_idx ON subscription_events (created_at);
+CREATE INDEX IF NOT EXISTS subscription_events_actor_idx ON subscription_events (actor);
+CREATE INDEX IF NOT EXISTS subscription_events_request_id_idx ON subscription_events (request_id);
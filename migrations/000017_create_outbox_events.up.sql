@@ -0,0 +1,18 @@
+-- Domain events waiting to be dispatched to the other services. They are
+-- written in the transaction of the change and removed once dispatched.
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    type TEXT NOT NULL CHECK (type IN (
+        'subscription.created',
+        'subscription.updated',
+        'subscription.deleted',
+        'subscription.ended'
+    )),
+    subscription_id INTEGER NOT NULL,
+    -- The subscription after and before the change, as in subscription_events.
+    subscription JSONB NOT NULL,
+    previous JSONB,
+    actor TEXT,
+    request_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS subscriptions_price_idx ON subscriptions (price);
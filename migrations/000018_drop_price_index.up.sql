@@ -0,0 +1,3 @@
+-- The list filters and sorts on the current price from the price history,
+-- which the index on the base price doesn't serve.
+DROP INDEX IF EXISTS subscriptions_price_idx;
@@ -0,0 +1,5 @@
+ALTER TABLE subscription_prices
+    DROP CONSTRAINT IF EXISTS subscription_prices_price_check;
+
+ALTER TABLE subscriptions
+    DROP CONSTRAINT IF EXISTS subscriptions_price_check;
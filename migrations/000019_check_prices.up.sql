@@ -0,0 +1,5 @@
+ALTER TABLE subscriptions
+    ADD CONSTRAINT subscriptions_price_check CHECK (price >= 0);
+
+ALTER TABLE subscription_prices
+    ADD CONSTRAINT subscription_prices_price_check CHECK (price >= 0);
@@ -0,0 +1,11 @@
+-- The same key of different clients can't be kept, only the unscoped
+-- ones are.
+DELETE FROM idempotency_keys WHERE client <> '';
+
+ALTER TABLE idempotency_keys
+    DROP CONSTRAINT IF EXISTS idempotency_keys_pkey,
+    ADD PRIMARY KEY (key);
+
+ALTER TABLE idempotency_keys
+    DROP COLUMN IF EXISTS response_headers,
+    DROP COLUMN IF EXISTS client;
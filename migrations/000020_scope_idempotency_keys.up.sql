@@ -0,0 +1,9 @@
+-- Keys are unique per client, and the response headers a retry needs,
+-- e.g. ETag, are kept with the body.
+ALTER TABLE idempotency_keys
+    ADD COLUMN IF NOT EXISTS client TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS response_headers JSONB;
+
+ALTER TABLE idempotency_keys
+    DROP CONSTRAINT IF EXISTS idempotency_keys_pkey,
+    ADD PRIMARY KEY (client, key);